@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+)
+
+// validateAccessFilter parses AllowHosts. The filter takes over the one configured bind
+// host and moves ssh's SOCKS listener to a loopback port of its own, see startAccessFilter.
+func (c *config) validateAccessFilter() error {
+	networks, err := parseCIDRs(c.AllowHosts, "allowed host CIDR")
+	if err != nil {
+		return err
+	}
+	c.allowedNets = networks
+
+	if len(c.allowedNets) == 0 {
+		if c.SSHBindLocalOnly {
+			return fmt.Errorf("bind local only requires SSH_TUNNEL_ALLOW_HOSTS")
+		}
+		return nil
+	}
+	switch {
+	case c.TunnelMode != tunnelModeDynamic:
+		return fmt.Errorf("allowed hosts require dynamic tunnel mode")
+	case len(c.SSHBindHosts) > 1:
+		return fmt.Errorf("allowed hosts require a single bind host")
+	case c.OverlapRestart:
+		return fmt.Errorf("allowed hosts cannot be combined with overlap restart")
+	}
+	return nil
+}
+
+// accessFilterAddr returns where the access filter listens: the configured bind host,
+// or its port on all interfaces with SSHBindLocalOnly.
+func (c *config) accessFilterAddr() string {
+	bindHost := c.SSHBindHost
+	if len(c.SSHBindHosts) > 0 {
+		bindHost = c.SSHBindHosts[0]
+	}
+	if !c.SSHBindLocalOnly {
+		return bindHost
+	}
+	_, port, _ := net.SplitHostPort(bindHost)
+	return net.JoinHostPort("0.0.0.0", port)
+}
+
+// startAccessFilter listens on the bind host in ssh's place when AllowHosts is set, and
+// points ssh's -D at a free loopback port. Clients from allowed networks are forwarded
+// to it, others are disconnected. Health checks go to ssh's port directly.
+func (app *Application) startAccessFilter() error {
+	if len(app.config.allowedNets) == 0 {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", app.config.accessFilterAddr())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", app.config.accessFilterAddr(), err)
+	}
+	backend, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	app.configMutex.Lock()
+	app.config.activeBindHost = backend
+	err = app.config.deriveProxyHost()
+	app.configMutex.Unlock()
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	app.accessFilter = listener
+	go app.serveAccessFilter(listener)
+	app.logger.Info("Access filter listening", "addr", listener.Addr().String(), "ssh_bind", backend, "allow_hosts", app.config.AllowHosts)
+	return nil
+}
+
+// stopAccessFilter stops accepting clients. Open connections end with the ssh process.
+func (app *Application) stopAccessFilter() {
+	if app.accessFilter == nil {
+		return
+	}
+	if err := app.accessFilter.Close(); err != nil {
+		app.logger.Error("Failed to close access filter", "error", err)
+	}
+}
+
+// serveAccessFilter accepts clients until the listener is closed.
+func (app *Application) serveAccessFilter(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			app.logger.Error("Access filter failed to accept a connection", "error", err)
+			continue
+		}
+		go app.filterConn(conn)
+	}
+}
+
+// filterConn forwards an allowed client to ssh's SOCKS port and closes any other.
+func (app *Application) filterConn(client net.Conn) {
+	defer func() { _ = client.Close() }()
+
+	app.configMutex.RLock()
+	allowed := allowedAddr(client.RemoteAddr(), app.config.allowedNets)
+	backend := app.config.proxyHost
+	timeout := app.config.PortCheckTimeout
+	app.configMutex.RUnlock()
+
+	if !allowed {
+		app.logger.Debug("Proxy connection from a disallowed address", "remote", client.RemoteAddr().String())
+		return
+	}
+
+	server, err := net.DialTimeout("tcp", backend, timeout)
+	if err != nil {
+		app.logger.Warn("Access filter failed to reach the SSH proxy", "backend", backend, "error", err)
+		return
+	}
+	defer func() { _ = server.Close() }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(server, client)
+		closeWrite(server)
+	}()
+	_, _ = io.Copy(client, server)
+	closeWrite(client)
+	<-done
+}
+
+// allowedAddr reports whether addr is inside one of networks.
+func allowedAddr(addr net.Addr, networks []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && slices.ContainsFunc(networks, func(n *net.IPNet) bool { return n.Contains(ip) })
+}
+
+// closeWrite half-closes conn so the peer sees the end of the stream, or closes it
+// if it doesn't support half-closing.
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestValidateAccessFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		ok     bool
+	}{
+		{"unset", func(*config) {}, true},
+		{"allowed hosts", func(c *config) { c.AllowHosts = []string{"10.0.0.0/8", " 192.168.1.0/24"} }, true},
+		{"bind local only", func(c *config) {
+			c.AllowHosts = []string{"10.0.0.0/8"}
+			c.SSHBindLocalOnly = true
+		}, true},
+		{"invalid CIDR", func(c *config) { c.AllowHosts = []string{"10.0.0.1"} }, false},
+		{"bind local only without allowed hosts", func(c *config) { c.SSHBindLocalOnly = true }, false},
+		{"several bind hosts", func(c *config) {
+			c.AllowHosts = []string{"10.0.0.0/8"}
+			c.SSHBindHosts = []string{"127.0.0.1:8080", "127.0.0.1:8081"}
+		}, false},
+		{"overlap restart", func(c *config) {
+			c.AllowHosts = []string{"10.0.0.0/8"}
+			c.OverlapRestart = true
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAccessFilterAddr(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHBindHost = "192.0.2.1:1080"
+	if got := cfg.accessFilterAddr(); got != "192.0.2.1:1080" {
+		t.Errorf("accessFilterAddr() = %q, want the bind host", got)
+	}
+	cfg.SSHBindLocalOnly = true
+	if got := cfg.accessFilterAddr(); got != "0.0.0.0:1080" {
+		t.Errorf("accessFilterAddr() = %q, want all interfaces", got)
+	}
+}
+
+// echoServer accepts connections on addr and echoes what they send.
+func echoServer(t *testing.T, addr string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+}
+
+func TestAccessFilter(t *testing.T) {
+	app := newTestApp(t)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	app.config.AllowHosts = []string{"127.0.0.0/8"}
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if err := app.startAccessFilter(); err != nil {
+		t.Fatalf("startAccessFilter: %v", err)
+	}
+	defer app.stopAccessFilter()
+	if app.config.proxyHost == bindHost {
+		t.Fatal("ssh should listen on a port of its own behind the filter")
+	}
+	echoServer(t, app.config.proxyHost)
+
+	conn, err := net.Dial("tcp", bindHost)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("read %q, %v; want the echo from ssh's port", buf, err)
+	}
+	_ = conn.Close()
+
+	// Clients outside the allowed networks are disconnected
+	app.configMutex.Lock()
+	app.config.allowedNets, _ = parseCIDRs([]string{"10.0.0.0/8"}, "test")
+	app.configMutex.Unlock()
+
+	conn, err = net.Dial("tcp", bindHost)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, _ = conn.Write([]byte("ping"))
+	if n, err := conn.Read(buf); err == nil {
+		t.Errorf("read %q from a disallowed client, want the connection closed", buf[:n])
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "time"
+
+// rttSmoothing is the weight of a new port check round trip in its moving average.
+const rttSmoothing = 0.3
+
+// recordPortCheckRTT folds a successful port check's round trip into the moving
+// average that sets the adaptive loop interval.
+func (app *Application) recordPortCheckRTT(rtt time.Duration) {
+	if !app.config.AdaptiveLoop {
+		return
+	}
+	avg := rtt
+	if prev := time.Duration(app.portCheckRTT.Load()); prev > 0 {
+		avg = time.Duration(rttSmoothing*float64(rtt) + (1-rttSmoothing)*float64(prev))
+	}
+	app.portCheckRTT.Store(int64(avg))
+}
+
+// loopSleep returns the main loop interval. With SSH_TUNNEL_ADAPTIVE_LOOP it is the
+// averaged port check round trip times RTTMultiplier, kept within MinLoopSleep and
+// MaxLoopSleep; MainLoopSleep, within the same limits, until the first measurement.
+func (app *Application) loopSleep() time.Duration {
+	if !app.config.AdaptiveLoop {
+		return app.config.MainLoopSleep
+	}
+
+	interval := app.config.MainLoopSleep
+	if rtt := app.portCheckRTT.Load(); rtt > 0 {
+		interval = time.Duration(float64(rtt) * app.config.RTTMultiplier)
+	}
+	return min(max(interval, app.config.MinLoopSleep), app.config.MaxLoopSleep)
+}
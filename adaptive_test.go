@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// adaptiveTestApp returns an app with the adaptive loop enabled and default limits.
+func adaptiveTestApp(t *testing.T) *Application {
+	t.Helper()
+	app := newTestApp(t)
+	app.config.AdaptiveLoop = true
+	app.config.MinLoopSleep = 5 * time.Second
+	app.config.MaxLoopSleep = 60 * time.Second
+	app.config.RTTMultiplier = 3
+	return app
+}
+
+func TestRecordPortCheckRTT(t *testing.T) {
+	app := adaptiveTestApp(t)
+
+	app.recordPortCheckRTT(10 * time.Second)
+	if got := time.Duration(app.portCheckRTT.Load()); got != 10*time.Second {
+		t.Errorf("first measurement: average = %s, want 10s", got)
+	}
+
+	// 0.3 * 20s + 0.7 * 10s
+	app.recordPortCheckRTT(20 * time.Second)
+	if got := time.Duration(app.portCheckRTT.Load()); got != 13*time.Second {
+		t.Errorf("second measurement: average = %s, want 13s", got)
+	}
+
+	app.config.AdaptiveLoop = false
+	app.recordPortCheckRTT(time.Hour)
+	if got := time.Duration(app.portCheckRTT.Load()); got != 13*time.Second {
+		t.Errorf("measured while disabled: average = %s, want 13s", got)
+	}
+}
+
+func TestLoopSleep(t *testing.T) {
+	tests := []struct {
+		name     string
+		adaptive bool
+		rtt      time.Duration
+		want     time.Duration
+	}{
+		{"disabled", false, 10 * time.Second, 15 * time.Second},
+		{"no measurement yet", true, 0, 15 * time.Second},
+		{"within limits", true, 10 * time.Second, 30 * time.Second},
+		{"below min", true, time.Millisecond, 5 * time.Second},
+		{"above max", true, time.Minute, 60 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := adaptiveTestApp(t)
+			app.config.AdaptiveLoop = tt.adaptive
+			app.config.MainLoopSleep = 15 * time.Second
+			app.portCheckRTT.Store(int64(tt.rtt))
+
+			if got := app.loopSleep(); got != tt.want {
+				t.Errorf("loopSleep() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_AdaptiveLoop(t *testing.T) {
+	tests := []struct {
+		name       string
+		min, max   time.Duration
+		multiplier float64
+		jitter     time.Duration
+		ok         bool
+	}{
+		{"defaults", 5 * time.Second, 60 * time.Second, 3, 0, true},
+		{"equal limits", 5 * time.Second, 5 * time.Second, 3, 0, true},
+		{"zero min", 0, 60 * time.Second, 3, 0, false},
+		{"max below min", 5 * time.Second, time.Second, 3, 0, false},
+		{"zero multiplier", 5 * time.Second, 60 * time.Second, 0, 0, false},
+		{"jitter above min", 5 * time.Second, 60 * time.Second, 3, 10 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.AdaptiveLoop = true
+			cfg.MinLoopSleep = tt.min
+			cfg.MaxLoopSleep = tt.max
+			cfg.RTTMultiplier = tt.multiplier
+			cfg.MainLoopJitter = tt.jitter
+			err := cfg.validate()
+			if (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMgmtAPI_StatusLoopInterval(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+
+	var body statusResponse
+	decodeBody(t, doRequest(t, http.MethodGet, srv.URL+"/api/v1/status", "", ""), &body)
+	if body.Tunnels[0].LoopInterval != 0 {
+		t.Errorf("loop_interval_seconds = %v without the adaptive loop, want it omitted", body.Tunnels[0].LoopInterval)
+	}
+
+	app.config.AdaptiveLoop = true
+	app.config.MinLoopSleep = 5 * time.Second
+	app.config.MaxLoopSleep = 60 * time.Second
+	app.config.RTTMultiplier = 3
+	app.portCheckRTT.Store(int64(4 * time.Second))
+
+	decodeBody(t, doRequest(t, http.MethodGet, srv.URL+"/api/v1/status", "", ""), &body)
+	if body.Tunnels[0].LoopInterval != 12 {
+		t.Errorf("loop_interval_seconds = %v, want 12", body.Tunnels[0].LoopInterval)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// agentSocketEnv is the environment variable ssh reads the agent socket from.
+const agentSocketEnv = "SSH_AUTH_SOCK"
+
+// agentPIDEnv is the environment variable ssh-agent reports its PID in.
+const agentPIDEnv = "SSH_AGENT_PID"
+
+// agentDialTimeout bounds the probe of a single agent socket.
+const agentDialTimeout = time.Second
+
+// agentSpawnTimeout bounds ssh-agent's startup, which forks and exits once the socket is ready.
+const agentSpawnTimeout = 5 * time.Second
+
+// sshAgentBinary is the ssh-agent executable and is replaced in tests.
+var sshAgentBinary = "ssh-agent"
+
+// agentOutputVar matches the NAME=value assignments of `ssh-agent -s`.
+var agentOutputVar = regexp.MustCompile(`(SSH_AUTH_SOCK|SSH_AGENT_PID)=([^;\s]+)`)
+
+// sshTimeInterval matches the sshd_config(5) time format, e.g. "3600", "1h" or "1h30m".
+var sshTimeInterval = regexp.MustCompile(`^([0-9]+[sSmMhHdDwW]?)+$`)
+
+// validateAddKeysToAgent accepts the AddKeysToAgent values of ssh_config(5):
+// "yes", "no", "ask", "confirm" or a key lifetime.
+func validateAddKeysToAgent(value string) error {
+	switch value {
+	case "yes", "no", "ask", "confirm":
+		return nil
+	}
+	if !sshTimeInterval.MatchString(value) {
+		return fmt.Errorf("invalid add keys to agent %q: must be yes, no, ask, confirm or a time interval such as 1h", value)
+	}
+	return nil
+}
+
+// agentSocketPatterns returns glob patterns of common agent socket locations,
+// most specific first; replaced in tests.
+var agentSocketPatterns = func() []string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return []string{
+		filepath.Join(runtimeDir, "gnupg", "S.gpg-agent.ssh"),
+		filepath.Join(runtimeDir, "ssh-agent.socket"), // systemd user unit
+		filepath.Join(runtimeDir, "keyring", "ssh"),   // GNOME Keyring
+		filepath.Join(os.TempDir(), "ssh-*", "agent.*"),
+		"/private/tmp/com.apple.launchd.*/Listeners", // macOS launchd agent
+	}
+}
+
+// agentSocketAlive reports whether an agent accepts connections on the socket at path.
+func agentSocketAlive(path string) bool {
+	conn, err := net.DialTimeout("unix", path, agentDialTimeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// refreshAgentSocket points SSH_AUTH_SOCK at a live agent when the current socket
+// is gone, e.g. after a re-login or a reattached tmux session. ssh and the other
+// commands started later inherit the updated environment.
+func refreshAgentSocket(logger *slog.Logger) {
+	current := os.Getenv(agentSocketEnv)
+	if current != "" && agentSocketAlive(current) {
+		return
+	}
+
+	for _, pattern := range agentSocketPatterns() {
+		// Glob only fails on malformed patterns
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			if path == current || !agentSocketAlive(path) {
+				continue
+			}
+			if err := os.Setenv(agentSocketEnv, path); err != nil {
+				logger.Error("Failed to update SSH agent socket", "error", err)
+				return
+			}
+			logger.Info("SSH agent socket is not reachable, switched to a live agent", "path", path, "previous", current)
+			return
+		}
+	}
+
+	logger.Warn("SSH agent socket is not reachable and no live agent was found", "path", current)
+}
+
+// ensureAgent starts a new ssh-agent when SSH_AUTH_SOCK doesn't accept connections,
+// e.g. because the agent it pointed to died, and points ssh at it. The agent is
+// stopped again by stopAgent; one started earlier that has died is replaced.
+func (app *Application) ensureAgent(logger *slog.Logger) {
+	current := os.Getenv(agentSocketEnv)
+	if current != "" && agentSocketAlive(current) {
+		return
+	}
+
+	// Our own agent died; forget it before starting the next one
+	app.stopAgent(logger)
+
+	socket, pid, err := spawnAgent()
+	if err != nil {
+		logger.Error("Failed to start ssh-agent", "error", err, "previous", current)
+		return
+	}
+	if err := os.Setenv(agentSocketEnv, socket); err != nil {
+		logger.Error("Failed to update SSH agent socket", "error", err)
+		return
+	}
+	if err := os.Setenv(agentPIDEnv, strconv.Itoa(pid)); err != nil {
+		logger.Error("Failed to update SSH agent PID", "error", err)
+	}
+	app.agentPID = pid
+	logger.Info("SSH agent socket is not reachable, started a new agent", "path", socket, "agent_pid", pid, "previous", current)
+}
+
+// spawnAgent runs `ssh-agent -s` and returns the socket and PID of the agent it leaves running.
+func spawnAgent() (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), agentSpawnTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, sshAgentBinary, "-s").Output() //nolint:gosec
+	if err != nil {
+		return "", 0, err
+	}
+	return parseAgentOutput(string(out))
+}
+
+// parseAgentOutput extracts SSH_AUTH_SOCK and SSH_AGENT_PID from the Bourne shell
+// commands printed by `ssh-agent -s`.
+func parseAgentOutput(out string) (string, int, error) {
+	var socket string
+	pid := 0
+	for _, match := range agentOutputVar.FindAllStringSubmatch(out, -1) {
+		switch match[1] {
+		case agentSocketEnv:
+			socket = match[2]
+		case agentPIDEnv:
+			n, err := strconv.Atoi(match[2])
+			if err != nil || n <= 0 {
+				return "", 0, fmt.Errorf("invalid %s: %q", agentPIDEnv, match[2])
+			}
+			pid = n
+		}
+	}
+	if socket == "" || pid == 0 {
+		return "", 0, errors.New("ssh-agent output has no socket or PID")
+	}
+	return socket, pid, nil
+}
+
+// stopAgent terminates the ssh-agent started by ensureAgent, if any.
+func (app *Application) stopAgent(logger *slog.Logger) {
+	if app.agentPID == 0 {
+		return
+	}
+	pid := app.agentPID
+	app.agentPID = 0
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	if err := terminateProcess(process); err != nil {
+		if !errors.Is(err, os.ErrProcessDone) {
+			logger.Error("Failed to stop ssh-agent", "agent_pid", pid, "error", err)
+		}
+		return
+	}
+	logger.Info("Stopped ssh-agent", "agent_pid", pid)
+}
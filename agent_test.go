@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenAgentSocket listens on a Unix socket standing in for an ssh-agent.
+func listenAgentSocket(t *testing.T, path string) {
+	t.Helper()
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+}
+
+// useFakeAgent points sshAgentBinary at a script that prints socket as the agent socket
+// and leaves a process running that writes to the returned file when terminated.
+func useFakeAgent(t *testing.T, socket string) string {
+	t.Helper()
+	requireShell(t)
+
+	dir := t.TempDir()
+	stopped := filepath.Join(dir, "stopped")
+	ready := filepath.Join(dir, "ready")
+	script := filepath.Join(dir, "ssh-agent")
+	// Like ssh-agent, only report the agent once it handles signals
+	content := "#!/bin/sh\n" +
+		"sh -c 'trap \"echo stopped > " + stopped + "; exit 0\" TERM; : > " + ready + "; while :; do sleep 0.1; done' >/dev/null 2>&1 &\n" +
+		"while [ ! -e " + ready + " ]; do sleep 0.01; done\n" +
+		"echo \"SSH_AUTH_SOCK=" + socket + "; export SSH_AUTH_SOCK;\"\n" +
+		"echo \"SSH_AGENT_PID=$!; export SSH_AGENT_PID;\"\n" +
+		"echo \"echo Agent pid $!;\"\n"
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil {
+		t.Fatalf("failed to write fake ssh-agent: %v", err)
+	}
+
+	original := sshAgentBinary
+	sshAgentBinary = script
+	t.Cleanup(func() { sshAgentBinary = original })
+	return stopped
+}
+
+// useAgentSocketPatterns replaces the searched agent socket locations.
+func useAgentSocketPatterns(t *testing.T, patterns ...string) {
+	t.Helper()
+	original := agentSocketPatterns
+	agentSocketPatterns = func() []string { return patterns }
+	t.Cleanup(func() { agentSocketPatterns = original })
+}
+
+func TestRefreshAgentSocket(t *testing.T) {
+	dir := t.TempDir()
+	live := filepath.Join(dir, "ssh-live", "agent.42")
+	if err := os.Mkdir(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	listenAgentSocket(t, live)
+	stale := filepath.Join(dir, "ssh-stale", "agent.7")
+	useAgentSocketPatterns(t, filepath.Join(dir, "missing", "S.gpg-agent.ssh"), filepath.Join(dir, "ssh-*", "agent.*"))
+
+	tests := []struct {
+		name    string
+		current string
+		want    string
+		logged  string
+	}{
+		{"live socket is kept", live, live, ""},
+		{"stale socket is replaced", stale, live, "switched to a live agent"},
+		{"unset socket is filled in", "", live, "switched to a live agent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(agentSocketEnv, tt.current)
+			var buf bytes.Buffer
+			refreshAgentSocket(slog.New(slog.NewTextHandler(&buf, nil)))
+
+			if got := os.Getenv(agentSocketEnv); got != tt.want {
+				t.Errorf("%s = %q, want %q", agentSocketEnv, got, tt.want)
+			}
+			if !strings.Contains(buf.String(), tt.logged) || (tt.logged == "" && buf.Len() > 0) {
+				t.Errorf("log = %q, want %q", buf.String(), tt.logged)
+			}
+		})
+	}
+}
+
+func TestRefreshAgentSocket_NoLiveAgent(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "agent.sock")
+	useAgentSocketPatterns(t, filepath.Join(dir, "*.sock"))
+	t.Setenv(agentSocketEnv, stale)
+
+	var buf bytes.Buffer
+	refreshAgentSocket(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	if got := os.Getenv(agentSocketEnv); got != stale {
+		t.Errorf("%s = %q, want it left at %q", agentSocketEnv, got, stale)
+	}
+	if !strings.Contains(buf.String(), "level=WARN") {
+		t.Errorf("expected a warning, got %q", buf.String())
+	}
+}
+
+func TestParseAgentOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		socket string
+		pid    int
+		ok     bool
+	}{
+		{
+			"ssh-agent -s",
+			"SSH_AUTH_SOCK=/tmp/ssh-XXXXabcd/agent.41; export SSH_AUTH_SOCK;\nSSH_AGENT_PID=42; export SSH_AGENT_PID;\necho Agent pid 42;\n",
+			"/tmp/ssh-XXXXabcd/agent.41", 42, true,
+		},
+		{"missing PID", "SSH_AUTH_SOCK=/tmp/agent.1; export SSH_AUTH_SOCK;\n", "", 0, false},
+		{"invalid PID", "SSH_AUTH_SOCK=/tmp/agent.1;\nSSH_AGENT_PID=abc;\n", "", 0, false},
+		{"empty", "", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket, pid, err := parseAgentOutput(tt.out)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if socket != tt.socket || pid != tt.pid {
+				t.Errorf("parseAgentOutput() = %q, %d, want %q, %d", socket, pid, tt.socket, tt.pid)
+			}
+		})
+	}
+}
+
+func TestValidateAddKeysToAgent(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"yes", true},
+		{"no", true},
+		{"ask", true},
+		{"confirm", true},
+		{"3600", true},
+		{"1h", true},
+		{"1h30m", true},
+		{"", false},
+		{"always", false},
+		{"1x", false},
+		{"-1h", false},
+	}
+
+	for _, tt := range tests {
+		if err := validateAddKeysToAgent(tt.value); (err == nil) != tt.ok {
+			t.Errorf("validateAddKeysToAgent(%q) err=%v, want ok=%v", tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestEnsureAgent(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "agent.sock")
+	stopped := useFakeAgent(t, socket)
+	listenAgentSocket(t, socket)
+	t.Setenv(agentSocketEnv, filepath.Join(dir, "dead.sock"))
+	t.Setenv(agentPIDEnv, "")
+
+	app := newTestApp(t)
+	app.ensureAgent(app.logger)
+
+	if got := os.Getenv(agentSocketEnv); got != socket {
+		t.Errorf("%s = %q, want the new agent's %q", agentSocketEnv, got, socket)
+	}
+	if app.agentPID == 0 || os.Getenv(agentPIDEnv) != strconv.Itoa(app.agentPID) {
+		t.Fatalf("agentPID = %d, %s = %q, want the new agent's PID", app.agentPID, agentPIDEnv, os.Getenv(agentPIDEnv))
+	}
+
+	app.stopAgent(app.logger)
+	if app.agentPID != 0 {
+		t.Errorf("agentPID = %d after stopAgent, want 0", app.agentPID)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(stopped); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("spawned agent was not terminated")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestEnsureAgent_LiveSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listenAgentSocket(t, socket)
+	t.Setenv(agentSocketEnv, socket)
+
+	original := sshAgentBinary
+	sshAgentBinary = filepath.Join(t.TempDir(), "missing-ssh-agent")
+	t.Cleanup(func() { sshAgentBinary = original })
+
+	app := newTestApp(t)
+	app.ensureAgent(app.logger)
+
+	if got := os.Getenv(agentSocketEnv); got != socket || app.agentPID != 0 {
+		t.Errorf("%s = %q, agentPID = %d, want the live socket kept and no agent started", agentSocketEnv, got, app.agentPID)
+	}
+}
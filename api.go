@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// mgmtTokenHeader carries the shared secret required by write endpoints.
+const mgmtTokenHeader = "X-SSH-Tunnel-Token"
+
+// tunnelStatus describes a single tunnel in the /api/v1/status response.
+type tunnelStatus struct {
+	ID            string      `json:"id"`
+	ProxyHost     string      `json:"proxy_host"`
+	ProxyHosts    []string    `json:"proxy_hosts"`
+	Remote        string      `json:"remote"`
+	ActiveRemote  string      `json:"active_remote"` // server ssh connects to, the fallback if the primary was unreachable
+	ActivePort    int         `json:"active_remote_port"`
+	State         string      `json:"state"`
+	Running       bool        `json:"running"`
+	Paused        bool        `json:"paused"`
+	LastRequestID string      `json:"last_request_id,omitempty"`
+	LoopInterval  float64     `json:"loop_interval_seconds,omitempty"` // adaptive main loop interval
+	SSHPID        int         `json:"ssh_pid,omitempty"`
+	Stats         TunnelStats `json:"stats"`
+
+	Tags map[string]string `json:"tags,omitempty"` // TunnelTags
+
+	Forwards []forwardStatus `json:"forwards,omitempty"` // one per forwarding rule of the SSH process
+}
+
+// Forward states in the status, from the last port check of the forward's address.
+const (
+	forwardStateUp        = "up"
+	forwardStateDown      = "down"
+	forwardStateUnknown   = "unknown"   // not checked yet
+	forwardStateUnchecked = "unchecked" // no address to check, e.g. a local socket
+)
+
+// forwardStatus is one forwarding rule in GET /api/v1/status.
+type forwardStatus struct {
+	Rule    string `json:"rule"`              // ssh arguments, e.g. "-L 5432:db:5432"
+	Address string `json:"address,omitempty"` // where health checks connect
+	State   string `json:"state"`
+}
+
+// statusResponse is the body of GET /api/v1/status.
+type statusResponse struct {
+	Tunnels []tunnelStatus `json:"tunnels"`
+}
+
+// configUpdate is the body of PUT /api/v1/config. Keys match those returned by
+// GET /api/v1/config. Only mutable fields are accepted; nil fields are left unchanged.
+type configUpdate struct {
+	MainLoopSleep       *string `json:"main_loop_sleep_sec"`
+	PortCheckTimeout    *string `json:"port_check_timeout_sec"`
+	RemoteAddress       *string `json:"remote_address"`
+	RemotePort          *int    `json:"remote_port"`
+	SocksDNS            *string `json:"socks_dns"`
+	TCPKeepAlive        *bool   `json:"tcp_keepalive"`
+	ServerAliveInterval *int    `json:"server_alive_interval"`
+	ConnectTimeout      *int    `json:"connect_timeout"`
+	StrictHostChecking  *bool   `json:"strict_host_checking"`
+}
+
+// sshOptionsUpdate is the body of PUT /api/v1/ssh-options: connection tuning that
+// can change without a config reload. Nil fields are left unchanged.
+type sshOptionsUpdate struct {
+	TCPKeepAlive        *bool `json:"tcp_keepalive"`
+	ServerAliveInterval *int  `json:"server_alive_interval"`
+	ConnectTimeout      *int  `json:"connect_timeout"`
+	ChannelTimeout      *int  `json:"channel_timeout"`
+	Compression         *bool `json:"compression"`
+}
+
+// configApplier is a partial config update from a request body.
+type configApplier interface {
+	apply(cfg *config) error
+}
+
+// tunnelID identifies the tunnel managed by this instance.
+// Instances are already distinguished by proxy port, so the port doubles as the ID.
+func (app *Application) tunnelID() string {
+	return app.config.proxyPort
+}
+
+// startMgmtServer starts the management API if an address is configured.
+func (app *Application) startMgmtServer() error {
+	if app.config.MgmtAddr == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", app.config.MgmtAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", app.config.MgmtAddr, err)
+	}
+
+	app.mgmtServer = &http.Server{
+		Handler:           app.mgmtHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := app.mgmtServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Error("Management API server failed", "error", err)
+		}
+	}()
+
+	app.logger.Info("Management API listening", "addr", listener.Addr().String())
+	return nil
+}
+
+// stopMgmtServer gracefully shuts down the management API.
+func (app *Application) stopMgmtServer() {
+	if app.mgmtServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := app.mgmtServer.Shutdown(ctx); err != nil {
+		app.logger.Error("Failed to shut down management API", "error", err)
+	}
+}
+
+// mgmtHandler builds the management API routes.
+func (app *Application) mgmtHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/status", app.handleStatus)
+	mux.HandleFunc("POST /api/v1/tunnels/{id}/restart", app.requireToken(app.handleRestart))
+	mux.HandleFunc("GET /api/v1/sessions", app.handleSessions)
+	mux.HandleFunc("GET /api/v1/config", app.handleGetConfig)
+	mux.HandleFunc("PUT /api/v1/config", app.requireToken(app.handlePutConfig))
+	mux.HandleFunc("PUT /api/v1/ssh-options", app.requireToken(app.handlePutSSHOptions))
+	mux.HandleFunc("GET /livez", app.handleLivez)
+	mux.HandleFunc("GET /readyz", app.handleReadyz)
+	mux.HandleFunc("GET /startupz", app.handleStartupz)
+	return app.ipAllowMiddleware(mux)
+}
+
+// parseMgmtAllowedCIDRs parses the networks allowed to reach the management API.
+func (c *config) parseMgmtAllowedCIDRs() error {
+	networks, err := parseCIDRs(c.MgmtAllowedCIDRs, "management allowed CIDR")
+	if err != nil {
+		return err
+	}
+	c.mgmtAllowed = networks
+	return nil
+}
+
+// parseCIDRs parses a list of networks; name describes them in errors.
+func parseCIDRs(cidrs []string, name string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", name, cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ipAllowMiddleware rejects requests from addresses outside MgmtAllowedCIDRs with 403.
+// It guards every route, including the health probes.
+func (app *Application) ipAllowMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+
+		app.configMutex.RLock()
+		allowed := ip != nil && slices.ContainsFunc(app.config.mgmtAllowed, func(n *net.IPNet) bool { return n.Contains(ip) })
+		app.configMutex.RUnlock()
+
+		if !allowed {
+			app.logger.Debug("Management API request from a disallowed address", "remote", r.RemoteAddr, "path", r.URL.Path)
+			writeError(w, http.StatusForbidden, "address not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireToken rejects requests without the configured shared secret.
+// Write endpoints are refused entirely when no token is configured.
+func (app *Application) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.configMutex.RLock()
+		token := app.config.MgmtToken
+		app.configMutex.RUnlock()
+
+		if token == "" {
+			writeError(w, http.StatusForbidden, "write endpoints are disabled: no management token configured")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(mgmtTokenHeader)), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid management token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleStatus reports the state of the managed tunnel.
+func (app *Application) handleStatus(w http.ResponseWriter, r *http.Request) {
+	app.configMutex.RLock()
+	status := tunnelStatus{
+		ID:            app.tunnelID(),
+		ProxyHost:     app.config.proxyHost,
+		ProxyHosts:    app.config.proxyHosts,
+		Remote:        app.config.SSHRemoteAddress,
+		ActiveRemote:  app.config.remoteAddress(),
+		ActivePort:    app.config.remotePort(),
+		State:         app.state().String(),
+		Paused:        app.paused.Load(),
+		LastRequestID: app.lastCheckRequestID(),
+		Stats:         app.Stats(),
+		Tags:          app.config.TunnelTags,
+		Forwards:      app.forwardStatuses(),
+	}
+	if app.config.AdaptiveLoop {
+		status.LoopInterval = app.loopSleep().Seconds()
+	}
+	app.configMutex.RUnlock()
+
+	app.sshMutex.RLock()
+	if app.isProcessRunning(app.sshProcess) {
+		status.Running = true
+		status.SSHPID = app.sshProcess.Process.Pid
+	}
+	app.sshMutex.RUnlock()
+
+	writeJSON(w, http.StatusOK, statusResponse{Tunnels: []tunnelStatus{status}})
+}
+
+// forwardStatuses lists the forwarding rules with the result of their last port check.
+// Callers hold configMutex.
+func (app *Application) forwardStatuses() []forwardStatus {
+	statuses := make([]forwardStatus, 0, len(app.config.Tunnels))
+	for _, tunnel := range app.config.Tunnels {
+		status := forwardStatus{
+			Rule:    strings.Join(tunnel.forwardOptions(), " "),
+			Address: tunnel.ProxyHost,
+			State:   forwardStateUnchecked,
+		}
+		if tunnel.ProxyHost != "" {
+			status.State = forwardStateUnknown
+			if up, ok := app.forwardStates.Load(tunnel.ProxyHost); ok {
+				status.State = forwardStateDown
+				if up.(bool) {
+					status.State = forwardStateUp
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// handleRestart queues a tunnel restart for the main loop.
+func (app *Application) handleRestart(w http.ResponseWriter, r *http.Request) {
+	app.configMutex.RLock()
+	id := app.tunnelID()
+	app.configMutex.RUnlock()
+
+	if r.PathValue("id") != id {
+		writeError(w, http.StatusNotFound, "unknown tunnel")
+		return
+	}
+
+	// A pending restart already covers this request
+	select {
+	case app.restartChan <- struct{}{}:
+	default:
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "restart queued"})
+}
+
+// handleGetConfig returns the active config with secrets masked.
+func (app *Application) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	app.configMutex.RLock()
+	cfg := *app.config
+	app.configMutex.RUnlock()
+
+	writeJSON(w, http.StatusOK, &cfg)
+}
+
+// handlePutConfig validates a config update and hands it to the main loop for reload.
+func (app *Application) handlePutConfig(w http.ResponseWriter, r *http.Request) {
+	var update configUpdate
+	if _, cfg, ok := app.decodeConfigUpdate(w, r, &update); ok {
+		app.queueReload(w, r, cfg)
+	}
+}
+
+// handlePutSSHOptions validates changed SSH options and hands them to the main loop,
+// which restarts the tunnel with them. Fields outside sshOptionsUpdate, such as the
+// remote address, are rejected: they belong in PUT /api/v1/config.
+func (app *Application) handlePutSSHOptions(w http.ResponseWriter, r *http.Request) {
+	var update sshOptionsUpdate
+	old, cfg, ok := app.decodeConfigUpdate(w, r, &update)
+	if !ok {
+		return
+	}
+	app.logger.Info("SSH options update requested", "changed", Diff(old, cfg))
+	app.queueReload(w, r, cfg)
+}
+
+// decodeConfigUpdate applies the update in the request body to a copy of the active
+// config and validates the result. It returns the copy before and after the update;
+// on failure it has already written the error response.
+func (app *Application) decodeConfigUpdate(w http.ResponseWriter, r *http.Request, update configApplier) (*config, *config, bool) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(update); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return nil, nil, false
+	}
+
+	app.configMutex.RLock()
+	old := *app.config
+	app.configMutex.RUnlock()
+
+	cfg := old
+	if err := update.apply(&cfg); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	if err := cfg.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid config: %v", err))
+		return nil, nil, false
+	}
+	return &old, &cfg, true
+}
+
+// queueReload hands a validated config to the main loop and returns it as the response.
+func (app *Application) queueReload(w http.ResponseWriter, r *http.Request, cfg *config) {
+	select {
+	case app.reloadChan <- cfg:
+	case <-r.Context().Done():
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, cfg)
+}
+
+// apply copies the non-nil update fields into cfg.
+func (u *configUpdate) apply(cfg *config) error {
+	if u.MainLoopSleep != nil {
+		d, err := time.ParseDuration(*u.MainLoopSleep)
+		if err != nil {
+			return fmt.Errorf("invalid main_loop_sleep_sec: %w", err)
+		}
+		cfg.MainLoopSleep = d
+	}
+	if u.PortCheckTimeout != nil {
+		d, err := time.ParseDuration(*u.PortCheckTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid port_check_timeout_sec: %w", err)
+		}
+		cfg.PortCheckTimeout = d
+	}
+	if u.RemoteAddress != nil {
+		cfg.SSHRemoteAddress = *u.RemoteAddress
+	}
+	if u.RemotePort != nil {
+		cfg.SSHRemotePort = *u.RemotePort
+	}
+	if u.SocksDNS != nil {
+		cfg.SSHSocksDNS = *u.SocksDNS
+	}
+	if u.TCPKeepAlive != nil {
+		cfg.SSHTCPKeepAlive = *u.TCPKeepAlive
+	}
+	if u.ServerAliveInterval != nil {
+		cfg.SSHServerAliveInterval = *u.ServerAliveInterval
+	}
+	if u.ConnectTimeout != nil {
+		cfg.SSHConnectTimeout = *u.ConnectTimeout
+	}
+	if u.StrictHostChecking != nil {
+		cfg.SSHStrictHostChecking = *u.StrictHostChecking
+	}
+	return nil
+}
+
+// apply copies the non-nil SSH options into cfg.
+func (u *sshOptionsUpdate) apply(cfg *config) error {
+	if u.TCPKeepAlive != nil {
+		cfg.SSHTCPKeepAlive = *u.TCPKeepAlive
+	}
+	if u.ServerAliveInterval != nil {
+		cfg.SSHServerAliveInterval = *u.ServerAliveInterval
+	}
+	if u.ConnectTimeout != nil {
+		cfg.SSHConnectTimeout = *u.ConnectTimeout
+	}
+	if u.ChannelTimeout != nil {
+		cfg.SSHChannelTimeout = *u.ChannelTimeout
+	}
+	if u.Compression != nil {
+		cfg.SSHCompression = *u.Compression
+	}
+	return nil
+}
+
+// writeJSON encodes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
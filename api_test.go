@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMgmtToken = "mgmt-token-value"
+
+// newTestMgmtServer starts the management API for a test app.
+func newTestMgmtServer(t *testing.T) (*Application, *httptest.Server) {
+	t.Helper()
+
+	app := newTestApp(t)
+	app.config.MgmtToken = testMgmtToken
+	app.logger = slog.New(slog.DiscardHandler)
+
+	srv := httptest.NewServer(app.mgmtHandler())
+	t.Cleanup(srv.Close)
+	return app, srv
+}
+
+// doRequest sends a request to the test server and returns the response.
+func doRequest(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set(mgmtTokenHeader, token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+// decodeBody decodes a JSON response body into v.
+func decodeBody(t *testing.T, resp *http.Response, v any) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+}
+
+func TestMgmtAPI_Status(t *testing.T) {
+	_, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/v1/status", "", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var body statusResponse
+	decodeBody(t, resp, &body)
+	if len(body.Tunnels) != 1 {
+		t.Fatalf("got %d tunnels, want 1", len(body.Tunnels))
+	}
+	if body.Tunnels[0].ID != "8080" || body.Tunnels[0].ProxyHost != "127.0.0.1:8080" {
+		t.Errorf("unexpected tunnel status: %+v", body.Tunnels[0])
+	}
+	if body.Tunnels[0].Running {
+		t.Error("tunnel should not be running")
+	}
+	if body.Tunnels[0].LastRequestID != "" {
+		t.Errorf("last_request_id = %q before any traffic check, want empty", body.Tunnels[0].LastRequestID)
+	}
+}
+
+func TestMgmtAPI_StatusLastRequestID(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	req, err := app.newTrafficCheckRequest()
+	if err != nil {
+		t.Fatalf("newTrafficCheckRequest() error: %v", err)
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/v1/status", "", "")
+	var body statusResponse
+	decodeBody(t, resp, &body)
+	if want := req.Header.Get(requestIDHeader); body.Tunnels[0].LastRequestID != want {
+		t.Errorf("last_request_id = %q, want %q", body.Tunnels[0].LastRequestID, want)
+	}
+}
+
+func TestMgmtAPI_GetConfigMasksToken(t *testing.T) {
+	_, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/v1/config", "", "")
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if strings.Contains(string(data), testMgmtToken) {
+		t.Errorf("config response leaks token: %s", data)
+	}
+	if !strings.Contains(string(data), `"mgmt_token":"***"`) {
+		t.Errorf("config response should mask token: %s", data)
+	}
+}
+
+func TestMgmtAPI_WriteRequiresToken(t *testing.T) {
+	_, srv := newTestMgmtServer(t)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "nope", http.StatusUnauthorized},
+		{"valid", testMgmtToken, http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doRequest(t, http.MethodPost, srv.URL+"/api/v1/tunnels/8080/restart", tt.token, "")
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestMgmtAPI_WriteDisabledWithoutToken(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	app.config.MgmtToken = ""
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/v1/tunnels/8080/restart", "anything", "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestMgmtAPI_RestartQueues(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/v1/tunnels/8080/restart", testMgmtToken, "")
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+
+	select {
+	case <-app.restartChan:
+	default:
+		t.Error("expected restart to be queued")
+	}
+}
+
+func TestMgmtAPI_RestartUnknownTunnel(t *testing.T) {
+	_, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodPost, srv.URL+"/api/v1/tunnels/9999/restart", testMgmtToken, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestMgmtAPI_PutConfig(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodPut, srv.URL+"/api/v1/config", testMgmtToken,
+		`{"main_loop_sleep_sec":"30s","socks_dns":"REMOTE"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+
+	select {
+	case cfg := <-app.reloadChan:
+		if cfg.MainLoopSleep != 30*time.Second {
+			t.Errorf("MainLoopSleep = %v, want 30s", cfg.MainLoopSleep)
+		}
+		if cfg.SSHSocksDNS != "remote" {
+			t.Errorf("SSHSocksDNS = %q, want %q", cfg.SSHSocksDNS, "remote")
+		}
+	default:
+		t.Fatal("expected reload to be queued")
+	}
+
+	if app.config.MainLoopSleep != 15*time.Second {
+		t.Error("active config must only change when the main loop applies the reload")
+	}
+}
+
+func TestMgmtAPI_PutConfigRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed", `{`},
+		{"immutable field", `{"bind_host":"127.0.0.1:9090"}`},
+		{"bad duration", `{"main_loop_sleep_sec":"soon"}`},
+		{"fails validation", `{"remote_port":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, srv := newTestMgmtServer(t)
+
+			resp := doRequest(t, http.MethodPut, srv.URL+"/api/v1/config", testMgmtToken, tt.body)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+			if len(app.reloadChan) != 0 {
+				t.Error("rejected update must not be queued")
+			}
+		})
+	}
+}
+
+func TestMgmtAPI_PutSSHOptions(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodPut, srv.URL+"/api/v1/ssh-options", testMgmtToken,
+		`{"server_alive_interval":30,"compression":false}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+
+	select {
+	case cfg := <-app.reloadChan:
+		if cfg.SSHServerAliveInterval != 30 || cfg.SSHCompression {
+			t.Errorf("ServerAliveInterval/Compression = %d/%v, want 30/false", cfg.SSHServerAliveInterval, cfg.SSHCompression)
+		}
+		if slices.Contains(cfg.serializeSSHOptions(), "-C") {
+			t.Error("-C should be left out with compression off")
+		}
+	default:
+		t.Fatal("expected reload to be queued")
+	}
+
+	if app.config.SSHServerAliveInterval != 15 {
+		t.Error("active config must only change when the main loop applies the reload")
+	}
+}
+
+func TestMgmtAPI_PutSSHOptionsRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"remote address", testMgmtToken, `{"remote_address":"other.example.com"}`, http.StatusBadRequest},
+		{"wrong type", testMgmtToken, `{"compression":"no"}`, http.StatusBadRequest},
+		{"fails validation", testMgmtToken, `{"connect_timeout":-1}`, http.StatusBadRequest},
+		{"no token", "", `{"server_alive_interval":30}`, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, srv := newTestMgmtServer(t)
+
+			resp := doRequest(t, http.MethodPut, srv.URL+"/api/v1/ssh-options", tt.token, tt.body)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if len(app.reloadChan) != 0 {
+				t.Error("rejected update must not be queued")
+			}
+		})
+	}
+}
+
+func TestMgmtAPI_IPAllowlist(t *testing.T) {
+	tests := []struct {
+		name   string
+		cidrs  []string
+		remote string
+		want   int
+	}{
+		{"loopback by default", []string{"127.0.0.0/8", "::1/128"}, "127.0.0.1:40000", http.StatusOK},
+		{"IPv6 loopback", []string{"127.0.0.0/8", "::1/128"}, "[::1]:40000", http.StatusOK},
+		{"IPv4-mapped loopback", []string{"127.0.0.0/8"}, "[::ffff:127.0.0.1]:40000", http.StatusOK},
+		{"outside the defaults", []string{"127.0.0.0/8", "::1/128"}, "192.0.2.10:40000", http.StatusForbidden},
+		{"allowed network", []string{"10.0.0.0/8", "192.0.2.0/24"}, "192.0.2.10:40000", http.StatusOK},
+		{"no networks", nil, "127.0.0.1:40000", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.config.MgmtAllowedCIDRs = tt.cidrs
+			if err := app.config.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			handler := app.mgmtHandler()
+
+			// Health probes are guarded as well as the API
+			for _, path := range []string{"/api/v1/status", "/startupz"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.RemoteAddr = tt.remote
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				got := rec.Code
+				if path == "/startupz" && got == http.StatusServiceUnavailable {
+					got = http.StatusOK // reached the handler; the tunnel never started
+				}
+				if got != tt.want {
+					t.Errorf("GET %s from %s: status = %d, want %d", path, tt.remote, rec.Code, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_MgmtAllowedCIDRs(t *testing.T) {
+	cfg := validConfig()
+	cfg.MgmtAllowedCIDRs = []string{"10.0.0.0/8", " fd00::/8"}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.mgmtAllowed) != 2 {
+		t.Errorf("parsed %d networks, want 2", len(cfg.mgmtAllowed))
+	}
+
+	for _, cidr := range []string{"10.0.0.1", "10.0.0.0/33", "localhost/8"} {
+		cfg.MgmtAllowedCIDRs = []string{"127.0.0.0/8", cidr}
+		if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), cidr) {
+			t.Errorf("CIDR %q: err=%v, want an error naming it", cidr, err)
+		}
+	}
+}
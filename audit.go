@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Audit event names written to the audit log.
+const (
+	auditTunnelStart    = "tunnel_start"
+	auditTunnelStop     = "tunnel_stop"
+	auditPIDConflict    = "pid_conflict"
+	auditSignalReceived = "signal_received"
+	auditConfigReload   = "config_reload"
+)
+
+// sequenceHandler stamps every record with a monotonic sequence number so gaps
+// or reordering in the audit log can be detected.
+type sequenceHandler struct {
+	handler slog.Handler
+	state   *sequenceState
+}
+
+// sequenceState is shared between handlers derived via WithAttrs/WithGroup.
+type sequenceState struct {
+	mu  sync.Mutex
+	seq uint64
+}
+
+func (h *sequenceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *sequenceHandler) Handle(ctx context.Context, r slog.Record) error {
+	// Hold the lock across the write so sequence order matches file order.
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	h.state.seq++
+	r.AddAttrs(slog.Uint64("sequence", h.state.seq))
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *sequenceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sequenceHandler{handler: h.handler.WithAttrs(attrs), state: h.state}
+}
+
+func (h *sequenceHandler) WithGroup(name string) slog.Handler {
+	return &sequenceHandler{handler: h.handler.WithGroup(name), state: h.state}
+}
+
+// createAuditLogger opens the append-only audit log.
+// Returns a nil logger when auditing is disabled.
+func (app *Application) createAuditLogger() (*slog.Logger, error) {
+	if app.config.AuditLogFile == "" {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(filepath.Clean(app.config.AuditLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	app.auditFile = file
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	handler := &sequenceHandler{
+		handler: slog.NewJSONHandler(file, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		state:   &sequenceState{},
+	}
+
+	return slog.New(handler).With("hostname", hostname, "pid", os.Getpid()), nil
+}
+
+// audit writes a security-relevant event to the audit log, if enabled.
+func (app *Application) audit(event string, args ...any) {
+	if app.auditLogger == nil {
+		return
+	}
+	app.auditLogger.Info(event, args...)
+}
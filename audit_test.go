@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readAuditRecords parses every JSON line in the audit log.
+func readAuditRecords(t *testing.T, path string) []map[string]any {
+	t.Helper()
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid audit record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	return records
+}
+
+func TestCreateAuditLogger_Disabled(t *testing.T) {
+	app := newTestApp(t)
+
+	logger, err := app.createAuditLogger()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if logger != nil {
+		t.Error("expected nil logger when audit log is disabled")
+	}
+
+	// Must not panic without a logger
+	app.audit(auditTunnelStart)
+}
+
+func TestAudit_RecordFields(t *testing.T) {
+	app := newTestApp(t)
+	app.config.AuditLogFile = filepath.Join(t.TempDir(), "audit.log")
+
+	logger, err := app.createAuditLogger()
+	if err != nil {
+		t.Fatalf("createAuditLogger: %v", err)
+	}
+	app.auditLogger = logger
+	defer func() { _ = app.auditFile.Close() }()
+
+	app.audit(auditTunnelStart, "ssh_pid", 42)
+	app.audit(auditTunnelStop, "ssh_pid", 42)
+
+	records := readAuditRecords(t, app.config.AuditLogFile)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	for i, rec := range records {
+		if _, ok := rec["hostname"]; !ok {
+			t.Errorf("record %d missing hostname", i)
+		}
+		if pid, _ := rec["pid"].(float64); int(pid) != os.Getpid() {
+			t.Errorf("record %d pid = %v, want %d", i, rec["pid"], os.Getpid())
+		}
+		if seq, _ := rec["sequence"].(float64); int(seq) != i+1 {
+			t.Errorf("record %d sequence = %v, want %d", i, rec["sequence"], i+1)
+		}
+	}
+	if records[0]["msg"] != auditTunnelStart || records[1]["msg"] != auditTunnelStop {
+		t.Errorf("unexpected events: %v, %v", records[0]["msg"], records[1]["msg"])
+	}
+}
+
+func TestAudit_PIDConflict(t *testing.T) {
+	app := newTestApp(t)
+	app.config.AuditLogFile = filepath.Join(t.TempDir(), "audit.log")
+
+	logger, err := app.createAuditLogger()
+	if err != nil {
+		t.Fatalf("createAuditLogger: %v", err)
+	}
+	app.auditLogger = logger
+	defer func() { _ = app.auditFile.Close() }()
+
+	if err := app.createPIDFile(); err != nil {
+		t.Fatalf("first createPIDFile: %v", err)
+	}
+	if err := app.createPIDFile(); err == nil {
+		t.Fatal("expected error for duplicate instance")
+	}
+
+	records := readAuditRecords(t, app.config.AuditLogFile)
+	if len(records) != 1 || records[0]["msg"] != auditPIDConflict {
+		t.Errorf("got records %v, want single %s event", records, auditPIDConflict)
+	}
+}
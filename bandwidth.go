@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// errBandwidthUnsupported is returned on platforms without per-process I/O counters.
+var errBandwidthUnsupported = errors.New("bandwidth monitoring is not supported on this platform")
+
+// bandwidthPollInterval is how often SSH process I/O counters are sampled.
+const bandwidthPollInterval = 10 * time.Second
+
+// runBandwidthMonitor samples the SSH process I/O counters until shutdown.
+// Counters belong to the current SSH process, so they reset on every restart.
+func (app *Application) runBandwidthMonitor() {
+	ticker := time.NewTicker(bandwidthPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.shutdownChan:
+			return
+		case <-ticker.C:
+			if err := app.sampleBandwidth(); err != nil {
+				app.componentLogger(componentSSH).Warn("Bandwidth monitoring stopped", "error", err)
+				return
+			}
+		}
+	}
+}
+
+// sampleBandwidth updates bytesIn/bytesOut from the running SSH process.
+// Only unsupported platforms return an error; a missing process just resets the counters.
+func (app *Application) sampleBandwidth() error {
+	app.sshMutex.RLock()
+	pid := 0
+	if app.isProcessRunning(app.sshProcess) {
+		pid = app.sshProcess.Process.Pid
+	}
+	app.sshMutex.RUnlock()
+
+	if pid == 0 {
+		app.bytesIn.Store(0)
+		app.bytesOut.Store(0)
+		return nil
+	}
+
+	in, out, err := processIOBytes(pid)
+	if errors.Is(err, errBandwidthUnsupported) {
+		return err
+	}
+	if err != nil {
+		app.componentLogger(componentSSH).Debug("Failed to read SSH process I/O", "pid", pid, "error", err)
+		return nil
+	}
+
+	app.bytesIn.Store(in)
+	app.bytesOut.Store(out)
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// processIOBytes returns the bytes read and written by a process.
+// /proc/net/tcp carries no byte counters, so the SSH process's /proc/<pid>/io
+// totals (rchar/wchar) are used as the tunnel traffic estimate.
+func processIOBytes(pid int) (int64, int64, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseProcIO(content)
+}
+
+// parseProcIO extracts rchar and wchar from /proc/<pid>/io content.
+func parseProcIO(content []byte) (int64, int64, error) {
+	var in, out int64
+	var foundIn, foundOut bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s value: %w", key, err)
+		}
+
+		switch string(key) {
+		case "rchar":
+			in, foundIn = n, true
+		case "wchar":
+			out, foundOut = n, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if !foundIn || !foundOut {
+		return 0, 0, errors.New("rchar/wchar not found")
+	}
+	return in, out, nil
+}
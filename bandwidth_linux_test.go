@@ -0,0 +1,89 @@
+//go:build linux
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProcIO(t *testing.T) {
+	content := []byte("rchar: 3980\nwchar: 120\nsyscr: 8\nsyscw: 1\nread_bytes: 0\nwrite_bytes: 0\n")
+
+	in, out, err := parseProcIO(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if in != 3980 || out != 120 {
+		t.Errorf("got in=%d out=%d, want in=3980 out=120", in, out)
+	}
+}
+
+func TestParseProcIO_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"missing wchar", "rchar: 10\n"},
+		{"non-numeric", "rchar: ten\nwchar: 1\n"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseProcIO([]byte(tt.content)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestProcessIOBytes_Self(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, make([]byte, 4096), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := os.ReadFile(filepath.Clean(path)); err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	in, out, err := processIOBytes(os.Getpid())
+	if err != nil {
+		t.Fatalf("processIOBytes: %v", err)
+	}
+	if in < 4096 || out < 4096 {
+		t.Errorf("got in=%d out=%d, want both >= 4096", in, out)
+	}
+}
+
+func TestSampleBandwidth_RunningProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start process: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	app := &Application{logger: slog.New(slog.DiscardHandler), sshProcess: cmd}
+	if err := app.sampleBandwidth(); err != nil {
+		t.Fatalf("sampleBandwidth: %v", err)
+	}
+}
+
+func TestSampleBandwidth_NoProcessResets(t *testing.T) {
+	app := &Application{logger: slog.New(slog.DiscardHandler)}
+	app.bytesIn.Store(100)
+	app.bytesOut.Store(100)
+
+	if err := app.sampleBandwidth(); err != nil {
+		t.Fatalf("sampleBandwidth: %v", err)
+	}
+	if stats := app.Stats(); stats.BytesIn != 0 || stats.BytesOut != 0 {
+		t.Errorf("counters not reset: %+v", stats)
+	}
+}
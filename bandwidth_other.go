@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// processIOBytes is unavailable outside Linux; macOS and Windows don't expose
+// per-process I/O counters without cgo.
+func processIOBytes(pid int) (int64, int64, error) {
+	return 0, 0, errBandwidthUnsupported
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=abc123 -X main.buildTime=2024-01-02T15:04:05Z"
+//
+// Empty values fall back to the module and VCS information embedded by the Go toolchain.
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// buildDetails describes the running binary.
+type buildDetails struct {
+	Version   string
+	Commit    string
+	BuildTime string
+	GoVersion string
+	OS        string
+	Arch      string
+}
+
+// buildInfo is resolved once at startup from the ldflags values and embedded build info.
+var buildInfo = readBuildDetails()
+
+// readBuildDetails merges the ldflags values with debug.ReadBuildInfo.
+func readBuildDetails() buildDetails {
+	details := buildDetails{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if details.Version == "" {
+			details.Version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && details.Commit == "":
+				details.Commit = setting.Value
+			case setting.Key == "vcs.time" && details.BuildTime == "":
+				details.BuildTime = setting.Value
+			}
+		}
+	}
+
+	if details.Version == "" {
+		details.Version = "(devel)"
+	}
+	return details
+}
+
+// logStartupBanner logs the build and a summary of the effective configuration.
+func (app *Application) logStartupBanner() {
+	app.logger.Info("Starting SSH tunnel application",
+		"version", buildInfo.Version,
+		"commit", buildInfo.Commit,
+		"build_time", buildInfo.BuildTime,
+		"go_version", buildInfo.GoVersion,
+		"os", buildInfo.OS,
+		"arch", buildInfo.Arch,
+		"tunnel_mode", app.config.TunnelMode,
+		"proxy_host", app.config.proxyHost,
+		"proxy_ports", app.config.proxyPorts(),
+		"remote", app.config.SSHRemoteAddress,
+		"main_loop_sleep", app.config.MainLoopSleep,
+		"config", app.config.String(),
+	)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestReadBuildInfo(t *testing.T) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info == nil {
+		t.Fatal("debug.ReadBuildInfo returned no data")
+	}
+}
+
+func TestReadBuildDetails(t *testing.T) {
+	details := readBuildDetails()
+	if details.Version == "" {
+		t.Error("Version should never be empty")
+	}
+	if details.GoVersion != runtime.Version() || details.OS != runtime.GOOS || details.Arch != runtime.GOARCH {
+		t.Errorf("unexpected runtime details: %+v", details)
+	}
+}
+
+func TestReadBuildDetails_LDFlagsTakePrecedence(t *testing.T) {
+	originalVersion, originalCommit, originalBuildTime := version, commit, buildTime
+	version, commit, buildTime = "v1.2.3", "abc123", "2024-01-02T15:04:05Z"
+	t.Cleanup(func() { version, commit, buildTime = originalVersion, originalCommit, originalBuildTime })
+
+	details := readBuildDetails()
+	if details.Version != "v1.2.3" || details.Commit != "abc123" || details.BuildTime != "2024-01-02T15:04:05Z" {
+		t.Errorf("ldflags values not used: %+v", details)
+	}
+}
+
+func TestLogStartupBanner(t *testing.T) {
+	app := newTestApp(t)
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	app.config.MgmtToken = "mgmt-token-value"
+
+	app.logStartupBanner()
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid log record: %v", err)
+	}
+	for _, key := range []string{"version", "go_version", "os", "arch", "tunnel_mode", "proxy_host", "remote", "main_loop_sleep", "config"} {
+		if _, ok := record[key]; !ok {
+			t.Errorf("banner is missing %q", key)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("mgmt-token-value")) {
+		t.Error("banner leaks the management token")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// loadCertificate reads and parses an OpenSSH certificate file (e.g. id_ed25519-cert.pub).
+func loadCertificate(path string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is a plain %s public key, not a certificate", path, key.Type())
+	}
+	return cert, nil
+}
+
+// privateKeyPublicKey returns the public key of a PEM private key. Encrypted keys are
+// not decrypted: the public key comes from the unencrypted part of the OpenSSH key format.
+func privateKeyPublicKey(data []byte) (ssh.PublicKey, error) {
+	signer, err := ssh.ParsePrivateKey(data)
+	if err == nil {
+		return signer.PublicKey(), nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return missing.PublicKey, nil
+	}
+	return nil, err
+}
+
+// identityPublicKey returns the public key of a private key file, falling back to
+// the adjacent .pub file for encrypted keys in other formats.
+func identityPublicKey(path string) (ssh.PublicKey, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	key, err := privateKeyPublicKey(data)
+	if err == nil {
+		return key, nil
+	}
+
+	pubData, pubErr := os.ReadFile(path + ".pub") //nolint:gosec // derived from operator configuration
+	if pubErr != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+	key, _, _, _, err = ssh.ParseAuthorizedKey(pubData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s.pub: %w", path, err)
+	}
+	return key, nil
+}
+
+// validateCertificate checks that the certificate file parses and, with an identity,
+// that it certifies that identity's key.
+func (c *config) validateCertificate() error {
+	cert, err := loadCertificate(c.SSHCertificateFile)
+	if err != nil {
+		return err
+	}
+
+	var key ssh.PublicKey
+	var source string
+	switch {
+	case c.identityKey != nil:
+		source = "$" + c.SSHIdentityEnvVar
+		if key, err = privateKeyPublicKey(c.identityKey); err != nil {
+			return fmt.Errorf("failed to parse identity from %s: %w", source, err)
+		}
+	case c.SSHIdentityFile != "":
+		source = c.SSHIdentityFile
+		if key, err = identityPublicKey(c.SSHIdentityFile); err != nil {
+			return err
+		}
+	}
+	if key != nil && !bytes.Equal(cert.Key.Marshal(), key.Marshal()) {
+		return fmt.Errorf("certificate %s was issued for %s, not for the key in %s (%s)",
+			c.SSHCertificateFile, ssh.FingerprintSHA256(cert.Key), source, ssh.FingerprintSHA256(key))
+	}
+
+	c.certificate = cert
+	return nil
+}
+
+// logCertificate reports the configured certificate's validity and principals,
+// warning when it is not valid at the moment.
+func (app *Application) logCertificate() {
+	cert := app.config.certificate
+	if cert == nil {
+		return
+	}
+
+	validAfter := time.Unix(int64(cert.ValidAfter), 0).UTC() //nolint:gosec // bounded by the cert format
+	validBefore := "forever"
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		validBefore = time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339) //nolint:gosec // bounded by the cert format
+	}
+	args := []any{
+		"file", app.config.SSHCertificateFile,
+		"key_id", cert.KeyId,
+		"principals", cert.ValidPrincipals,
+		"valid_after", validAfter.Format(time.RFC3339),
+		"valid_before", validBefore,
+	}
+
+	now := uint64(time.Now().Unix()) //nolint:gosec // current time is positive
+	switch {
+	case now < cert.ValidAfter:
+		app.logger.Warn("SSH certificate is not valid yet", args...)
+	case cert.ValidBefore != ssh.CertTimeInfinity && now >= cert.ValidBefore:
+		app.logger.Warn("SSH certificate has expired", args...)
+	default:
+		app.logger.Info("Using SSH certificate", args...)
+	}
+}
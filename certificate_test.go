@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testKeyPair writes an ed25519 private key to dir/name, optionally encrypted,
+// and returns its path and public key.
+func testKeyPair(t *testing.T, dir, name, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert key: %v", err)
+	}
+	return path, sshPub
+}
+
+// testCertificate signs key with a fresh CA for the given validity and writes it next to the key.
+func testCertificate(t *testing.T, dir string, key ssh.PublicKey, validAfter, validBefore uint64) string {
+	t.Helper()
+
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA signer: %v", err)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        ssh.UserCert,
+		KeyId:           "tunnel@example.com",
+		ValidPrincipals: []string{"tunnel", "deploy"},
+		ValidAfter:      validAfter,
+		ValidBefore:     validBefore,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("failed to sign certificate: %v", err)
+	}
+
+	path := filepath.Join(dir, "id_ed25519-cert.pub")
+	if err := os.WriteFile(path, ssh.MarshalAuthorizedKey(cert), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	return path
+}
+
+func TestValidate_Certificate(t *testing.T) {
+	dir := t.TempDir()
+	identity, pub := testKeyPair(t, dir, "id_ed25519", "")
+	certFile := testCertificate(t, dir, pub, 0, ssh.CertTimeInfinity)
+	otherIdentity, _ := testKeyPair(t, dir, "id_other", "")
+
+	plainKey := filepath.Join(dir, "id_ed25519.pub")
+	if err := os.WriteFile(plainKey, ssh.MarshalAuthorizedKey(pub), 0o600); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		identity string
+		cert     string
+		ok       bool
+	}{
+		{"certificate only", "", certFile, true},
+		{"matching identity", identity, certFile, true},
+		{"other identity", otherIdentity, certFile, false},
+		{"plain public key", "", plainKey, false},
+		{"missing certificate", "", filepath.Join(dir, "missing-cert.pub"), false},
+		{"missing identity", filepath.Join(dir, "missing"), certFile, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.SSHIdentityFile = tt.identity
+			cfg.SSHCertificateFile = tt.cert
+			err := cfg.validate()
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if tt.ok && cfg.certificate == nil {
+				t.Error("certificate should be kept after validation")
+			}
+		})
+	}
+}
+
+func TestValidate_CertificateEncryptedIdentity(t *testing.T) {
+	dir := t.TempDir()
+	identity, pub := testKeyPair(t, dir, "id_ed25519", "secret")
+	certFile := testCertificate(t, dir, pub, 0, ssh.CertTimeInfinity)
+
+	cfg := validConfig()
+	cfg.SSHIdentityFile = identity
+	cfg.SSHCertificateFile = certFile
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("encrypted identity should be matched by its public key: %v", err)
+	}
+}
+
+func TestSerializeSSHOptions_Certificate(t *testing.T) {
+	dir := t.TempDir()
+	identity, pub := testKeyPair(t, dir, "id_ed25519", "")
+	certFile := testCertificate(t, dir, pub, 0, ssh.CertTimeInfinity)
+
+	cfg := validConfig()
+	cfg.SSHIdentityFile = identity
+	cfg.SSHCertificateFile = certFile
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	joined := strings.Join(cfg.serializeSSHOptions(), " ")
+	if !strings.Contains(joined, "-i "+identity) {
+		t.Errorf("missing -i %s: %s", identity, joined)
+	}
+	if !strings.Contains(joined, "-o CertificateFile="+certFile) {
+		t.Errorf("missing CertificateFile=%s: %s", certFile, joined)
+	}
+}
+
+func TestLogCertificate(t *testing.T) {
+	now := uint64(time.Now().Unix()) //nolint:gosec // current time is positive
+
+	tests := []struct {
+		name        string
+		validAfter  uint64
+		validBefore uint64
+		level       string
+		msg         string
+	}{
+		{"valid", now - 60, now + 3600, "INFO", "Using SSH certificate"},
+		{"no expiry", 0, ssh.CertTimeInfinity, "INFO", "Using SSH certificate"},
+		{"expired", now - 7200, now - 3600, "WARN", "SSH certificate has expired"},
+		{"not yet valid", now + 3600, now + 7200, "WARN", "SSH certificate is not valid yet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			_, pub := testKeyPair(t, dir, "id_ed25519", "")
+
+			app := newTestApp(t)
+			app.config.SSHCertificateFile = testCertificate(t, dir, pub, tt.validAfter, tt.validBefore)
+			if err := app.config.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			var buf bytes.Buffer
+			app.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+			app.logCertificate()
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid log output %q: %v", buf.String(), err)
+			}
+			if record["level"] != tt.level || record["msg"] != tt.msg {
+				t.Errorf("logged %v %q, want %s %q", record["level"], record["msg"], tt.level, tt.msg)
+			}
+			principals, _ := record["principals"].([]any)
+			if len(principals) != 2 || principals[0] != "tunnel" {
+				t.Errorf("principals = %v, want [tunnel deploy]", record["principals"])
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errPinnedCertMismatch is returned by the TLS handshake when the traffic check
+// endpoint presents a certificate that is not pinned.
+var errPinnedCertMismatch = errors.New("server certificate does not match TRAFFIC_CHECK_PINNED_CERT")
+
+// loadPinnedCerts returns the SHA-256 fingerprints of the DER-encoded certificates
+// in a PEM file. Several certificates can be pinned, e.g. the current and the next one.
+func loadPinnedCerts(path string) ([][sha256.Size]byte, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned certificate: %w", err)
+	}
+
+	var pins [][sha256.Size]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse pinned certificate %s: %w", path, err)
+		}
+		pins = append(pins, sha256.Sum256(block.Bytes))
+	}
+	if len(pins) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+	return pins, nil
+}
+
+// verifyPinnedCert accepts a handshake only if the server's leaf certificate is pinned.
+func verifyPinnedCert(pins [][sha256.Size]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errPinnedCertMismatch
+		}
+		fingerprint := sha256.Sum256(rawCerts[0])
+		for _, pin := range pins {
+			if fingerprint == pin {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: got SHA256 %x", errPinnedCertMismatch, fingerprint)
+	}
+}
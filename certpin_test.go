@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEMCerts writes DER certificates as a PEM file and returns its path.
+func writePEMCerts(t *testing.T, certs ...[]byte) string {
+	t.Helper()
+
+	var data []byte
+	for _, der := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	path := filepath.Join(t.TempDir(), "pinned.pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write pinned certificate: %v", err)
+	}
+	return path
+}
+
+// otherCert returns a self-signed DER certificate unrelated to the httptest one.
+func otherCert(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "other.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func TestPinnedTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	serverCert := server.Certificate().Raw
+
+	tests := []struct {
+		name  string
+		certs [][]byte
+		ok    bool
+	}{
+		{"pinned", [][]byte{serverCert}, true},
+		{"pinned among others", [][]byte{otherCert(t), serverCert}, true},
+		{"not pinned", [][]byte{otherCert(t)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.TrafficCheckPinnedCert = writePEMCerts(t, tt.certs...)
+			if err := cfg.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			tlsConfig := cfg.trafficCheckTLSConfig()
+			// Trust the test CA so that only the pin decides
+			tlsConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+			if (err == nil) != tt.ok {
+				t.Fatalf("GET error = %v, want ok=%v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, errPinnedCertMismatch) {
+				t.Errorf("error = %v, want errPinnedCertMismatch", err)
+			}
+		})
+	}
+}
+
+func TestTrafficCheckTLSConfig_Unpinned(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if cfg.trafficCheckTLSConfig().VerifyPeerCertificate != nil {
+		t.Error("VerifyPeerCertificate should be unset without a pinned certificate")
+	}
+}
+
+func TestValidate_TrafficCheckPinnedCert(t *testing.T) {
+	notCert := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(notCert, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")}), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	garbage := writePEMCerts(t, []byte("not DER"))
+
+	tests := []struct {
+		name string
+		path string
+		ok   bool
+	}{
+		{"certificate", writePEMCerts(t, otherCert(t)), true},
+		{"missing file", filepath.Join(t.TempDir(), "missing.pem"), false},
+		{"no certificate", notCert, false},
+		{"invalid certificate", garbage, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.TrafficCheckPinnedCert = tt.path
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
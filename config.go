@@ -1,49 +1,289 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/caarlos0/env/v11"
+	"golang.org/x/crypto/ssh"
 )
 
+// defaultLogFile is the LOG_FILE default; it gets a port suffix and is ignored for console output.
+const defaultLogFile = "ssh-tunnel.log"
+
+// defaultPIDFile is the PID_FILE default; it gets a port suffix.
+const defaultPIDFile = "ssh-tunnel.pid"
+
+// sshConfigNone is the SSH_CONFIG_FILE value that makes ssh read no config file at all.
+const sshConfigNone = "none"
+
 // config holds all application settings parsed from SSH_TUNNEL_* environment variables.
+// Fields tagged sensitive:"true" are masked whenever the config is serialized.
 type config struct {
 	// Main config
-	MainLoopSleep    time.Duration `env:"MAIN_LOOP_SLEEP_SEC" envDefault:"15s"`
-	PortCheckTimeout time.Duration `env:"PORT_CHECK_TIMEOUT_SEC" envDefault:"4s"`
-	PIDFile          string        `env:"PID_FILE" envDefault:"ssh-tunnel.pid"`
-	LogFile          string        `env:"LOG_FILE" envDefault:"ssh-tunnel.log"`
-	LogStdout        bool          `env:"LOG_STDOUT" envDefault:"false"`
+	MainLoopSleep                time.Duration `env:"MAIN_LOOP_SLEEP_SEC" envDefault:"15s"`
+	MainLoopJitter               time.Duration `env:"MAIN_LOOP_JITTER" envDefault:"0s"`
+	TickTimeout                  time.Duration `env:"TICK_TIMEOUT" envDefault:"0"`
+	AdaptiveLoop                 bool          `env:"ADAPTIVE_LOOP" envDefault:"false"`
+	MinLoopSleep                 time.Duration `env:"MIN_LOOP_SLEEP" envDefault:"5s"`
+	MaxLoopSleep                 time.Duration `env:"MAX_LOOP_SLEEP" envDefault:"60s"`
+	RTTMultiplier                float64       `env:"RTT_MULTIPLIER" envDefault:"3.0"`
+	PortCheckTimeout             time.Duration `env:"PORT_CHECK_TIMEOUT_SEC" envDefault:"4s"`
+	TunnelStartTimeout           time.Duration `env:"TUNNEL_START_TIMEOUT" envDefault:"30s"`
+	SSHLoginTimeout              time.Duration `env:"LOGIN_TIMEOUT" envDefault:"30s"`
+	SSHTunnelReadyTimeout        time.Duration `env:"TUNNEL_READY_TIMEOUT" envDefault:"5s"`
+	PreflightCheck               bool          `env:"PREFLIGHT_CHECK" envDefault:"false"`
+	PreflightCheckTimeout        time.Duration `env:"PREFLIGHT_CHECK_TIMEOUT" envDefault:"5s"`
+	ReconnectJitter              time.Duration `env:"RECONNECT_JITTER" envDefault:"5s"`
+	MaxRestartsPerHour           int           `env:"MAX_RESTARTS_PER_HOUR" envDefault:"20"`
+	RetryBudgetCapacity          int           `env:"RETRY_BUDGET_CAPACITY" envDefault:"20"`
+	RetryBudgetRefillRate        float64       `env:"RETRY_BUDGET_REFILL_RATE" envDefault:"1.0"`
+	MaxTunnelIdleTime            time.Duration `env:"MAX_TUNNEL_IDLE_TIME" envDefault:"0"`
+	OverlapRestart               bool          `env:"OVERLAP_RESTART" envDefault:"false"`
+	StartupDelay                 time.Duration `env:"STARTUP_DELAY" envDefault:"0s"`
+	StartupProbeInterval         time.Duration `env:"STARTUP_PROBE_INTERVAL" envDefault:"2s"`
+	StartupProbeMaxDuration      time.Duration `env:"STARTUP_PROBE_MAX_DURATION" envDefault:"60s"`
+	StartupProbeFailureThreshold int           `env:"STARTUP_PROBE_FAILURE_THRESHOLD" envDefault:"30"`
+	InstanceName                 string        `env:"INSTANCE_NAME"`
+	PIDFile                      string        `env:"PID_FILE" envDefault:"ssh-tunnel.pid"`
+	LogFile                      string        `env:"LOG_FILE" envDefault:"ssh-tunnel.log"`
+	LogStdout                    bool          `env:"LOG_STDOUT" envDefault:"false"`
+	LogOutput                    string        `env:"LOG_OUTPUT" envDefault:"file"`
+	LogFormat                    string        `env:"LOG_FORMAT" envDefault:"json"`
+	LogLevel                     string        `env:"LOG_LEVEL" envDefault:"debug"`
+	LogLevelSSH                  string        `env:"LOG_LEVEL_SSH"`
+	LogLevelHealthCheck          string        `env:"LOG_LEVEL_HEALTH_CHECK"`
+	LogLevelTunnel               string        `env:"LOG_LEVEL_TUNNEL"`
+	LogFieldMessage              string        `env:"LOG_FIELD_MESSAGE" envDefault:"msg"`
+	LogFieldTime                 string        `env:"LOG_FIELD_TIME" envDefault:"time"`
+	LogFieldLevel                string        `env:"LOG_FIELD_LEVEL" envDefault:"level"`
+	LogFieldError                string        `env:"LOG_FIELD_ERROR" envDefault:"error"`
+	SyslogPriority               string        `env:"SYSLOG_PRIORITY" envDefault:"LOG_DAEMON|LOG_INFO"`
+	AuditLogFile                 string        `env:"AUDIT_LOG_FILE"`
+	SessionLogFile               string        `env:"SESSION_LOG_FILE"`
+	DryRun                       bool          `env:"DRY_RUN" envDefault:"false"`
+	SelfTest                     bool          `env:"SELF_TEST" envDefault:"false"`
+	SelfTestTimeout              time.Duration `env:"SELF_TEST_TIMEOUT" envDefault:"60s"`
+	BandwidthMonitor             bool          `env:"BANDWIDTH_MONITOR" envDefault:"false"`
+	MonitorResources             bool          `env:"MONITOR_RESOURCES" envDefault:"false"`
+	ConfigFile                   string        `env:"CONFIG_FILE"`
+	WatchConfig                  bool          `env:"WATCH_CONFIG" envDefault:"false"`
+	PolicyFile                   string        `env:"POLICY_FILE"`
+	SIGUSR1Action                string        `env:"SIGUSR1_ACTION"`
 
 	// SSH Options
-	SSHTCPKeepAlive        bool   `env:"TCP_KEEPALIVE" envDefault:"true"`
-	SSHServerAliveInterval int    `env:"SERVER_ALIVE_INTERVAL" envDefault:"15"`
-	SSHConnectTimeout      int    `env:"CONNECT_TIMEOUT" envDefault:"10"`
-	SSHStrictHostChecking  bool   `env:"STRICT_HOST_CHECKING" envDefault:"false"`
-	SSHBindHost            string `env:"BIND_HOST" envDefault:"127.0.0.1:8080"`
-	SSHRemoteAddress       string `env:"REMOTE_ADDRESS,required"`
-	SSHRemotePort          int    `env:"REMOTE_PORT" envDefault:"2212"`
-	SSHSocksDNS            string `env:"SOCKS_DNS" envDefault:"local"`
+	TunnelMode             string   `env:"TUNNEL_MODE" envDefault:"dynamic"`
+	SSHTCPKeepAlive        bool     `env:"TCP_KEEPALIVE" envDefault:"true"`
+	SSHServerAliveInterval int      `env:"SERVER_ALIVE_INTERVAL" envDefault:"15"`
+	SSHConnectTimeout      int      `env:"CONNECT_TIMEOUT" envDefault:"10"`
+	SSHChannelTimeout      int      `env:"CHANNEL_TIMEOUT" envDefault:"0"`
+	SSHCompression         bool     `env:"COMPRESSION" envDefault:"true"`
+	SSHStrictHostChecking  bool     `env:"STRICT_HOST_CHECKING" envDefault:"false"`
+	SSHAutoKnownHosts      bool     `env:"AUTO_KNOWN_HOSTS" envDefault:"false"`
+	SSHHashKnownHosts      bool     `env:"HASH_KNOWN_HOSTS" envDefault:"false"`
+	SSHCheckHostIP         bool     `env:"CHECK_HOST_IP" envDefault:"true"`
+	SSHVerifyHostKeyDNS    string   `env:"VERIFY_HOST_KEY_DNS" envDefault:"no"`
+	HostKeyScan            bool     `env:"HOST_KEY_SCAN" envDefault:"false"`
+	RejectOnKeyChange      bool     `env:"REJECT_ON_KEY_CHANGE" envDefault:"false"`
+	SSHSuppressBanner      bool     `env:"SUPPRESS_BANNER" envDefault:"true"`
+	SSHLogLevel            string   `env:"SSH_LOG_LEVEL"`
+	SSHBindHost            string   `env:"BIND_HOST" envDefault:"127.0.0.1:8080"`
+	SSHBindHosts           []string `env:"BIND_HOSTS" envSeparator:","`
+	SSHAutoSelectPort      bool     `env:"AUTO_SELECT_PORT" envDefault:"false"`
+	SSHAutoSelectPortRange int      `env:"AUTO_SELECT_PORT_RANGE" envDefault:"10"`
+	SSHRemoteAddress       string   `env:"REMOTE_ADDRESS,required"`
+	SSHRemotePort          int      `env:"REMOTE_PORT" envDefault:"2212"`
+	FallbackRemoteAddress  string   `env:"FALLBACK_REMOTE_ADDRESS"`
+	FallbackRemotePort     int      `env:"FALLBACK_REMOTE_PORT" envDefault:"0"`
+	SSHRemotePortStrict    bool     `env:"REMOTE_PORT_STRICT" envDefault:"false"`
+	SSHAllowedRemotePorts  []int    `env:"ALLOWED_REMOTE_PORTS" envSeparator:","`
+	SSHResolveOnRestart    bool     `env:"RESOLVE_ON_RESTART" envDefault:"false"`
+	SSHPreferIPv4          bool     `env:"PREFER_IPV4" envDefault:"true"`
+	SSHIPVersion           string   `env:"IP_VERSION"`
+	SSHEscapeChar          string   `env:"ESCAPE_CHAR" envDefault:"none"`
+	SSHNoTTY               bool     `env:"NO_TTY" envDefault:"true"`
+	SSHSocksDNS            string   `env:"SOCKS_DNS" envDefault:"local"`
+	SSHHTTPProxy           string   `env:"SSH_HTTP_PROXY" sensitive:"true"`
+	SSHProxyCommand        string   `env:"PROXY_COMMAND" sensitive:"true"`
+	SSHBindSourceIP        string   `env:"BIND_SOURCE_IP"`
+	SSHBindInterface       string   `env:"BIND_INTERFACE"`
+	Netns                  string   `env:"NETNS"`
+	SSHPKCS11Provider      string   `env:"PKCS11_PROVIDER"`
+	SSHIdentityFile        string   `env:"IDENTITY_FILE" sensitive:"true"`
+	SSHIdentityEnvVar      string   `env:"IDENTITY_ENV_VAR"`
+	SSHCertificateFile     string   `env:"CERTIFICATE_FILE"`
+	SSHConfigFile          string   `env:"SSH_CONFIG_FILE"`
+	SSHControlMaster       bool     `env:"CONTROL_MASTER" envDefault:"false"`
+	SSHControlSocketDir    string   `env:"CONTROL_SOCKET_DIR" envDefault:"/tmp"`
+	SSHControlPersist      string   `env:"CONTROL_PERSIST" envDefault:"60"`
+	ZombieDetection        bool     `env:"ZOMBIE_DETECTION" envDefault:"false"`
+
+	// Interval of "ssh -O check" against the control master, 0 disables it
+	SSHControlMasterAliveInterval time.Duration `env:"CONTROL_MASTER_ALIVE_INTERVAL" envDefault:"0"`
+
+	// Remote port forwarding with TUNNEL_MODE=remote, host:port each
+	SSHRemoteForwardRemoteAddr string `env:"REMOTE_FORWARD_REMOTE_ADDR"`
+	SSHRemoteForwardLocalAddr  string `env:"REMOTE_FORWARD_LOCAL_ADDR"`
+	SSHGatewayPorts            bool   `env:"GATEWAY_PORTS" envDefault:"false"`
+
+	// Several -R rules instead of the addresses above, and a URL served through one of them
+	SSHRemoteForwards        []string `env:"REMOTE_FORWARDS" envSeparator:","`
+	SSHRemoteForwardCheckURL string   `env:"REMOTE_FORWARD_CHECK_URL"`
+
+	// Local port forwarding
+	SSHLocalForwards []string `env:"LOCAL_FORWARDS" envSeparator:","`
+
+	// ssh exits when a -R or -L forward can't be set up, so the tunnel is restarted
+	SSHExitOnForwardFailure bool `env:"EXIT_ON_FORWARD_FAILURE" envDefault:"true"`
+
+	// Forwarding rules of the SSH process, derived from the settings above by deriveProxyHost
+	Tunnels []TunnelConfig `env:"-"`
+
+	// Source address filter in front of the SOCKS port, CIDRs; ssh then listens on loopback only
+	AllowHosts       []string `env:"ALLOW_HOSTS" envSeparator:","`
+	SSHBindLocalOnly bool     `env:"BIND_LOCAL_ONLY" envDefault:"false"`
+
+	// Look for a live ssh-agent, or start one, when SSH_AUTH_SOCK is stale
+	SSHAgentSocketAutoRefresh bool `env:"AGENT_SOCKET_AUTO_REFRESH" envDefault:"false"`
+	SSHSpawnAgent             bool `env:"SPAWN_AGENT" envDefault:"false"`
+
+	// Agent use of the SSH session: forwarding to the server, adding loaded keys
+	SSHForwardAgent   bool   `env:"FORWARD_AGENT" envDefault:"false"`
+	SSHAddKeysToAgent string `env:"ADD_KEYS_TO_AGENT" envDefault:"no"`
+
+	// Command ssh runs locally once connected to the server
+	SSHPermitLocalCommand bool   `env:"PERMIT_LOCAL_COMMAND" envDefault:"false"`
+	SSHLocalCommand       string `env:"LOCAL_COMMAND"`
+
+	// Restrict key exchange, ciphers and MACs to post-quantum safe algorithms
+	SSHPostQuantumOnly bool `env:"POST_QUANTUM_ONLY" envDefault:"false"`
+
+	// Filtering of ssh's stderr, regexp patterns
+	SSHOutputFilter         []string `env:"SSH_OUTPUT_FILTER" envSeparator:","`
+	SSHOutputPromotePattern string   `env:"SSH_OUTPUT_PROMOTE_PATTERN"`
+
+	// Hook commands run via "sh -c"
+	OnStartCommand string `env:"ON_START_COMMAND"`
+	OnStopCommand  string `env:"ON_STOP_COMMAND"`
+
+	// Environment file for dependent services, written once the tunnel is ready
+	PublishEnvFile   string `env:"PUBLISH_ENV_FILE"`
+	PublishEnvFormat string `env:"PUBLISH_ENV_FORMAT"`
+
+	// HTTP transport used for traffic checks
+	HTTPMaxIdleConns             int           `env:"HTTP_MAX_IDLE_CONNS" envDefault:"100"`
+	HTTPMaxConnsPerHost          int           `env:"HTTP_MAX_CONNS_PER_HOST" envDefault:"0"`
+	HTTPIdleConnTimeout          time.Duration `env:"HTTP_IDLE_CONN_TIMEOUT" envDefault:"90s"`
+	HTTPTLSHandshakeTimeout      time.Duration `env:"HTTP_TLS_HANDSHAKE_TIMEOUT" envDefault:"10s"`
+	HTTPExpectContinueTimeout    time.Duration `env:"HTTP_EXPECT_CONTINUE_TIMEOUT" envDefault:"1s"`
+	HTTPDisableKeepAlives        bool          `env:"HTTP_DISABLE_KEEPALIVES" envDefault:"false"`
+	TLSMinVersion                string        `env:"TLS_MIN_VERSION" envDefault:"TLS12"`
+	TLSMaxVersion                string        `env:"TLS_MAX_VERSION" envDefault:""`
+	TCPKeepAliveInterval         time.Duration `env:"TCP_KEEPALIVE_INTERVAL" envDefault:"30s"`
+	TCPKeepAliveCount            int           `env:"TCP_KEEPALIVE_COUNT" envDefault:"3"`
+	TrafficCheckDNSServer        string        `env:"TRAFFIC_CHECK_DNS_SERVER"`
+	TrafficCheckMode             string        `env:"TRAFFIC_CHECK_MODE" envDefault:"http"`
+	TrafficCheckSocks5Target     string        `env:"TRAFFIC_CHECK_SOCKS5_TARGET" envDefault:"8.8.8.8:443"`
+	TrafficCheckPinnedCert       string        `env:"TRAFFIC_CHECK_PINNED_CERT"`
+	TrafficCheckHTTP2            bool          `env:"TRAFFIC_CHECK_HTTP2" envDefault:"false"`
+	TrafficCheckFailureThreshold int           `env:"TRAFFIC_CHECK_FAILURE_THRESHOLD" envDefault:"3"`
+	TrafficCheckRetries          int           `env:"TRAFFIC_CHECK_RETRIES" envDefault:"1"`
+	TrafficCheckRetryDelay       time.Duration `env:"TRAFFIC_CHECK_RETRY_DELAY" envDefault:"2s"`
+	RestartPolicy                string        `env:"RESTART_POLICY" envDefault:"on-failure"`
+	HealthWindowSize             int           `env:"HEALTH_WINDOW_SIZE" envDefault:"10"`
+	HealthRestartThreshold       float64       `env:"HEALTH_RESTART_THRESHOLD" envDefault:"0.5"`
+	QualityRestartThreshold      float64       `env:"QUALITY_RESTART_THRESHOLD" envDefault:"10.0"`
+
+	// Management API
+	MgmtAddr         string   `env:"MGMT_ADDR"`
+	MgmtToken        string   `env:"MGMT_TOKEN" sensitive:"true"`
+	MgmtBindAll      bool     `env:"MGMT_BIND_ALL" envDefault:"false"`
+	MgmtAllowedCIDRs []string `env:"MGMT_ALLOWED_CIDRS" envSeparator:"," envDefault:"127.0.0.0/8,::1/128"`
+
+	// Go runtime profiling via net/http/pprof
+	PprofAddr        string `env:"PPROF_ADDR"`
+	PprofAllowRemote bool   `env:"PPROF_ALLOW_REMOTE" envDefault:"false"`
+
+	// Webhook notifications
+	WebhookURL    string `env:"WEBHOOK_URL" sensitive:"true"`
+	WebhookSecret string `env:"WEBHOOK_SECRET" sensitive:"true"`
+
+	// Labels of the tunnel in logs, webhook events and the status, key=value pairs
+	TunnelTags map[string]string `env:"TUNNEL_TAGS" envSeparator:"," envKeyValSeparator:"="`
 
 	// Derived values (not from env)
-	proxyHost string
-	proxyPort string
+	proxyHost      string           // primary proxy address, used for traffic checks
+	proxyPort      string           // port of the configured primary binding; identifies the instance
+	proxyHosts     []string         // every proxy address, proxyHost first
+	forwards       []forwardRule    // parsed -L rules in local mode, -R rules in remote mode
+	activeBindHost string           // binding replacing the configured ones after an overlap restart, or behind the access filter
+	selectedFrom   string           // configured bind host replaced by an auto-selected port
+	sshVersion     openSSHVersion   // ssh release, detected only when it changes the arguments
+	certificate    *ssh.Certificate // parsed SSHCertificateFile
+	resolvedHost   string           // server address chosen at the last start with SSHResolveOnRestart
+	useFallback    bool             // the primary was unreachable at the last start, see selectRemote
+
+	pinnedCerts [][sha256.Size]byte // fingerprints from TrafficCheckPinnedCert, see verifyPinnedCert
+	mgmtAllowed []*net.IPNet        // parsed MgmtAllowedCIDRs, see ipAllowMiddleware
+	allowedNets []*net.IPNet        // parsed AllowHosts, see serveAccessFilter
+	envPrefix   string              // prefix of the variables the config was read from, see --env-prefix
+	stateDir    string              // directory of the default PID and log files, see stateDirFor
+
+	// Identity from SSHIdentityEnvVar
+	identityKey     []byte // decoded private key
+	identityKeyFile string // temp file holding identityKey for ssh -i, see writeIdentityKey
+}
+
+// defaultEnvPrefix prefixes every variable unless --env-prefix says otherwise.
+const defaultEnvPrefix = "SSH_TUNNEL_"
+
+// envPrefixPattern accepts uppercase environment variable prefixes ending in "_".
+var envPrefixPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*_$`)
+
+// newConfig parses the variables starting with envPrefix and returns a validated config.
+func newConfig(envPrefix string) (*config, error) {
+	if !envPrefixPattern.MatchString(envPrefix) {
+		return nil, fmt.Errorf("invalid environment variable prefix %q: must be uppercase and end with \"_\"", envPrefix)
+	}
+	return loadConfig(os.Getenv(envPrefix+"CONFIG_FILE"), envPrefix)
 }
 
-// newConfig parses environment variables and returns a validated config.
-func newConfig() (*config, error) {
+// loadConfig parses the environment overlaid with the variables from configFile, if set,
+// all named with envPrefix. Values from the file take precedence so that editing it can
+// change a running instance.
+func loadConfig(configFile, envPrefix string) (*config, error) {
+	environment := env.ToMap(os.Environ())
+	if configFile != "" {
+		fileVars, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(environment, fileVars)
+	}
+
 	var cfg config
 	opts := env.Options{
-		Prefix: "SSH_TUNNEL_",
+		Prefix:      envPrefix,
+		Environment: environment,
 	}
 
 	if err := env.ParseWithOptions(&cfg, opts); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	cfg.envPrefix = envPrefix
 
 	if err := cfg.validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
@@ -54,6 +294,10 @@ func newConfig() (*config, error) {
 
 // validate checks config values and populates derived fields.
 func (c *config) validate() error {
+	if err := c.validateTunnelMode(); err != nil {
+		return err
+	}
+
 	if err := c.deriveProxyHost(); err != nil {
 		return err
 	}
@@ -62,14 +306,224 @@ func (c *config) validate() error {
 		return fmt.Errorf("invalid remote port: %d", c.SSHRemotePort)
 	}
 
+	if c.FallbackRemotePort < 0 || c.FallbackRemotePort > 65535 {
+		return fmt.Errorf("invalid fallback remote port: %d", c.FallbackRemotePort)
+	}
+
+	if err := c.validateRemoteAddress(); err != nil {
+		return err
+	}
+
+	if err := c.validateRemotePortPolicy(); err != nil {
+		return err
+	}
+
+	if err := c.validateIPVersion(); err != nil {
+		return err
+	}
+
+	if !validEscapeChar(c.SSHEscapeChar) {
+		return fmt.Errorf("invalid escape char %q: must be none, a single character or ^ followed by one", c.SSHEscapeChar)
+	}
+
 	if c.MainLoopSleep <= 0 {
 		return fmt.Errorf("main loop sleep must be positive")
 	}
 
+	// The name ends up in file names
+	if c.InstanceName != "" && !instanceNamePattern.MatchString(c.InstanceName) {
+		return fmt.Errorf("invalid instance name %q: must be letters, digits, \"_\" and \"-\"", c.InstanceName)
+	}
+
+	if c.MainLoopJitter < 0 {
+		return fmt.Errorf("main loop jitter must not be negative")
+	}
+
+	if c.MainLoopJitter >= c.MainLoopSleep {
+		return fmt.Errorf("main loop jitter (%s) must be less than main loop sleep (%s)", c.MainLoopJitter, c.MainLoopSleep)
+	}
+
+	if c.TickTimeout < 0 {
+		return fmt.Errorf("tick timeout must not be negative")
+	}
+
+	if c.AdaptiveLoop {
+		if c.MinLoopSleep <= 0 || c.MaxLoopSleep < c.MinLoopSleep {
+			return fmt.Errorf("min loop sleep must be positive and not above max loop sleep (%s)", c.MaxLoopSleep)
+		}
+		if c.RTTMultiplier <= 0 {
+			return fmt.Errorf("RTT multiplier must be positive")
+		}
+		if c.MainLoopJitter >= c.MinLoopSleep {
+			return fmt.Errorf("main loop jitter (%s) must be less than min loop sleep (%s)", c.MainLoopJitter, c.MinLoopSleep)
+		}
+	}
+
+	if c.SelfTestTimeout <= 0 {
+		return fmt.Errorf("self-test timeout must be positive")
+	}
+
+	if c.ReconnectJitter < 0 {
+		return fmt.Errorf("reconnect jitter must not be negative")
+	}
+
+	if c.MaxRestartsPerHour < 0 {
+		return fmt.Errorf("max restarts per hour must not be negative")
+	}
+
+	if c.RetryBudgetCapacity < 0 {
+		return fmt.Errorf("retry budget capacity must not be negative")
+	}
+	if c.RetryBudgetCapacity > 0 && c.RetryBudgetRefillRate <= 0 {
+		return fmt.Errorf("retry budget refill rate must be positive")
+	}
+
+	if c.MaxTunnelIdleTime < 0 {
+		return fmt.Errorf("max tunnel idle time must not be negative")
+	}
+
+	if c.StartupDelay < 0 || c.StartupProbeMaxDuration < 0 {
+		return fmt.Errorf("startup delay and probe duration must not be negative")
+	}
+
+	if c.TrafficCheckFailureThreshold < 0 || c.StartupProbeFailureThreshold < 0 {
+		return fmt.Errorf("probe failure thresholds must not be negative")
+	}
+
+	if c.TrafficCheckRetries < 0 || c.TrafficCheckRetryDelay < 0 {
+		return fmt.Errorf("traffic check retries and retry delay must not be negative")
+	}
+
+	if c.StartupProbeMaxDuration > 0 && c.StartupProbeInterval <= 0 {
+		return fmt.Errorf("startup probe interval must be positive")
+	}
+
 	if c.PortCheckTimeout <= 0 {
 		return fmt.Errorf("port check timeout must be positive")
 	}
 
+	switch strings.ToLower(c.LogOutput) {
+	case "", "file":
+		c.LogOutput = "file"
+	case "stdout", "stderr":
+		c.LogOutput = strings.ToLower(c.LogOutput)
+	case "syslog":
+		c.LogOutput = "syslog"
+		if err := validateSyslogPriority(c.SyslogPriority); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid log output: %s", c.LogOutput)
+	}
+
+	switch strings.ToLower(c.LogFormat) {
+	case "", "json":
+		c.LogFormat = "json"
+	case "text", "logfmt":
+		c.LogFormat = strings.ToLower(c.LogFormat)
+	default:
+		return fmt.Errorf("invalid log format: %s", c.LogFormat)
+	}
+
+	if c.LogLevel == "" {
+		c.LogLevel = "debug"
+	}
+	for _, level := range []string{c.LogLevel, c.LogLevelSSH, c.LogLevelHealthCheck, c.LogLevelTunnel} {
+		if level == "" {
+			continue
+		}
+		if _, err := parseLogLevel(level); err != nil {
+			return err
+		}
+	}
+	if err := c.validateLogFieldNames(); err != nil {
+		return err
+	}
+
+	if c.TunnelStartTimeout <= 0 {
+		return fmt.Errorf("tunnel start timeout must be positive")
+	}
+
+	if c.SSHLoginTimeout < 0 {
+		return fmt.Errorf("login timeout must not be negative")
+	}
+
+	if c.SSHTunnelReadyTimeout <= 0 {
+		return fmt.Errorf("tunnel ready timeout must be positive")
+	}
+
+	if c.SSHConnectTimeout < 0 || c.SSHChannelTimeout < 0 {
+		return fmt.Errorf("SSH connect and channel timeouts must not be negative")
+	}
+
+	if (c.PreflightCheck || c.FallbackRemoteAddress != "") && c.PreflightCheckTimeout <= 0 {
+		return fmt.Errorf("preflight check timeout must be positive")
+	}
+
+	if c.HTTPMaxIdleConns < 0 || c.HTTPMaxConnsPerHost < 0 {
+		return fmt.Errorf("HTTP connection limits must not be negative")
+	}
+
+	if c.HTTPIdleConnTimeout < 0 || c.HTTPTLSHandshakeTimeout < 0 || c.HTTPExpectContinueTimeout < 0 {
+		return fmt.Errorf("HTTP transport timeouts must not be negative")
+	}
+
+	if err := c.validateTLSVersions(); err != nil {
+		return err
+	}
+
+	if c.TCPKeepAliveInterval < 0 || c.TCPKeepAliveCount < 0 {
+		return fmt.Errorf("TCP keepalive interval and count must not be negative")
+	}
+
+	if c.TrafficCheckDNSServer != "" {
+		host, port, err := net.SplitHostPort(c.TrafficCheckDNSServer)
+		if err != nil {
+			return fmt.Errorf("invalid traffic check DNS server: %w", err)
+		}
+		if portNum, err := strconv.Atoi(port); host == "" || err != nil || portNum <= 0 || portNum > 65535 {
+			return fmt.Errorf("invalid traffic check DNS server: %s", c.TrafficCheckDNSServer)
+		}
+	}
+
+	switch strings.ToLower(c.TrafficCheckMode) {
+	case "", trafficCheckHTTP:
+		c.TrafficCheckMode = trafficCheckHTTP
+	case trafficCheckSocks5Connect:
+		c.TrafficCheckMode = trafficCheckSocks5Connect
+		host, port, err := net.SplitHostPort(c.TrafficCheckSocks5Target)
+		if err != nil {
+			return fmt.Errorf("invalid traffic check SOCKS5 target: %w", err)
+		}
+		if portNum, err := strconv.Atoi(port); host == "" || err != nil || portNum <= 0 || portNum > 65535 {
+			return fmt.Errorf("invalid traffic check SOCKS5 target: %s", c.TrafficCheckSocks5Target)
+		}
+	default:
+		return fmt.Errorf("invalid traffic check mode: %s", c.TrafficCheckMode)
+	}
+
+	// The failure threshold and the health score are alternative ways to judge the tunnel
+	switch strings.ToLower(c.RestartPolicy) {
+	case "", restartPolicyOnFailure:
+		c.RestartPolicy = restartPolicyOnFailure
+	case restartPolicyHealthScore:
+		c.RestartPolicy = restartPolicyHealthScore
+	default:
+		return fmt.Errorf("invalid restart policy: %s", c.RestartPolicy)
+	}
+
+	if c.HealthWindowSize < 1 {
+		return fmt.Errorf("health window size must be at least 1")
+	}
+
+	if c.HealthRestartThreshold <= 0 || c.HealthRestartThreshold > 1 {
+		return fmt.Errorf("health restart threshold must be in (0, 1], got %g", c.HealthRestartThreshold)
+	}
+
+	if c.QualityRestartThreshold < 0 || c.QualityRestartThreshold > 100 {
+		return fmt.Errorf("quality restart threshold must be in [0, 100], got %g", c.QualityRestartThreshold)
+	}
+
 	switch strings.ToLower(c.SSHSocksDNS) {
 	case "", "local":
 		c.SSHSocksDNS = "local"
@@ -79,74 +533,612 @@ func (c *config) validate() error {
 		return fmt.Errorf("invalid SOCKS DNS mode: %s", c.SSHSocksDNS)
 	}
 
+	if c.WatchConfig && c.ConfigFile == "" {
+		return fmt.Errorf("config watching requires SSH_TUNNEL_CONFIG_FILE")
+	}
+
+	switch c.SIGUSR1Action {
+	case "":
+	case sigusr1ActionPause:
+		if len(pauseSignals()) == 0 {
+			return fmt.Errorf("SIGUSR1 action %q is not supported on this platform", c.SIGUSR1Action)
+		}
+	default:
+		return fmt.Errorf("invalid SIGUSR1 action: %s", c.SIGUSR1Action)
+	}
+
+	if err := c.validateAccessFilter(); err != nil {
+		return err
+	}
+
+	if c.OverlapRestart && (len(c.SSHBindHosts) > 1 || c.SSHControlMaster) {
+		return fmt.Errorf("overlap restart requires a single bind host and no control master")
+	}
+
+	if c.SSHAutoSelectPort {
+		if len(c.SSHBindHosts) > 0 {
+			return fmt.Errorf("auto port selection requires SSH_TUNNEL_BIND_HOST instead of SSH_TUNNEL_BIND_HOSTS")
+		}
+		if c.SSHAutoSelectPortRange < 1 {
+			return fmt.Errorf("auto select port range must be at least 1")
+		}
+	}
+
+	if c.SSHControlMaster {
+		if err := c.validateControlPath(); err != nil {
+			return err
+		}
+		if err := validateControlPersist(c.SSHControlPersist); err != nil {
+			return err
+		}
+	}
+	if c.SSHControlMasterAliveInterval < 0 {
+		return fmt.Errorf("control master alive interval must not be negative")
+	}
+	if c.SSHControlMasterAliveInterval > 0 && !c.SSHControlMaster {
+		return fmt.Errorf("control master alive interval requires CONTROL_MASTER")
+	}
+
+	if err := validateAddKeysToAgent(c.SSHAddKeysToAgent); err != nil {
+		return err
+	}
+
+	switch c.SSHVerifyHostKeyDNS {
+	case "yes", "no", "ask":
+	default:
+		return fmt.Errorf("invalid verify host key DNS %q: must be yes, no or ask", c.SSHVerifyHostKeyDNS)
+	}
+
+	switch c.PublishEnvFormat {
+	case "", publishEnvFormatShell, publishEnvFormatSystemd:
+	default:
+		return fmt.Errorf("invalid publish env format %q: must be shell or systemd", c.PublishEnvFormat)
+	}
+
+	if err := c.normalizeMgmtAddr(); err != nil {
+		return err
+	}
+
+	if err := c.normalizePprofAddr(); err != nil {
+		return err
+	}
+
+	if err := c.parseMgmtAllowedCIDRs(); err != nil {
+		return err
+	}
+
+	if c.SSHHTTPProxy != "" {
+		u, err := url.Parse(c.SSHHTTPProxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("invalid SSH HTTP proxy")
+		}
+		if u.User != nil {
+			return fmt.Errorf("invalid SSH HTTP proxy: credentials are not supported")
+		}
+		if c.SSHProxyCommand != "" {
+			return fmt.Errorf("SSH HTTP proxy and proxy command cannot be combined")
+		}
+	}
+
+	if c.SSHLogLevel != "" {
+		level := strings.ToUpper(c.SSHLogLevel)
+		if !slices.Contains(sshLogLevels, level) {
+			return fmt.Errorf("invalid SSH log level %q: must be one of %s", c.SSHLogLevel, strings.Join(sshLogLevels, ", "))
+		}
+		c.SSHLogLevel = level
+	}
+
+	if c.SSHBindSourceIP != "" && net.ParseIP(c.SSHBindSourceIP) == nil {
+		return fmt.Errorf("invalid bind source IP: %s", c.SSHBindSourceIP)
+	}
+
+	if c.SSHBindInterface != "" && !isValidInterfaceName(c.SSHBindInterface) {
+		return fmt.Errorf("invalid bind interface: %s", c.SSHBindInterface)
+	}
+	if err := validateNetns(c.Netns); err != nil {
+		return err
+	}
+
+	if c.SSHPKCS11Provider != "" {
+		if err := c.validatePKCS11Provider(); err != nil {
+			return err
+		}
+	}
+
+	// Only options that depend on the release pay for running ssh -V
+	c.sshVersion = openSSHVersion{}
+	if c.SSHPKCS11Provider != "" || c.SSHChannelTimeout > 0 || c.SSHPostQuantumOnly {
+		c.detectVersion()
+	}
+
+	if c.SSHIdentityFile != "" {
+		if _, err := os.Stat(c.SSHIdentityFile); err != nil {
+			return fmt.Errorf("invalid identity file: %w", err)
+		}
+	}
+
+	if c.SSHConfigFile != "" && c.SSHConfigFile != sshConfigNone {
+		if _, err := os.Stat(c.SSHConfigFile); err != nil {
+			return fmt.Errorf("invalid SSH config file: %w", err)
+		}
+	}
+
+	c.identityKey = nil
+	if c.SSHIdentityEnvVar != "" {
+		key, err := decodeIdentityEnv(c.SSHIdentityEnvVar)
+		if err != nil {
+			return err
+		}
+		c.identityKey = key
+	}
+
+	c.certificate = nil
+	if c.SSHCertificateFile != "" {
+		if err := c.validateCertificate(); err != nil {
+			return err
+		}
+	}
+
+	c.pinnedCerts = nil
+	if c.TrafficCheckPinnedCert != "" {
+		pins, err := loadPinnedCerts(c.TrafficCheckPinnedCert)
+		if err != nil {
+			return err
+		}
+		c.pinnedCerts = pins
+	}
+
+	if c.RejectOnKeyChange && !c.HostKeyScan {
+		return fmt.Errorf("rejecting changed host keys requires SSH_TUNNEL_HOST_KEY_SCAN")
+	}
+
+	// ssh-keyscan connects directly and cannot go through the proxy
+	if c.HostKeyScan && c.SSHHTTPProxy != "" {
+		return fmt.Errorf("host key scan cannot be combined with an SSH HTTP proxy")
+	}
+	if c.HostKeyScan && c.SSHProxyCommand != "" {
+		return fmt.Errorf("host key scan cannot be combined with a proxy command")
+	}
+
+	// ssh makes no connection of its own through a ProxyCommand, so there is nothing to bind
+	if c.SSHHTTPProxy != "" && (c.SSHBindSourceIP != "" || c.SSHBindInterface != "") {
+		return fmt.Errorf("bind source IP and bind interface cannot be combined with an SSH HTTP proxy")
+	}
+	if c.SSHProxyCommand != "" && (c.SSHBindSourceIP != "" || c.SSHBindInterface != "") {
+		return fmt.Errorf("bind source IP and bind interface cannot be combined with a proxy command")
+	}
+
+	// The preflight check dials the server, which may only be reachable through the command
+	if c.PreflightCheck && c.SSHProxyCommand != "" {
+		return fmt.Errorf("preflight check cannot be combined with a proxy command")
+	}
+
+	// ssh ignores LocalCommand unless PermitLocalCommand is set
+	if c.SSHLocalCommand != "" && !c.SSHPermitLocalCommand {
+		return fmt.Errorf("local command requires PERMIT_LOCAL_COMMAND")
+	}
+
+	// Choosing the fallback takes a direct connection to the primary, see selectRemote
+	if c.FallbackRemoteAddress != "" && (c.SSHHTTPProxy != "" || c.SSHProxyCommand != "") {
+		return fmt.Errorf("fallback remote address cannot be combined with an SSH HTTP proxy or proxy command")
+	}
+
+	// The fallback server has keys of its own, which would count as a change
+	if c.FallbackRemoteAddress != "" && c.RejectOnKeyChange {
+		return fmt.Errorf("fallback remote address cannot be combined with rejecting changed host keys")
+	}
+
+	if c.WebhookURL != "" {
+		u, err := url.Parse(c.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL")
+		}
+	}
+
+	if c.SSHRemoteForwardCheckURL != "" {
+		if c.TunnelMode != tunnelModeRemote {
+			return fmt.Errorf("remote forward check URL requires the remote tunnel mode")
+		}
+		u, err := url.Parse(c.SSHRemoteForwardCheckURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid remote forward check URL: %s", c.SSHRemoteForwardCheckURL)
+		}
+	}
+
+	if err := c.validateTunnelTags(); err != nil {
+		return err
+	}
+
+	return c.checkPolicy()
+}
+
+// normalizeMgmtAddr restricts the management API to loopback unless MgmtBindAll is set.
+// An empty host is bound to 127.0.0.1 rather than all interfaces.
+func (c *config) normalizeMgmtAddr() error {
+	addr, err := loopbackAddr(c.MgmtAddr, c.MgmtBindAll, "management address", "MGMT_BIND_ALL")
+	if err != nil {
+		return err
+	}
+	c.MgmtAddr = addr
 	return nil
 }
 
-// deriveProxyHost parses SSHBindHost into proxyHost/proxyPort, normalizing wildcard addresses to loopback.
-func (c *config) deriveProxyHost() error {
-	host, port, err := net.SplitHostPort(c.SSHBindHost)
+// normalizePprofAddr restricts the profiling server to loopback unless PprofAllowRemote is set.
+func (c *config) normalizePprofAddr() error {
+	addr, err := loopbackAddr(c.PprofAddr, c.PprofAllowRemote, "profiling address", "PPROF_ALLOW_REMOTE")
 	if err != nil {
-		return fmt.Errorf("invalid bind host: %w", err)
+		return err
+	}
+	c.PprofAddr = addr
+	return nil
+}
+
+// loopbackAddr checks that the listen address addr is on loopback, unless allowRemote,
+// and binds an empty host to 127.0.0.1. name and override describe addr and the
+// setting lifting the restriction in errors. An empty addr is returned as is.
+func loopbackAddr(addr string, allowRemote bool, name, override string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", name, err)
 	}
 
-	portNum, err := strconv.Atoi(port)
-	if err != nil || portNum <= 0 || portNum > 65535 {
-		return fmt.Errorf("invalid bind host port: %s", port)
+	if allowRemote {
+		return addr, nil
 	}
 
-	switch host {
-	case "", "0.0.0.0":
+	switch {
+	case host == "":
 		host = "127.0.0.1"
-	case "::":
-		host = "::1"
+	case host == "localhost":
+	default:
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return "", fmt.Errorf("%s %s is not loopback; set %s=true to allow it", name, addr, override)
+		}
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// validateRemoteAddress checks SSHRemoteAddress and FallbackRemoteAddress, see checkRemote.
+func (c *config) validateRemoteAddress() error {
+	if err := c.checkRemote(c.SSHRemoteAddress, c.SSHRemotePort, "SSH_TUNNEL_REMOTE_PORT"); err != nil {
+		return err
+	}
+	if c.FallbackRemoteAddress == "" {
+		return nil
+	}
+	if err := c.checkRemote(c.FallbackRemoteAddress, c.fallbackRemotePort(), "SSH_TUNNEL_FALLBACK_REMOTE_PORT"); err != nil {
+		return fmt.Errorf("fallback: %w", err)
+	}
+	return nil
+}
+
+// checkRemote checks address is "[user@]host" where host is an IP or DNS name, and that
+// it doesn't point SSH back at its own SOCKS listener. portVar names the port setting.
+func (c *config) checkRemote(address string, port int, portVar string) error {
+	if strings.HasPrefix(address, "@") {
+		return fmt.Errorf("invalid remote address %q: empty user", address)
+	}
+	host := addressHost(address)
+
+	ip := net.ParseIP(host)
+	switch {
+	case host == "":
+		return fmt.Errorf("invalid remote address %q: empty host", address)
+	case ip != nil:
+	case strings.Contains(host, ":"):
+		return fmt.Errorf("invalid remote address %q: host must not include a port, use %s instead", address, portVar)
+	case !isValidHostname(host):
+		return fmt.Errorf("invalid remote address %q: %q is not a valid hostname or IP", address, host)
+	}
+
+	isLoopback := host == "localhost" || (ip != nil && ip.IsLoopback())
+	if isLoopback && slices.Contains(strings.Split(c.proxyPorts(), ","), strconv.Itoa(port)) {
+		return fmt.Errorf("remote port %d on %s overlaps a local proxy port", port, host)
+	}
+
+	return nil
+}
+
+// defaultHTTPProxyPort is used when SSHHTTPProxy has no port.
+const defaultHTTPProxyPort = "3128"
+
+// httpProxyAddr returns the host:port of SSHHTTPProxy, or "" if unset.
+func (c *config) httpProxyAddr() string {
+	if c.SSHHTTPProxy == "" {
+		return ""
+	}
+	u, err := url.Parse(c.SSHHTTPProxy)
+	if err != nil {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultHTTPProxyPort
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// proxyCommand returns SSHProxyCommand with its ${REMOTE_ADDRESS}, ${REMOTE_PORT},
+// ${PROXY_HOST} and ${IDENTITY_FILE} placeholders replaced by the config values.
+// ssh's own tokens such as %h and %p are left for ssh to expand.
+func (c *config) proxyCommand() string {
+	return strings.NewReplacer(
+		"${REMOTE_ADDRESS}", c.remoteAddress(),
+		"${REMOTE_PORT}", strconv.Itoa(c.remotePort()),
+		"${PROXY_HOST}", c.proxyHost,
+		"${IDENTITY_FILE}", c.identityFile(),
+	).Replace(c.SSHProxyCommand)
+}
+
+// remoteEndpoint returns the host:port of the SSH server.
+func (c *config) remoteEndpoint() string {
+	return net.JoinHostPort(c.remoteHost(), strconv.Itoa(c.remotePort()))
+}
+
+// remoteHost returns the SSH server without the optional "user@" prefix.
+func (c *config) remoteHost() string {
+	return addressHost(c.remoteAddress())
+}
+
+// remoteAddress returns the "[user@]host" ssh connects to: FallbackRemoteAddress
+// while the fallback is in use, otherwise SSHRemoteAddress.
+func (c *config) remoteAddress() string {
+	if c.useFallback {
+		return c.FallbackRemoteAddress
+	}
+	return c.SSHRemoteAddress
+}
+
+// remotePort returns the port of remoteAddress.
+func (c *config) remotePort() int {
+	if c.useFallback {
+		return c.fallbackRemotePort()
+	}
+	return c.SSHRemotePort
+}
+
+// fallbackRemotePort returns FallbackRemotePort, defaulting to SSHRemotePort.
+func (c *config) fallbackRemotePort() int {
+	if c.FallbackRemotePort == 0 {
+		return c.SSHRemotePort
+	}
+	return c.FallbackRemotePort
+}
+
+// addressHost returns address without the optional "user@" prefix.
+func addressHost(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return address[at+1:]
+	}
+	return address
+}
+
+// isValidInterfaceName reports whether name can be a network interface name
+// (at most 15 bytes as on Linux, no whitespace or path separators).
+func isValidInterfaceName(name string) bool {
+	if len(name) > 15 || name == "." || name == ".." {
+		return false
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r == '/' || r == ':' || unicode.IsSpace(r)
+	})
+}
+
+// validEscapeChar reports whether ssh accepts value for -e: "none", a single
+// character or "^" followed by one for a control character. Empty leaves ssh's "~".
+func validEscapeChar(value string) bool {
+	switch {
+	case value == "" || value == "none":
+		return true
+	case len(value) == 2 && value[0] == '^':
+		value = value[1:]
+	case len(value) != 1:
+		return false
+	}
+	return value[0] > ' ' && value[0] < 0x7f
+}
+
+// isValidHostname reports whether host is a syntactically valid DNS name.
+func isValidHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for label := range strings.SplitSeq(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, ch := range label {
+			isAlnum := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+			if !isAlnum && ch != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bindHosts returns the -D bindings: the overlap restart binding if any,
+// then SSHBindHosts if set, otherwise SSHBindHost. Remote and local forwarding have none.
+func (c *config) bindHosts() []string {
+	if c.TunnelMode == tunnelModeRemote || c.TunnelMode == tunnelModeLocal {
+		return nil
+	}
+	if c.activeBindHost != "" {
+		return []string{c.activeBindHost}
 	}
+	if len(c.SSHBindHosts) > 0 {
+		return c.SSHBindHosts
+	}
+	return []string{c.SSHBindHost}
+}
+
+// deriveProxyHost parses the bind hosts into proxyHosts, normalizing wildcard addresses to loopback.
+// The first binding becomes proxyHost. Every binding must use a distinct port.
+// proxyPort always comes from the configured bindings, so an overlap restart doesn't rename PID or log files.
+func (c *config) deriveProxyHost() error {
+	switch c.TunnelMode {
+	case tunnelModeRemote:
+		return c.deriveForwardTarget()
+	case tunnelModeLocal:
+		return c.deriveLocalForwards()
+	}
+
+	bindHosts := c.bindHosts()
+	proxyHosts := make([]string, 0, len(bindHosts))
+	ports := make(map[string]bool, len(bindHosts))
 
-	c.proxyHost = net.JoinHostPort(host, port)
+	for _, bindHost := range bindHosts {
+		host, port, err := net.SplitHostPort(bindHost)
+		if err != nil {
+			return fmt.Errorf("invalid bind host: %w", err)
+		}
+
+		portNum, err := strconv.Atoi(port)
+		if err != nil || portNum <= 0 || portNum > 65535 {
+			return fmt.Errorf("invalid bind host port: %s", port)
+		}
+		if ports[port] {
+			return fmt.Errorf("duplicate bind host port: %s", port)
+		}
+		ports[port] = true
+
+		switch host {
+		case "", "0.0.0.0":
+			host = "127.0.0.1"
+		case "::":
+			host = "::1"
+		}
+		proxyHosts = append(proxyHosts, net.JoinHostPort(host, port))
+	}
+
+	c.proxyHosts = proxyHosts
+	c.proxyHost = proxyHosts[0]
+	c.Tunnels = c.flatTunnels()
+
+	primary := c.SSHBindHost
+	if len(c.SSHBindHosts) > 0 {
+		primary = c.SSHBindHosts[0]
+	}
+	_, port, err := net.SplitHostPort(primary)
+	if err != nil {
+		return fmt.Errorf("invalid bind host: %w", err)
+	}
 	c.proxyPort = port
 	return nil
 }
 
-// getPortSpecificPIDFile returns a PID file name that includes the proxy port
-// to allow multiple instances running on different ports.
+// proxyPorts returns the ports of all proxy addresses, comma-separated.
+func (c *config) proxyPorts() string {
+	ports := make([]string, 0, len(c.proxyHosts))
+	for _, host := range c.proxyHosts {
+		_, port, _ := net.SplitHostPort(host)
+		ports = append(ports, port)
+	}
+	return strings.Join(ports, ",")
+}
+
+// instanceNamePattern accepts instance names that are safe in file names.
+var instanceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// instanceName returns InstanceName, or the proxy port if it isn't set.
+func (c *config) instanceName() string {
+	if c.InstanceName != "" {
+		return c.InstanceName
+	}
+	return c.proxyPort
+}
+
+// getPortSpecificPIDFile returns a PID file name that includes the instance name,
+// the proxy port by default, to allow multiple instances running on different ports.
 func (c *config) getPortSpecificPIDFile() string {
 	// e.g., "ssh-tunnel.pid" becomes "ssh-tunnel-8080.pid"
-	if c.PIDFile == "ssh-tunnel.pid" {
-		return fmt.Sprintf("ssh-tunnel-%s.pid", c.proxyPort)
+	if c.PIDFile == defaultPIDFile {
+		return filepath.Join(c.stateDir, fmt.Sprintf("ssh-tunnel-%s.pid", c.instanceName()))
 	}
 
-	// For custom PID file names, insert port before extension
+	// For custom PID file names, insert the name before extension
 	if len(c.PIDFile) > 4 && c.PIDFile[len(c.PIDFile)-4:] == ".pid" {
 		base := c.PIDFile[:len(c.PIDFile)-4]
-		return fmt.Sprintf("%s-%s.pid", base, c.proxyPort)
+		return fmt.Sprintf("%s-%s.pid", base, c.instanceName())
 	}
 
-	// Fallback: append port to filename
-	return fmt.Sprintf("%s-%s", c.PIDFile, c.proxyPort)
+	// Fallback: append the name to filename
+	return fmt.Sprintf("%s-%s", c.PIDFile, c.instanceName())
 }
 
-// getPortSpecificLogFile returns a log file name that includes the proxy port.
+// getPortSpecificLogFile returns a log file name that includes the instance name.
 func (c *config) getPortSpecificLogFile() string {
 	// e.g., "ssh-tunnel.log" becomes "ssh-tunnel-8080.log"
-	if c.LogFile == "ssh-tunnel.log" {
-		return fmt.Sprintf("ssh-tunnel-%s.log", c.proxyPort)
+	if c.LogFile == defaultLogFile {
+		return filepath.Join(c.stateDir, fmt.Sprintf("ssh-tunnel-%s.log", c.instanceName()))
 	}
 
-	// For custom log file names, insert port before extension
+	// For custom log file names, insert the name before extension
 	if len(c.LogFile) > 4 && c.LogFile[len(c.LogFile)-4:] == ".log" {
 		base := c.LogFile[:len(c.LogFile)-4]
-		return fmt.Sprintf("%s-%s.log", base, c.proxyPort)
+		return fmt.Sprintf("%s-%s.log", base, c.instanceName())
 	}
 
-	// Fallback: append port to filename
-	return fmt.Sprintf("%s-%s", c.LogFile, c.proxyPort)
+	// Fallback: append the name to filename
+	return fmt.Sprintf("%s-%s", c.LogFile, c.instanceName())
+}
+
+// sshLogLevels are the LogLevel values accepted by OpenSSH.
+var sshLogLevels = []string{"QUIET", "FATAL", "ERROR", "INFO", "VERBOSE", "DEBUG", "DEBUG1", "DEBUG2", "DEBUG3"}
+
+// sshLogLevel returns the LogLevel passed to ssh: SSHLogLevel if set,
+// otherwise ERROR with banner suppression and DEBUG3 without.
+func (c *config) sshLogLevel() string {
+	switch {
+	case c.SSHLogLevel != "":
+		return c.SSHLogLevel
+	case c.SSHSuppressBanner:
+		return "ERROR"
+	default:
+		return "DEBUG3"
+	}
 }
 
 // serializeSSHOptions builds the SSH command-line arguments from config.
 func (c *config) serializeSSHOptions() []string {
 	opts := make([]string, 0, 16)
 
-	// Base SSH options (no remote command, enable compression)
-	opts = append(opts, "-N", "-C")
+	// Base SSH options (no remote command, compression unless turned off)
+	opts = append(opts, "-N")
+	if c.SSHCompression {
+		opts = append(opts, "-C")
+	}
+
+	// Pseudo-terminal and escape character; forwarding needs neither
+	if c.SSHNoTTY {
+		opts = append(opts, "-T")
+	} else {
+		opts = append(opts, "-t")
+	}
+	if c.SSHEscapeChar != "" {
+		opts = append(opts, "-e", c.SSHEscapeChar)
+	}
+
+	// Address family of the connection to the server
+	if c.SSHIPVersion != "" {
+		opts = append(opts, "-"+c.SSHIPVersion)
+	}
+
+	// Client config file; "none" keeps ssh from reading ~/.ssh/config and /etc/ssh/ssh_config
+	switch c.SSHConfigFile {
+	case "":
+	case sshConfigNone:
+		opts = append(opts, "-F", os.DevNull)
+	default:
+		opts = append(opts, "-F", c.SSHConfigFile)
+	}
 
 	// TCP keepalive
 	if c.SSHTCPKeepAlive {
@@ -163,17 +1155,192 @@ func (c *config) serializeSSHOptions() []string {
 		opts = append(opts, "-o", fmt.Sprintf("ConnectTimeout=%d", c.SSHConnectTimeout))
 	}
 
-	// Strict host key checking
-	if !c.SSHStrictHostChecking {
+	// Inactive channels are closed by ssh; the option is unknown before OpenSSH 9.2
+	if c.SSHChannelTimeout > 0 && !c.sshVersion.less(channelTimeoutVersion) {
+		opts = append(opts, "-o", fmt.Sprintf("ChannelTimeout=*=%d", c.SSHChannelTimeout))
+	}
+
+	// Algorithms, with post-quantum key exchange where the release has it
+	if c.SSHPostQuantumOnly {
+		opts = append(opts, c.postQuantumOptions()...)
+	}
+
+	// Verbosity; ERROR also hides the server's login banner
+	opts = append(opts, "-o", "LogLevel="+c.sshLogLevel())
+
+	// Strict host key checking; accept-new trusts the first key but rejects changed ones
+	switch {
+	case c.SSHStrictHostChecking:
+	case c.SSHAutoKnownHosts:
+		opts = append(opts, "-o", "StrictHostKeyChecking=accept-new")
+	default:
 		opts = append(opts, "-o", "StrictHostKeyChecking=no")
 	}
 
-	// Dynamic port forwarding
-	opts = append(opts,
-		"-D", c.SSHBindHost,
-		"-p", fmt.Sprintf("%d", c.SSHRemotePort),
-		c.SSHRemoteAddress,
-	)
+	// known_hosts entries: hashed names, the server IP check and SSHFP records
+	if c.SSHHashKnownHosts {
+		opts = append(opts, "-o", "HashKnownHosts=yes")
+	}
+	if !c.SSHCheckHostIP {
+		opts = append(opts, "-o", "CheckHostIP=no")
+	}
+	opts = append(opts, "-o", "VerifyHostKeyDNS="+c.SSHVerifyHostKeyDNS)
+
+	// Outgoing address or interface of the SSH connection
+	if c.SSHBindSourceIP != "" {
+		opts = append(opts, "-b", c.SSHBindSourceIP)
+	}
+	if c.SSHBindInterface != "" {
+		opts = append(opts, "-o", "BindInterface="+c.SSHBindInterface)
+	}
+
+	// Connect to the address resolved at start, keeping host keys under the configured name
+	if c.resolvedHost != "" {
+		opts = append(opts,
+			"-o", "HostName="+c.resolvedHost,
+			"-o", "HostKeyAlias="+c.remoteHost(),
+		)
+	}
+
+	// Identity and the certificate signed for it
+	if identity := c.identityFile(); identity != "" {
+		opts = append(opts, "-i", identity)
+	}
+	if c.SSHCertificateFile != "" {
+		opts = append(opts, "-o", "CertificateFile="+c.SSHCertificateFile)
+	}
+
+	// Agent forwarding to the server and keys added to the local agent
+	if c.SSHForwardAgent {
+		opts = append(opts, "-o", "ForwardAgent=yes")
+	}
+	opts = append(opts, "-o", "AddKeysToAgent="+c.SSHAddKeysToAgent)
+
+	// Keys on a hardware token
+	if c.SSHPKCS11Provider != "" {
+		opts = append(opts, c.pkcs11Options()...)
+	}
+
+	// Reach the SSH server through an HTTP CONNECT proxy or a command of the user's
+	if proxyAddr := c.httpProxyAddr(); proxyAddr != "" {
+		opts = append(opts, "-o", "ProxyCommand=nc -X connect -x "+proxyAddr+" %h %p")
+	}
+	if c.SSHProxyCommand != "" {
+		opts = append(opts, "-o", "ProxyCommand="+c.proxyCommand())
+	}
+
+	// Local command run by ssh once connected
+	if c.SSHPermitLocalCommand {
+		opts = append(opts, "-o", "PermitLocalCommand=yes")
+	}
+	if c.SSHLocalCommand != "" {
+		opts = append(opts, "-o", "LocalCommand="+c.SSHLocalCommand)
+	}
+
+	// Connection multiplexing
+	if c.SSHControlMaster {
+		opts = append(opts,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+c.controlPath(),
+			"-o", "ControlPersist="+c.SSHControlPersist,
+		)
+	}
+
+	// Remote and local port forwarding; without a forward ssh would stay connected for nothing
+	if (c.TunnelMode == tunnelModeRemote || c.TunnelMode == tunnelModeLocal) && c.SSHExitOnForwardFailure {
+		opts = append(opts, "-o", "ExitOnForwardFailure=yes")
+	}
+	if c.SSHGatewayPorts {
+		opts = append(opts, "-o", "GatewayPorts=yes")
+	}
+
+	// Forwards of every rule; they all share the one SSH connection
+	for _, tunnel := range c.Tunnels {
+		opts = append(opts, tunnel.forwardOptions()...)
+	}
+	if len(c.Tunnels) > 0 {
+		opts = append(opts, c.Tunnels[0].destinationOptions()...)
+	}
 
 	return opts
 }
+
+// redactedValue is substituted for non-empty sensitive fields.
+const redactedValue = "***"
+
+// MarshalJSON encodes the config keyed by lowercase env names, with sensitive values masked.
+func (c *config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.redactedFields())
+}
+
+// String returns a redacted "key=value" representation suitable for logs.
+func (c *config) String() string {
+	fields := c.redactedFields()
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// redactedFields maps every env-backed field to a printable value.
+func (c *config) redactedFields() map[string]any {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	fields := make(map[string]any, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		var value any = v.Field(i).Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		if field.Tag.Get("sensitive") == "true" && !v.Field(i).IsZero() {
+			value = redactedValue
+		}
+		fields[configKey(field)] = value
+	}
+	return fields
+}
+
+// configKey returns the lowercase env name of a config field, e.g. "remote_address".
+func configKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+	if name == "-" {
+		// Derived, not read from the environment
+		return strings.ToLower(field.Name)
+	}
+	if name == "" {
+		return field.Name
+	}
+	return strings.ToLower(name)
+}
+
+// Diff returns the names of exported config fields whose values differ.
+func Diff(oldCfg, newCfg *config) []string {
+	ov := reflect.ValueOf(oldCfg).Elem()
+	nv := reflect.ValueOf(newCfg).Elem()
+	t := ov.Type()
+
+	var changed []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, field.Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// configWatchInterval is how often the config file is polled for changes. Replaced in tests.
+var configWatchInterval = time.Second
+
+// configWatchDebounce is how long the file must stay unchanged before it is reloaded,
+// so editors and ConfigMap updates that write in several steps trigger a single reload.
+var configWatchDebounce = 500 * time.Millisecond
+
+// readConfigFile reads KEY=VALUE lines using the same prefixed names as the environment.
+// Blank lines and lines starting with # are ignored; values may be single- or double-quoted.
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path) //nolint:gosec // path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("config file %s line %d: expected KEY=VALUE", path, lineNum)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return vars, nil
+}
+
+// configFileVersion identifies a revision of the config file by modification time and size.
+type configFileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// statConfigFile returns the current version of the file; a missing file has the zero version.
+// Stat follows symlinks, so a Kubernetes ConfigMap swapping its ..data link is seen as a change.
+func statConfigFile(path string) configFileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFileVersion{}
+	}
+	return configFileVersion{modTime: info.ModTime(), size: info.Size()}
+}
+
+// watchConfigFile polls ConfigFile for changes from the current version and, once a change
+// has settled, hands the reloaded config to the main loop like a management API update.
+// Returns when shutdownChan is closed.
+func (app *Application) watchConfigFile(current configFileVersion) {
+	logger := app.componentLogger(componentTunnel)
+	path := app.config.ConfigFile
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.shutdownChan:
+			return
+		case <-ticker.C:
+		}
+
+		version := statConfigFile(path)
+		if version == current {
+			continue
+		}
+
+		// Debounce: wait until the file stops changing
+		for {
+			if !app.sleepOrShutdown(configWatchDebounce) {
+				return
+			}
+			settled := statConfigFile(path)
+			if settled == version {
+				break
+			}
+			version = settled
+		}
+		current = version
+
+		cfg, err := loadConfig(path, app.config.envPrefix)
+		if err != nil {
+			logger.Error("Ignoring invalid config file change", "file", path, "error", err)
+			continue
+		}
+
+		app.configMutex.RLock()
+		err = cfg.keepSelectedPort(app.config)
+		changed := Diff(app.config, cfg)
+		app.configMutex.RUnlock()
+		if err != nil {
+			logger.Error("Ignoring invalid config file change", "file", path, "error", err)
+			continue
+		}
+		if len(changed) == 0 {
+			logger.Debug("Config file changed without effective changes", "file", path)
+			continue
+		}
+		logger.Info("Config file changed", "file", path, "changed", changed)
+
+		select {
+		case app.reloadChan <- cfg:
+		case <-app.shutdownChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to a config file in a temp dir and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ssh-tunnel.env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `# tunnel settings
+SSH_TUNNEL_REMOTE_ADDRESS=user@example.com
+
+export SSH_TUNNEL_REMOTE_PORT = 22
+SSH_TUNNEL_MGMT_TOKEN="a=b c"
+SSH_TUNNEL_LOG_FILE='tunnel.log'
+`)
+
+	vars, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"SSH_TUNNEL_REMOTE_ADDRESS": "user@example.com",
+		"SSH_TUNNEL_REMOTE_PORT":    "22",
+		"SSH_TUNNEL_MGMT_TOKEN":     "a=b c",
+		"SSH_TUNNEL_LOG_FILE":       "tunnel.log",
+	}
+	if len(vars) != len(want) {
+		t.Errorf("got %d vars, want %d: %v", len(vars), len(want), vars)
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s = %q, want %q", k, vars[k], v)
+		}
+	}
+}
+
+func TestReadConfigFile_Invalid(t *testing.T) {
+	path := writeConfigFile(t, "SSH_TUNNEL_REMOTE_ADDRESS\n")
+	if _, err := readConfigFile(path); err == nil {
+		t.Error("expected error for a line without =")
+	}
+
+	if _, err := readConfigFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestLoadConfig_FileOverridesEnvironment(t *testing.T) {
+	t.Setenv("SSH_TUNNEL_REMOTE_ADDRESS", "env@example.com")
+	t.Setenv("SSH_TUNNEL_REMOTE_PORT", "2000")
+	path := writeConfigFile(t, "SSH_TUNNEL_REMOTE_PORT=3000\n")
+
+	cfg, err := loadConfig(path, defaultEnvPrefix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SSHRemoteAddress != "env@example.com" {
+		t.Errorf("SSHRemoteAddress = %q, want value from environment", cfg.SSHRemoteAddress)
+	}
+	if cfg.SSHRemotePort != 3000 {
+		t.Errorf("SSHRemotePort = %d, want 3000 from file", cfg.SSHRemotePort)
+	}
+}
+
+func TestValidate_WatchConfigRequiresFile(t *testing.T) {
+	cfg := validConfig()
+	cfg.WatchConfig = true
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for WATCH_CONFIG without CONFIG_FILE")
+	}
+}
+
+// startConfigWatcher runs watchConfigFile on path with short intervals until the test ends.
+func startConfigWatcher(t *testing.T, path string) *Application {
+	t.Helper()
+
+	originalInterval, originalDebounce := configWatchInterval, configWatchDebounce
+	configWatchInterval, configWatchDebounce = 10*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { configWatchInterval, configWatchDebounce = originalInterval, originalDebounce })
+
+	t.Setenv("SSH_TUNNEL_REMOTE_ADDRESS", "user@example.com")
+	cfg, err := loadConfig(path, defaultEnvPrefix)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config = cfg
+	app.config.ConfigFile = path
+
+	version := statConfigFile(path)
+	done := make(chan struct{})
+	go func() {
+		app.watchConfigFile(version)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		close(app.shutdownChan)
+		<-done
+	})
+	return app
+}
+
+// rewriteConfigFile replaces the file content and bumps its modification time.
+func rewriteConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("failed to touch config file: %v", err)
+	}
+}
+
+func TestWatchConfigFile_Reload(t *testing.T) {
+	path := writeConfigFile(t, "SSH_TUNNEL_REMOTE_PORT=2212\n")
+	app := startConfigWatcher(t, path)
+
+	rewriteConfigFile(t, path, "SSH_TUNNEL_REMOTE_PORT=2222\n")
+
+	select {
+	case cfg := <-app.reloadChan:
+		if cfg.SSHRemotePort != 2222 {
+			t.Errorf("SSHRemotePort = %d, want 2222", cfg.SSHRemotePort)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change was not reloaded")
+	}
+}
+
+func TestWatchConfigFile_InvalidChangeIgnored(t *testing.T) {
+	path := writeConfigFile(t, "SSH_TUNNEL_REMOTE_PORT=2212\n")
+	app := startConfigWatcher(t, path)
+
+	rewriteConfigFile(t, path, "SSH_TUNNEL_REMOTE_PORT=99999\n")
+
+	select {
+	case cfg := <-app.reloadChan:
+		t.Errorf("invalid config should not be reloaded, got port %d", cfg.SSHRemotePort)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
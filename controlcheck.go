@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// controlCheckTimeout bounds a single "ssh -O check" of the control master.
+const controlCheckTimeout = 5 * time.Second
+
+// runControlMasterCheck asks the control master whether it is alive every
+// SSHControlMasterAliveInterval, and has the main loop restart the tunnel when it
+// doesn't answer. The master can die while ssh still has its forwards open, which
+// traffic checks only notice once the forwards are torn down.
+func (app *Application) runControlMasterCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.shutdownChan:
+			return
+		case <-ticker.C:
+			if app.checkControlMaster() {
+				continue
+			}
+			// A pending request already covers this one
+			select {
+			case app.masterDied <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// checkControlMaster runs "ssh -O check" against the control socket and reports whether
+// the master answered. It is skipped, reporting true, while paused or while no ssh process
+// runs, e.g. during a restart; the process watcher covers those.
+func (app *Application) checkControlMaster() bool {
+	app.sshMutex.RLock()
+	running := app.isProcessRunning(app.sshProcess)
+	app.sshMutex.RUnlock()
+	if !running || app.paused.Load() {
+		return true
+	}
+
+	// The fallback server may be in use, and a reload may change the server
+	app.configMutex.RLock()
+	args := app.config.controlCommandArgs("check")
+	app.configMutex.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), controlCheckTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, sshBinary, args...).CombinedOutput() //nolint:gosec
+	if err != nil {
+		app.componentLogger(componentSSH).Warn("SSH control master is not answering, restarting tunnel",
+			"error", err, "output", strings.TrimSpace(string(out)))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// startControlMasterSSH starts a fake ssh with control master settings.
+func startControlMasterSSH(t *testing.T) *Application {
+	t.Helper()
+	app, _ := newControlMasterTestApp(t)
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	t.Cleanup(func() { app.stopSSH(app.logger) })
+	return app
+}
+
+func TestCheckControlMaster(t *testing.T) {
+	app := startControlMasterSSH(t)
+	if app.checkControlMaster() {
+		t.Error("a master that doesn't answer should fail the check")
+	}
+
+	t.Setenv(fakeSSHMasterEnv, "1")
+	if !app.checkControlMaster() {
+		t.Error("a running master should pass the check")
+	}
+}
+
+func TestCheckControlMaster_SkippedWithoutProcess(t *testing.T) {
+	app, _ := newControlMasterTestApp(t)
+	if !app.checkControlMaster() {
+		t.Error("the check should be skipped while no ssh process runs")
+	}
+}
+
+func TestRunControlMasterCheck(t *testing.T) {
+	app := startControlMasterSSH(t)
+
+	done := make(chan struct{})
+	go func() {
+		app.runControlMasterCheck(10 * time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-app.masterDied:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a dead master should request a restart")
+	}
+
+	close(app.shutdownChan)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the check did not stop on shutdown")
+	}
+}
+
+func TestValidate_ControlMasterAliveInterval(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHControlMasterAliveInterval = time.Minute
+	if err := cfg.validate(); err == nil {
+		t.Error("alive interval without CONTROL_MASTER should be rejected")
+	}
+
+	cfg.SSHControlMaster = true
+	cfg.SSHControlSocketDir = t.TempDir()
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.SSHControlMasterAliveInterval = -time.Second
+	if err := cfg.validate(); err == nil {
+		t.Error("negative alive interval should be rejected")
+	}
+}
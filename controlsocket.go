@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // matches ssh's own %C hash
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// controlSocketName is the ControlPath file name inside SSHControlSocketDir.
+// ssh expands %r, %h and %p to the remote user, host and port.
+const controlSocketName = "ssh-tunnel-%r@%h:%p"
+
+// maxControlPathLen is the smallest sun_path limit among supported platforms (macOS).
+const maxControlPathLen = 104
+
+// controlPath returns the unexpanded ControlPath passed to ssh.
+func (c *config) controlPath() string {
+	return filepath.Join(c.SSHControlSocketDir, controlSocketName)
+}
+
+// validateControlPath checks that the expanded control socket path fits in a Unix socket address.
+func (c *config) validateControlPath() error {
+	if c.SSHControlSocketDir == "" {
+		return fmt.Errorf("control socket directory must not be empty")
+	}
+
+	path, err := c.expandControlPath(c.controlPath())
+	if err != nil {
+		return fmt.Errorf("invalid control socket path: %w", err)
+	}
+	if len(path) > maxControlPathLen {
+		return fmt.Errorf("control socket path %q is %d bytes, over the %d-byte Unix socket limit; use a shorter SSH_TUNNEL_CONTROL_SOCKET_DIR",
+			path, len(path), maxControlPathLen)
+	}
+	return nil
+}
+
+// validateControlPersist accepts "yes", "no" or a positive number of seconds.
+func validateControlPersist(value string) error {
+	switch value {
+	case "yes", "no":
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+		return fmt.Errorf("invalid control persist %q: must be yes, no or a positive number of seconds", value)
+	}
+	return nil
+}
+
+// controlPersists reports whether the master connection outlives the ssh process we started.
+func (c *config) controlPersists() bool {
+	return c.SSHControlMaster && c.SSHControlPersist != "no"
+}
+
+// controlCommandArgs returns the ssh arguments sending a control command ("check", "exit")
+// to the master listening on the control socket.
+func (c *config) controlCommandArgs(command string) []string {
+	return []string{
+		"-o", "ControlPath=" + c.controlPath(),
+		"-O", command,
+		"-p", strconv.Itoa(c.remotePort()),
+		c.remoteAddress(),
+	}
+}
+
+// stopControlMaster asks a persisted master connection to exit once our ssh process is gone.
+// With ControlPersist=no the master exits with the process, so nothing is sent.
+func (app *Application) stopControlMaster(logger *slog.Logger) {
+	if !app.config.controlPersists() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, sshBinary, app.config.controlCommandArgs("exit")...).CombinedOutput() //nolint:gosec
+	if err != nil {
+		logger.Warn("Failed to stop SSH control master", "error", err, "output", strings.TrimSpace(string(out)))
+		return
+	}
+	logger.Info("SSH control master stopped")
+}
+
+// removeStaleControlSocket deletes a control socket whose master no longer answers,
+// e.g. after a crash, since ssh refuses to create a new socket over it.
+// A socket of a running master is kept so the new process can reuse it.
+func (app *Application) removeStaleControlSocket(logger *slog.Logger, target *config) {
+	if !target.SSHControlMaster {
+		return
+	}
+
+	path, err := target.expandControlPath(target.controlPath())
+	if err != nil {
+		logger.Warn("Failed to resolve control socket path", "error", err)
+		return
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := exec.CommandContext(ctx, sshBinary, target.controlCommandArgs("check")...).Run(); err == nil { //nolint:gosec
+		logger.Info("SSH control master is still running, keeping its socket", "path", path)
+		return
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error("Failed to remove stale control socket", "path", path, "error", err)
+		return
+	}
+	logger.Warn("Removed stale SSH control socket", "path", path)
+}
+
+// createControlSocketDir creates the control socket directory, readable only by the current user.
+func (c *config) createControlSocketDir() error {
+	dir, err := c.expandControlPath(c.SSHControlSocketDir)
+	if err != nil {
+		return fmt.Errorf("invalid control socket directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	return nil
+}
+
+// expandControlPath resolves the ssh percent-sequences valid in ControlPath the way ssh would,
+// so the result can be created on disk and length-checked before ssh sees it.
+func (c *config) expandControlPath(path string) (string, error) {
+	if !strings.Contains(path, "%") {
+		return path, nil
+	}
+
+	localUser, home, uid := localUserInfo()
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname: %w", err)
+	}
+	shortHostname, _, _ := strings.Cut(hostname, ".")
+
+	// ssh expands %h to the HostName it connects to, %n to the name given on the command line
+	host := c.remoteHost()
+	hostName := host
+	if c.resolvedHost != "" {
+		hostName = c.resolvedHost
+	}
+	remoteUser := localUser
+	if at := strings.LastIndex(c.remoteAddress(), "@"); at >= 0 {
+		remoteUser = c.remoteAddress()[:at]
+	}
+	port := strconv.Itoa(c.remotePort())
+
+	// %C is the SHA1 of %l%h%p%r, as computed by ssh
+	sum := sha1.Sum([]byte(hostname + hostName + port + remoteUser)) //nolint:gosec // not used for security
+	values := map[byte]string{
+		'%': "%",
+		'C': hex.EncodeToString(sum[:]),
+		'd': home,
+		'h': hostName,
+		'i': uid,
+		'L': shortHostname,
+		'l': hostname,
+		'n': host,
+		'p': port,
+		'r': remoteUser,
+		'u': localUser,
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '%' {
+			b.WriteByte(path[i])
+			continue
+		}
+		if i+1 == len(path) {
+			return "", fmt.Errorf("%q ends with an incomplete %% sequence", path)
+		}
+		i++
+		value, ok := values[path[i]]
+		if !ok {
+			return "", fmt.Errorf("%q contains unsupported sequence %%%c", path, path[i])
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+// localUserInfo returns the current user's name, home directory and uid, falling back to
+// the environment when the user database is unavailable.
+func localUserInfo() (name, home, uid string) {
+	if u, err := user.Current(); err == nil {
+		return u.Username, u.HomeDir, u.Uid
+	}
+	home, _ = os.UserHomeDir()
+	return os.Getenv("USER"), home, strconv.Itoa(os.Getuid())
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExpandControlPath(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHRemoteAddress = "alice@example.com"
+
+	got, err := cfg.expandControlPath("/run/%r@%h:%p/%%")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "/run/alice@example.com:2212/%"; got != want {
+		t.Errorf("expandControlPath() = %q, want %q", got, want)
+	}
+
+	got, err = cfg.expandControlPath("%C")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 40 {
+		t.Errorf("%%C expanded to %q, want a 40-character hash", got)
+	}
+}
+
+func TestExpandControlPath_Errors(t *testing.T) {
+	cfg := validConfig()
+	for _, path := range []string{"/tmp/%", "/tmp/%z"} {
+		if _, err := cfg.expandControlPath(path); err == nil {
+			t.Errorf("expandControlPath(%q) expected error", path)
+		}
+	}
+}
+
+func TestValidate_ControlPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{"default", "/tmp", false},
+		{"empty", "", true},
+		{"too long", "/" + strings.Repeat("d", 100), true},
+		{"bad sequence", "/tmp/%z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.SSHControlMaster = true
+			cfg.SSHControlSocketDir = tt.dir
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_ControlPathIgnoredWhenDisabled(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHControlSocketDir = ""
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCreateControlSocketDir(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHControlSocketDir = filepath.Join(t.TempDir(), "sockets")
+
+	if err := cfg.createControlSocketDir(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := os.Stat(cfg.SSHControlSocketDir)
+	if err != nil {
+		t.Fatalf("directory not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0o700 {
+		t.Errorf("permissions = %o, want 700", perm)
+	}
+}
+
+func TestValidateControlPersist(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"yes", true},
+		{"no", true},
+		{"60", true},
+		{"0", false},
+		{"-5", false},
+		{"10m", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if err := validateControlPersist(tt.value); (err == nil) != tt.ok {
+			t.Errorf("validateControlPersist(%q) err=%v, want ok=%v", tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestStopControlMaster(t *testing.T) {
+	requireShell(t)
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	fakeSSH := filepath.Join(dir, "ssh")
+	if err := os.WriteFile(fakeSSH, []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0o700); err != nil {
+		t.Fatalf("failed to write fake ssh: %v", err)
+	}
+	originalSSHBinary := sshBinary
+	sshBinary = fakeSSH
+	t.Cleanup(func() { sshBinary = originalSSHBinary })
+
+	app := newTestApp(t)
+	app.config.SSHControlMaster = true
+	logger := slog.New(slog.DiscardHandler)
+
+	app.config.SSHControlPersist = "no"
+	app.stopControlMaster(logger)
+	if _, err := os.Stat(argsFile); err == nil {
+		t.Fatal("control master should not be signalled with ControlPersist=no")
+	}
+
+	app.config.SSHControlPersist = "60"
+	app.stopControlMaster(logger)
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("control master was not signalled: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "-o ControlPath=/tmp/ssh-tunnel-%r@%h:%p -O exit -p 2212 user@host" {
+		t.Errorf("unexpected ssh arguments: %s", got)
+	}
+}
+
+// newControlMasterTestApp returns an app using a control socket in a temporary directory,
+// with a stale socket file already at the expected path.
+func newControlMasterTestApp(t *testing.T) (*Application, string) {
+	t.Helper()
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	app.config.SSHControlMaster = true
+	app.config.SSHControlSocketDir = t.TempDir()
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	path, err := app.config.expandControlPath(app.config.controlPath())
+	if err != nil {
+		t.Fatalf("expandControlPath: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("failed to create socket file: %v", err)
+	}
+	return app, path
+}
+
+func TestStartSSH_RemovesStaleControlSocket(t *testing.T) {
+	app, path := newControlMasterTestApp(t)
+
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	t.Cleanup(func() { app.stopSSH(app.logger) })
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stale control socket should have been removed, stat err=%v", err)
+	}
+}
+
+func TestRemoveStaleControlSocket_KeepsRunningMaster(t *testing.T) {
+	app, path := newControlMasterTestApp(t)
+	t.Setenv(fakeSSHMasterEnv, "1")
+
+	app.removeStaleControlSocket(app.logger, app.config)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("socket of a running master should be kept: %v", err)
+	}
+}
+
+func TestSerializeSSHOptions_ControlMaster(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHControlMaster = true
+	cfg.SSHControlSocketDir = "/run/tunnel"
+	cfg.SSHControlPersist = "60"
+
+	joined := strings.Join(cfg.serializeSSHOptions(), " ")
+	if !strings.Contains(joined, "-o ControlMaster=auto -o ControlPath=/run/tunnel/ssh-tunnel-%r@%h:%p -o ControlPersist=60") {
+		t.Errorf("missing control options: %s", joined)
+	}
+
+	cfg.SSHControlMaster = false
+	if joined := strings.Join(cfg.serializeSSHOptions(), " "); strings.Contains(joined, "ControlMaster") {
+		t.Errorf("unexpected control options: %s", joined)
+	}
+}
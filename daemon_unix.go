@@ -0,0 +1,75 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// daemonChildEnv marks the background process started by --daemon.
+const daemonChildEnv = "SSH_TUNNEL_DAEMON_CHILD"
+
+// daemonize starts the binary again in a new session, detached from the terminal, with
+// stdin from /dev/null and stdout/stderr appended to the log file, and writes the
+// child's PID to the PID file. It returns true in the parent, which should exit, and
+// false in the child, which carries on with the normal startup.
+func (app *Application) daemonize() (bool, error) {
+	if os.Getenv(daemonChildEnv) != "" {
+		// Keep the marker away from ssh and hook commands
+		if err := os.Unsetenv(daemonChildEnv); err != nil {
+			return false, err
+		}
+		app.daemonPID = os.Getpid()
+		return false, nil
+	}
+
+	// The child picks the port, and with it the PID file name, only after forking
+	if app.config.SSHAutoSelectPort {
+		return false, errors.New("--daemon cannot be combined with SSH_TUNNEL_AUTO_SELECT_PORT")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("failed to locate the executable: %w", err)
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = devNull.Close() }()
+
+	if err := app.config.createStateDir(); err != nil {
+		return false, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	logFile, err := os.OpenFile(filepath.Clean(app.config.getPortSpecificLogFile()), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return false, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer func() { _ = logFile.Close() }()
+
+	pid, err := syscall.ForkExec(executable, os.Args, &syscall.ProcAttr{
+		Env:   append(os.Environ(), daemonChildEnv+"=1"),
+		Files: []uintptr{devNull.Fd(), logFile.Fd(), logFile.Fd()},
+		Sys:   &syscall.SysProcAttr{Setsid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to start background process: %w", err)
+	}
+
+	app.daemonPID = pid
+	if err := app.claimPIDFile(pid); err != nil {
+		// The child would fail on the same PID file; don't leave it running until then
+		if process, findErr := os.FindProcess(pid); findErr == nil {
+			_ = process.Kill()
+		}
+		return false, err
+	}
+
+	app.logger.Info("Running in the background", "pid", pid, "log_file", logFile.Name())
+	return true, nil
+}
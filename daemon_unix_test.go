@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDaemonize_Child(t *testing.T) {
+	t.Setenv(daemonChildEnv, "1")
+	app := newTestApp(t)
+
+	started, err := app.daemonize()
+	if err != nil || started {
+		t.Fatalf("daemonize() = %v, %v in the child, want false, nil", started, err)
+	}
+	if app.daemonPID != os.Getpid() {
+		t.Errorf("daemonPID = %d, want own PID %d", app.daemonPID, os.Getpid())
+	}
+	if _, ok := os.LookupEnv(daemonChildEnv); ok {
+		t.Errorf("%s should be removed from the child's environment", daemonChildEnv)
+	}
+}
+
+func TestDaemonize_AutoSelectPort(t *testing.T) {
+	app := newTestApp(t)
+	app.config.SSHAutoSelectPort = true
+
+	if started, err := app.daemonize(); err == nil || started {
+		t.Errorf("daemonize() = %v, %v, want an error with auto-selected ports", started, err)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+// daemonize is not supported on Windows; the process stays in the foreground.
+// Run it as a service instead.
+func (app *Application) daemonize() (bool, error) {
+	app.logger.Error("--daemon is not supported on Windows, running in the foreground")
+	return false, nil
+}
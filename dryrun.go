@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dryRunDialTimeout bounds the TCP reachability check of the SSH server.
+const dryRunDialTimeout = 5 * time.Second
+
+// dryRunReport is the structured summary printed by --dry-run.
+type dryRunReport struct {
+	Config          *config  `json:"config"`
+	PIDFile         string   `json:"pid_file"`
+	PIDFileStatus   string   `json:"pid_file_status"`
+	SSHBinary       string   `json:"ssh_binary,omitempty"`
+	SSHVersion      string   `json:"ssh_version,omitempty"`
+	RemoteEndpoint  string   `json:"remote_endpoint"`
+	RemoteReachable bool     `json:"remote_reachable"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+// dryRun checks the PID file, SSH binary, and remote reachability without
+// starting anything, then writes a JSON report to w.
+// Returns an error if any check failed.
+func (app *Application) dryRun(w io.Writer) error {
+	report := dryRunReport{
+		Config:         app.config,
+		PIDFile:        filepath.Clean(app.config.getPortSpecificPIDFile()),
+		RemoteEndpoint: app.config.remoteEndpoint(),
+	}
+
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	status, err := pidFileStatus(report.PIDFile)
+	if err != nil {
+		fail(err)
+	}
+	report.PIDFileStatus = status
+
+	path, version, err := sshVersion()
+	if err != nil {
+		fail(err)
+	} else {
+		report.SSHBinary = path
+		report.SSHVersion = version
+		app.logger.Info("SSH binary found", "path", path, "version", version)
+	}
+
+	dialer := app.config.dialer(&net.Dialer{Timeout: dryRunDialTimeout})
+	conn, err := dialer.DialContext(context.Background(), app.config.serverNetwork(), report.RemoteEndpoint)
+	if err != nil {
+		fail(fmt.Errorf("remote %s unreachable: %w", report.RemoteEndpoint, err))
+	} else {
+		report.RemoteReachable = true
+		if err := conn.Close(); err != nil {
+			app.logger.Error("Failed to close remote connection", "error", err)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write dry-run report: %w", err)
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d dry-run check(s) failed", len(report.Errors))
+	}
+	return nil
+}
+
+// pidFileStatus describes the PID file without modifying it: "absent", "stale (pid N)",
+// "stale (pid N, other binary)" or "in use (pid N)". An in-use file is an error.
+func pidFileStatus(pidFile string) (string, error) {
+	if _, err := os.Stat(pidFile); errors.Is(err, os.ErrNotExist) {
+		return "absent", nil
+	}
+
+	existing, err := readPIDFile(pidFile)
+	if err != nil {
+		return "unreadable", err
+	}
+	pid := existing.PID
+
+	alive, err := checkProcessAlive(pid)
+	if err != nil {
+		return "unknown", fmt.Errorf("failed to check PID %d: %w", pid, err)
+	}
+	// Without a hash of our own binary only the PID counts
+	if hash, _ := binaryHash(); alive && existing.otherBinary(hash) {
+		return fmt.Sprintf("stale (pid %d, other binary)", pid), nil
+	}
+	if alive {
+		return fmt.Sprintf("in use (pid %d)", pid), fmt.Errorf("another instance is already running with PID %d", pid)
+	}
+	return fmt.Sprintf("stale (pid %d)", pid), nil
+}
+
+// sshVersion locates the SSH binary and returns its path and version string.
+func sshVersion() (string, string, error) {
+	path, err := exec.LookPath(sshBinary)
+	if err != nil {
+		return "", "", fmt.Errorf("SSH binary not found: %w", err)
+	}
+
+	// ssh -V prints the version to stderr
+	out, err := exec.Command(path, "-V").CombinedOutput() //nolint:gosec // G204: path comes from LookPath of a fixed binary name.
+	if err != nil {
+		return path, "", fmt.Errorf("failed to get SSH version: %w", err)
+	}
+	return path, strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+// newDryRunTestApp returns an app whose remote endpoint is a local listener.
+func newDryRunTestApp(t *testing.T) *Application {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.SSHRemoteAddress = "user@127.0.0.1"
+	app.config.SSHRemotePort = listenerPort(t, listener)
+	return app
+}
+
+// listenerPort returns the TCP port a listener is bound to.
+func listenerPort(t *testing.T, listener net.Listener) int {
+	t.Helper()
+
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		t.Fatalf("unexpected listener address type %T", listener.Addr())
+	}
+	return addr.Port
+}
+
+// dryRunResult holds the report fields the tests assert on.
+type dryRunResult struct {
+	PIDFileStatus   string   `json:"pid_file_status"`
+	RemoteReachable bool     `json:"remote_reachable"`
+	Errors          []string `json:"errors"`
+}
+
+// runDryRun runs the dry run and decodes its report.
+func runDryRun(t *testing.T, app *Application) (dryRunResult, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	runErr := app.dryRun(&out)
+
+	var report dryRunResult
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report %q: %v", out.String(), err)
+	}
+	return report, runErr
+}
+
+func TestDryRun_Success(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh binary not available")
+	}
+	app := newDryRunTestApp(t)
+
+	report, err := runDryRun(t, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v (report errors: %v)", err, report.Errors)
+	}
+	if report.PIDFileStatus != "absent" {
+		t.Errorf("PIDFileStatus = %q, want %q", report.PIDFileStatus, "absent")
+	}
+	if !report.RemoteReachable {
+		t.Error("remote should be reachable")
+	}
+
+	// Dry run must not create the PID file
+	if _, err := os.Stat(app.config.getPortSpecificPIDFile()); !os.IsNotExist(err) {
+		t.Error("dry run must not create a PID file")
+	}
+}
+
+func TestDryRun_MissingSSHBinary(t *testing.T) {
+	app := newDryRunTestApp(t)
+
+	originalSSHBinary := sshBinary
+	sshBinary = "ssh-tunnel-test-missing-binary"
+	t.Cleanup(func() { sshBinary = originalSSHBinary })
+
+	report, err := runDryRun(t, app)
+	if err == nil {
+		t.Fatal("expected error for missing SSH binary")
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("Errors = %v, want exactly the SSH binary failure", report.Errors)
+	}
+}
+
+func TestDryRun_RemoteUnreachable(t *testing.T) {
+	app := newDryRunTestApp(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	app.config.SSHRemotePort = listenerPort(t, listener)
+	_ = listener.Close()
+
+	report, err := runDryRun(t, app)
+	if err == nil {
+		t.Fatal("expected error for unreachable remote")
+	}
+	if report.RemoteReachable {
+		t.Error("remote should be unreachable")
+	}
+}
+
+func TestPIDFileStatus(t *testing.T) {
+	useBinaryHash(t, "new")
+	app := newTestApp(t)
+	pidFile := app.config.getPortSpecificPIDFile()
+
+	status, err := pidFileStatus(pidFile)
+	if err != nil || status != "absent" {
+		t.Errorf("absent: got %q, %v", status, err)
+	}
+
+	if err := os.WriteFile(pidFile, []byte("999999999"), 0600); err != nil {
+		t.Fatalf("failed to write PID file: %v", err)
+	}
+	status, err = pidFileStatus(pidFile)
+	if err != nil || status != "stale (pid 999999999)" {
+		t.Errorf("stale: got %q, %v", status, err)
+	}
+
+	// Written by this process before an upgrade
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		t.Fatalf("failed to write PID file: %v", err)
+	}
+	status, err = pidFileStatus(pidFile)
+	if want := fmt.Sprintf("stale (pid %d, other binary)", os.Getpid()); err != nil || status != want {
+		t.Errorf("other binary: got %q, %v; want %q", status, err, want)
+	}
+
+	if err := os.WriteFile(pidFile, fmt.Appendf(nil, `{"pid":%d,"hash":"new"}`, os.Getpid()), 0600); err != nil {
+		t.Fatalf("failed to write PID file: %v", err)
+	}
+	if _, err := pidFileStatus(pidFile); err == nil {
+		t.Error("expected error for PID file of a running process")
+	}
+
+	// The PID file must be left untouched
+	if _, err := os.Stat(pidFile); err != nil {
+		t.Errorf("PID file should still exist: %v", err)
+	}
+}
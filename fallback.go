@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// selectRemote picks the SSH server for the next start: the primary if it accepts
+// connections, otherwise FallbackRemoteAddress. Every start tries the primary first,
+// so the tunnel moves back to it once it is reachable again. A failure of both counts
+// as a failed check. It only probes copies of the config and returns the chosen one;
+// useRemote switches to it. Main loop only.
+func (app *Application) selectRemote(logger *slog.Logger) (*config, error) {
+	target := app.config.forServer(false, "")
+	primary, err := target.dialServer()
+	if err == nil {
+		return target, nil
+	}
+	logger.Warn("Primary SSH server unreachable, trying the fallback", "remote", primary, "error", err)
+
+	target = app.config.forServer(true, "")
+	fallback, fallbackErr := target.dialServer()
+	if fallbackErr != nil {
+		app.recordCheckStats(false)
+		return nil, fmt.Errorf("SSH servers unreachable: %s: %w; fallback %s: %w", primary, err, fallback, fallbackErr)
+	}
+	logger.Warn("Connecting to the fallback SSH server", "remote", target.remoteAddress(), "endpoint", fallback)
+	return target, nil
+}
+
+// forServer returns a copy of c pointed at the primary or the fallback server, with
+// resolvedHost as the server's address.
+func (c *config) forServer(fallback bool, resolvedHost string) *config {
+	next := *c
+	next.useFallback = fallback
+	next.resolvedHost = resolvedHost
+	return &next
+}
+
+// useRemote switches the config to the server and address chosen for target.
+func (app *Application) useRemote(target *config) {
+	app.configMutex.Lock()
+	defer app.configMutex.Unlock()
+
+	app.config.useFallback = target.useFallback
+	app.config.resolvedHost = target.resolvedHost
+	app.config.Tunnels = app.config.flatTunnels()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serverPort returns the port of a local listener, closed unless open is true.
+func serverPort(t *testing.T, open bool) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if open {
+		t.Cleanup(func() { _ = listener.Close() })
+	} else {
+		_ = listener.Close()
+	}
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// fallbackApp returns an app with a primary and a fallback SSH server, both resolved
+// to 127.0.0.1.
+func fallbackApp(t *testing.T, primaryUp, fallbackUp bool) *Application {
+	t.Helper()
+	useLookupHost(t, func(context.Context, string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	})
+
+	app := newTestApp(t)
+	app.config.SSHRemoteAddress = "user@old.example.com"
+	app.config.SSHRemotePort = serverPort(t, primaryUp)
+	app.config.FallbackRemoteAddress = "user@new.example.com"
+	app.config.FallbackRemotePort = serverPort(t, fallbackUp)
+	app.config.PreflightCheckTimeout = time.Second
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	return app
+}
+
+func TestSelectRemote_Primary(t *testing.T) {
+	app := fallbackApp(t, true, true)
+
+	target, err := app.selectRemote(app.logger)
+	if err != nil {
+		t.Fatalf("selectRemote: %v", err)
+	}
+	if target.useFallback || target.remoteAddress() != "user@old.example.com" {
+		t.Errorf("remote = %s, want the primary", target.remoteAddress())
+	}
+}
+
+func TestSelectRemote_Fallback(t *testing.T) {
+	app := fallbackApp(t, false, true)
+
+	target, err := app.selectRemote(app.logger)
+	if err != nil {
+		t.Fatalf("selectRemote: %v", err)
+	}
+	if target.remoteAddress() != "user@new.example.com" {
+		t.Errorf("remote = %s, want the fallback", target.remoteAddress())
+	}
+	if app.config.useFallback {
+		t.Error("selectRemote should leave the active config alone")
+	}
+	app.useRemote(target)
+	opts := app.config.serializeSSHOptions()
+	want := []string{"-p", strconv.Itoa(app.config.FallbackRemotePort), "user@new.example.com"}
+	if !slices.Equal(opts[len(opts)-3:], want) {
+		t.Errorf("ssh destination = %v, want %v", opts[len(opts)-3:], want)
+	}
+
+	// The next start tries the primary again
+	primary := app.config.SSHRemotePort
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(primary))
+	if err != nil {
+		t.Skipf("primary port %d was taken in the meantime: %v", primary, err)
+	}
+	defer func() { _ = listener.Close() }()
+	if target, err = app.selectRemote(app.logger); err != nil {
+		t.Fatalf("selectRemote: %v", err)
+	}
+	if target.useFallback {
+		t.Error("the primary is back, the fallback should no longer be used")
+	}
+}
+
+func TestSelectRemote_BothDown(t *testing.T) {
+	app := fallbackApp(t, false, false)
+
+	if _, err := app.selectRemote(app.logger); err == nil {
+		t.Fatal("selectRemote should fail with both servers down")
+	}
+	if app.config.useFallback {
+		t.Error("a failed selection should leave the primary in place")
+	}
+	if got := app.consecutiveFailures.Load(); got != 1 {
+		t.Errorf("consecutiveFailures = %d, want the failure counted", got)
+	}
+}
+
+func TestStartSSH_SwitchesToFallback(t *testing.T) {
+	useFakeSSH(t)
+	app := fallbackApp(t, false, true)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	// The probes must not show up in the active config
+	var switchedEarly bool
+	useLookupHost(t, func(context.Context, string) ([]string, error) {
+		switchedEarly = switchedEarly || app.config.useFallback
+		return []string{"127.0.0.1"}, nil
+	})
+
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	defer app.stopSSH(app.logger)
+
+	if switchedEarly {
+		t.Error("the active config switched to the fallback while the servers were probed")
+	}
+	if !app.config.useFallback || app.config.remoteAddress() != "user@new.example.com" {
+		t.Errorf("remote = %s, want the fallback", app.config.remoteAddress())
+	}
+}
+
+func TestValidate_FallbackRemote(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		ok     bool
+	}{
+		{"unset", func(c *config) { c.FallbackRemoteAddress = "" }, true},
+		{"address only", func(*config) {}, true},
+		{"with port", func(c *config) { c.FallbackRemotePort = 22 }, true},
+		{"invalid port", func(c *config) { c.FallbackRemotePort = 70000 }, false},
+		{"invalid address", func(c *config) { c.FallbackRemoteAddress = "user@new.example.com:22" }, false},
+		{"with HTTP proxy", func(c *config) { c.SSHHTTPProxy = "http://proxy.corp" }, false},
+		{"with reject on key change", func(c *config) {
+			c.HostKeyScan = true
+			c.RejectOnKeyChange = true
+		}, false},
+		{"no dial timeout", func(c *config) { c.PreflightCheckTimeout = 0 }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.FallbackRemoteAddress = "user@new.example.com"
+			cfg.PreflightCheckTimeout = time.Second
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFallbackRemotePort(t *testing.T) {
+	cfg := validConfig()
+	cfg.FallbackRemoteAddress = "user@new.example.com"
+	if got := cfg.fallbackRemotePort(); got != cfg.SSHRemotePort {
+		t.Errorf("fallbackRemotePort() = %d, want the primary's %d", got, cfg.SSHRemotePort)
+	}
+	cfg.FallbackRemotePort = 22
+	if got := cfg.fallbackRemotePort(); got != 22 {
+		t.Errorf("fallbackRemotePort() = %d, want 22", got)
+	}
+}
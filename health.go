@@ -0,0 +1,48 @@
+package main
+
+// Restart policies.
+const (
+	restartPolicyOnFailure   = "on-failure"
+	restartPolicyHealthScore = "health-score"
+)
+
+// resetHealth clears the check history, e.g. for a freshly started tunnel. Main loop only.
+func (app *Application) resetHealth() {
+	app.healthResults = app.healthResults[:0]
+	storeFloat(&app.healthScore, 1)
+	app.quality.reset()
+}
+
+// recordHealth adds a check outcome to the last HealthWindowSize results and updates
+// the health score. Until the window is full the score covers the checks seen so far.
+// Main loop only.
+func (app *Application) recordHealth(healthy bool) {
+	app.healthResults = append(app.healthResults, healthy)
+	if extra := len(app.healthResults) - app.config.HealthWindowSize; extra > 0 {
+		app.healthResults = app.healthResults[extra:]
+	}
+
+	successes := 0
+	for _, ok := range app.healthResults {
+		if ok {
+			successes++
+		}
+	}
+	storeFloat(&app.healthScore, float64(successes)/float64(len(app.healthResults)))
+}
+
+// shouldRestart reports whether a failed traffic check restarts the tunnel. With the
+// health-score policy only a score below HealthRestartThreshold does.
+func (app *Application) shouldRestart() bool {
+	if app.config.RestartPolicy != restartPolicyHealthScore {
+		return true
+	}
+
+	score := loadFloat(&app.healthScore)
+	if score >= app.config.HealthRestartThreshold {
+		app.componentLogger(componentTunnel).Info("Traffic check failed, health score is above the restart threshold",
+			"health_score", score, "health_restart_threshold", app.config.HealthRestartThreshold)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestRecordHealth(t *testing.T) {
+	app := newTestApp(t)
+	app.config.HealthWindowSize = 4
+	app.resetHealth()
+
+	steps := []struct {
+		healthy bool
+		want    float64
+	}{
+		{false, 0},      // F
+		{true, 0.5},     // F T
+		{true, 2.0 / 3}, // F T T
+		{true, 0.75},    // F T T T
+		{true, 1},       // T T T T, the first failure has left the window
+		{false, 0.75},   // T T T F
+	}
+	for i, step := range steps {
+		app.recordHealth(step.healthy)
+		if got := loadFloat(&app.healthScore); got != step.want {
+			t.Errorf("after check %d: score = %v, want %v", i+1, got, step.want)
+		}
+	}
+
+	app.resetHealth()
+	if got := app.Stats().HealthScore; got != 1 || len(app.healthResults) != 0 {
+		t.Errorf("after reset: score = %v with %d results, want 1 and none", got, len(app.healthResults))
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		score  float64
+		want   bool
+	}{
+		{"on failure", restartPolicyOnFailure, 0.9, true},
+		{"health score above threshold", restartPolicyHealthScore, 0.6, false},
+		{"health score at threshold", restartPolicyHealthScore, 0.5, false},
+		{"health score below threshold", restartPolicyHealthScore, 0.4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.logger = slog.New(slog.DiscardHandler)
+			app.config.RestartPolicy = tt.policy
+			app.config.HealthRestartThreshold = 0.5
+			storeFloat(&app.healthScore, tt.score)
+
+			if got := app.shouldRestart(); got != tt.want {
+				t.Errorf("shouldRestart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartTunnel_ResetsHealth(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.MaxRestartsPerHour = 0
+	countRestarts(app)
+	app.recordHealth(false)
+
+	app.restartTunnel()
+	if got := app.Stats().HealthScore; got != 1 || len(app.healthResults) != 0 {
+		t.Errorf("after restart: score = %v with %d results, want a fresh window", got, len(app.healthResults))
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hookTimeout bounds how long an on-start or on-stop command may run.
+const hookTimeout = 10 * time.Second
+
+// runHook runs an operator command via "sh -c" with the tunnel described in its environment.
+// Failures are logged and otherwise ignored so hooks can't break the tunnel.
+func (app *Application) runHook(logger *slog.Logger, name, command string) {
+	if command == "" {
+		return
+	}
+
+	host, port, _ := net.SplitHostPort(app.config.proxyHost)
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command) //nolint:gosec // command is operator configuration
+	cmd.Env = append(os.Environ(),
+		"TUNNEL_HOST="+host,
+		"TUNNEL_PORT="+port,
+		"TUNNEL_MODE="+app.config.TunnelMode,
+	)
+
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		logger.Error("Hook command failed", "hook", name, "error", err, "output", output)
+		return
+	}
+	logger.Info("Hook command completed", "hook", name, "output", output)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requireShell skips the test when sh is unavailable.
+func requireShell(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+}
+
+func TestRunHook_Environment(t *testing.T) {
+	requireShell(t)
+	app := newTestApp(t)
+	out := filepath.Join(t.TempDir(), "hook.out")
+
+	app.runHook(slog.New(slog.DiscardHandler), "on_start", `echo "$TUNNEL_HOST $TUNNEL_PORT $TUNNEL_MODE" > `+out)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "127.0.0.1 8080 dynamic"; got != want {
+		t.Errorf("hook environment = %q, want %q", got, want)
+	}
+}
+
+func TestRunHook_FailureIsLogged(t *testing.T) {
+	requireShell(t)
+	app := newTestApp(t)
+	var buf bytes.Buffer
+
+	app.runHook(slog.New(slog.NewTextHandler(&buf, nil)), "on_stop", "echo boom; exit 3")
+
+	if !strings.Contains(buf.String(), "Hook command failed") || !strings.Contains(buf.String(), "boom") {
+		t.Errorf("expected failure with output in log, got: %s", buf.String())
+	}
+}
+
+func TestRunHook_Empty(t *testing.T) {
+	app := newTestApp(t)
+	var buf bytes.Buffer
+
+	app.runHook(slog.New(slog.NewTextHandler(&buf, nil)), "on_start", "")
+
+	if buf.Len() != 0 {
+		t.Errorf("empty hook should not log, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshKeyscanBinary is the ssh-keyscan executable used to fetch the server's host keys.
+var sshKeyscanBinary = "ssh-keyscan"
+
+// scanHostKey points to the ssh-keyscan lookup and is replaced in tests.
+var scanHostKey = keyscanFingerprints
+
+// errHostKeyChanged is returned by checkHostKey when SSH_TUNNEL_REJECT_ON_KEY_CHANGE refuses to connect.
+var errHostKeyChanged = errors.New("SSH host key has changed since the last connection")
+
+// keyscanFingerprints fetches the server's ed25519 and RSA host keys and returns
+// their fingerprints in "ED25519 SHA256:..." form, sorted so they compare stably.
+func keyscanFingerprints(ctx context.Context, host string, port int) (string, error) {
+	out, err := exec.CommandContext(ctx, sshKeyscanBinary, //nolint:gosec // fixed binary, host and port are operator configuration
+		"-p", strconv.Itoa(port), "-t", "ed25519,rsa", host).Output()
+	if err != nil {
+		return "", fmt.Errorf("ssh-keyscan failed: %w", err)
+	}
+	return parseKeyscan(out)
+}
+
+// parseKeyscan hashes the keys in ssh-keyscan output, whose lines look like
+// "[example.com]:2212 ssh-ed25519 AAAA...". Comment lines start with '#'.
+func parseKeyscan(out []byte) (string, error) {
+	var fingerprints []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(fields[1] + " " + fields[2]))
+		if err != nil {
+			return "", fmt.Errorf("invalid host key in ssh-keyscan output: %w", err)
+		}
+		keyType := strings.ToUpper(strings.TrimPrefix(key.Type(), "ssh-"))
+		fingerprints = append(fingerprints, keyType+" "+ssh.FingerprintSHA256(key))
+	}
+	if len(fingerprints) == 0 {
+		return "", errors.New("ssh-keyscan returned no host keys")
+	}
+	slices.Sort(fingerprints)
+	return strings.Join(fingerprints, ", "), nil
+}
+
+// scanServerHostKey scans the host keys of target's server before ssh starts. It runs
+// ssh-keyscan, so it is called without sshMutex. A failed scan is only logged and
+// returns "", since ssh checks the key itself.
+func (app *Application) scanServerHostKey(logger *slog.Logger, target *config) string {
+	host := target.hostKeyHost()
+	ctx, cancel := context.WithTimeout(context.Background(), target.TunnelStartTimeout)
+	defer cancel()
+
+	fingerprint, err := scanHostKey(ctx, host, target.remotePort())
+	if err != nil {
+		logger.Warn("Failed to scan SSH host key", "host", host, "error", err)
+		return ""
+	}
+	return fingerprint
+}
+
+// hostKeyHost returns the address whose host keys are scanned.
+func (c *config) hostKeyHost() string {
+	if c.resolvedHost != "" {
+		return c.resolvedHost
+	}
+	return c.remoteHost()
+}
+
+// checkHostKey compares the fingerprint that scanServerHostKey found for target with
+// the last scan, which survives in the PID file. A first scan is logged at WARN, a
+// change at ERROR; with SSH_TUNNEL_REJECT_ON_KEY_CHANGE a change refuses the start. An
+// empty fingerprint, from a failed scan, is accepted. Called with sshMutex held.
+func (app *Application) checkHostKey(logger *slog.Logger, target *config, fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+	host := target.hostKeyHost()
+
+	switch app.hostKey {
+	case "":
+		logger.Warn("Scanned SSH host key on first connection", "host", host, "fingerprint", fingerprint, "first_connection", true)
+	case fingerprint:
+		logger.Debug("SSH host key unchanged", "host", host, "fingerprint", fingerprint)
+		return nil
+	default:
+		logger.Error("SSH host key has changed since the last connection",
+			"host", host, "fingerprint", fingerprint, "previous_fingerprint", app.hostKey)
+		if app.config.RejectOnKeyChange {
+			return errHostKeyChanged
+		}
+	}
+
+	app.hostKey = fingerprint
+	if err := app.storeHostKey(fingerprint); err != nil {
+		logger.Warn("Failed to store SSH host key in the PID file", "error", err)
+	}
+	return nil
+}
+
+// storeHostKey records the host key fingerprint in this instance's PID file.
+// Without a PID file, as in a self-test, there is nothing to update.
+func (app *Application) storeHostKey(fingerprint string) error {
+	pidFile := filepath.Clean(app.config.getPortSpecificPIDFile())
+	content, err := readPIDFile(pidFile)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && content.PID != os.Getpid()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	content.HostKey = fingerprint
+	return writePIDFile(pidFile, content)
+}
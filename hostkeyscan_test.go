@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// useScanHostKey replaces the ssh-keyscan lookup for the duration of the test.
+func useScanHostKey(t *testing.T, scan func(ctx context.Context, host string, port int) (string, error)) {
+	t.Helper()
+	original := scanHostKey
+	scanHostKey = scan
+	t.Cleanup(func() { scanHostKey = original })
+}
+
+func TestParseKeyscan(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert key: %v", err)
+	}
+
+	out := "# example.com:2212 SSH-2.0-OpenSSH_9.6\n" +
+		"[example.com]:2212 " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + "\n"
+	got, err := parseKeyscan([]byte(out))
+	if err != nil {
+		t.Fatalf("parseKeyscan: %v", err)
+	}
+	if want := "ED25519 " + ssh.FingerprintSHA256(key); got != want {
+		t.Errorf("parseKeyscan() = %q, want %q", got, want)
+	}
+
+	for _, out := range []string{"", "# example.com:2212 SSH-2.0-OpenSSH_9.6\n", "example.com ssh-ed25519 not-base64\n"} {
+		if _, err := parseKeyscan([]byte(out)); err == nil {
+			t.Errorf("parseKeyscan(%q): expected error", out)
+		}
+	}
+}
+
+// hostKeyTestApp returns an app with host key scanning enabled, a PID file and a JSON log buffer.
+func hostKeyTestApp(t *testing.T, scanned string) (*Application, *bytes.Buffer) {
+	t.Helper()
+	useScanHostKey(t, func(_ context.Context, host string, port int) (string, error) {
+		if host != "host" || port != 2212 {
+			t.Errorf("scanned %s:%d, want host:2212", host, port)
+		}
+		if scanned == "" {
+			return "", errors.New("connection refused")
+		}
+		return scanned, nil
+	})
+
+	app := newTestApp(t)
+	app.config.HostKeyScan = true
+	if err := app.createPIDFile(); err != nil {
+		t.Fatalf("createPIDFile: %v", err)
+	}
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return app, &buf
+}
+
+func TestCheckHostKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		previous   string
+		scanned    string // "" makes the scan fail
+		reject     bool
+		ok         bool
+		level      string
+		stored     string // host key written to the PID file
+		remembered string
+	}{
+		{"first connection", "", "ED25519 SHA256:new", false, true, "WARN", "ED25519 SHA256:new", "ED25519 SHA256:new"},
+		{"unchanged", "ED25519 SHA256:old", "ED25519 SHA256:old", false, true, "DEBUG", "", "ED25519 SHA256:old"},
+		{"changed", "ED25519 SHA256:old", "ED25519 SHA256:new", false, true, "ERROR", "ED25519 SHA256:new", "ED25519 SHA256:new"},
+		{"changed and rejected", "ED25519 SHA256:old", "ED25519 SHA256:new", true, false, "ERROR", "", "ED25519 SHA256:old"},
+		{"scan failed", "ED25519 SHA256:old", "", true, true, "WARN", "", "ED25519 SHA256:old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, buf := hostKeyTestApp(t, tt.scanned)
+			app.config.RejectOnKeyChange = tt.reject
+			app.hostKey = tt.previous
+
+			err := app.checkHostKey(app.logger, app.config, app.scanServerHostKey(app.logger, app.config))
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid log output %q: %v", buf.String(), err)
+			}
+			if record["level"] != tt.level {
+				t.Errorf("logged %v %q, want level %s", record["level"], record["msg"], tt.level)
+			}
+			if tt.name == "first connection" && record["first_connection"] != true {
+				t.Errorf("first connection not flagged: %v", record)
+			}
+
+			content, err := readPIDFile(app.config.getPortSpecificPIDFile())
+			if err != nil {
+				t.Fatalf("readPIDFile: %v", err)
+			}
+			if content.HostKey != tt.stored {
+				t.Errorf("PID file host key = %q, want %q", content.HostKey, tt.stored)
+			}
+			if app.hostKey != tt.remembered {
+				t.Errorf("remembered host key = %q, want %q", app.hostKey, tt.remembered)
+			}
+		})
+	}
+}
+
+func TestStartSSH_HostKeyScanWithoutLock(t *testing.T) {
+	app, _ := hostKeyTestApp(t, "ED25519 SHA256:new")
+	app.config.RejectOnKeyChange = true
+	app.hostKey = "ED25519 SHA256:old"
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	var locked bool
+	useScanHostKey(t, func(context.Context, string, int) (string, error) {
+		// ssh-keyscan may take up to the start timeout
+		if app.sshMutex.TryLock() {
+			app.sshMutex.Unlock()
+		} else {
+			locked = true
+		}
+		return "ED25519 SHA256:new", nil
+	})
+
+	if err := app.startSSH(app.logger); !errors.Is(err, errHostKeyChanged) {
+		t.Fatalf("startSSH() = %v, want errHostKeyChanged", err)
+	}
+	if locked {
+		t.Error("sshMutex was held during the host key scan")
+	}
+	if app.sshProcess != nil {
+		t.Error("no SSH process should be started")
+	}
+}
+
+func TestOverlapRestart_RejectsChangedHostKey(t *testing.T) {
+	useFakeSSH(t)
+	app, _ := hostKeyTestApp(t, "ED25519 SHA256:old")
+	app.config.RejectOnKeyChange = true
+	app.config.OverlapRestart = true
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	old := app.sshProcess
+	t.Cleanup(func() { app.stopSSH(app.logger) })
+
+	useScanHostKey(t, func(context.Context, string, int) (string, error) {
+		return "ED25519 SHA256:new", nil
+	})
+	if err := overlapRestart(context.Background(), app, app.logger); !errors.Is(err, errHostKeyChanged) {
+		t.Fatalf("overlapRestart() = %v, want errHostKeyChanged", err)
+	}
+	if app.sshProcess != old || !app.isProcessRunning(old) {
+		t.Error("the current SSH process should be kept after a rejected key")
+	}
+	if app.hostKey != "ED25519 SHA256:old" {
+		t.Errorf("remembered host key = %q, want the old one", app.hostKey)
+	}
+}
+
+func TestCreatePIDFile_KeepsHostKey(t *testing.T) {
+	app := newTestApp(t)
+	pidFile := app.config.getPortSpecificPIDFile()
+
+	// Left behind by a run that didn't shut down cleanly
+	stale := `{"pid":999999999,"host_key":"ED25519 SHA256:old"}`
+	if err := os.WriteFile(pidFile, []byte(stale), 0600); err != nil {
+		t.Fatalf("failed to write PID file: %v", err)
+	}
+
+	if err := app.createPIDFile(); err != nil {
+		t.Fatalf("createPIDFile: %v", err)
+	}
+	content, err := readPIDFile(pidFile)
+	if err != nil {
+		t.Fatalf("readPIDFile: %v", err)
+	}
+	if app.hostKey != "ED25519 SHA256:old" || content.HostKey != "ED25519 SHA256:old" {
+		t.Errorf("host key = %q, PID file %q; want the previous run's key", app.hostKey, content.HostKey)
+	}
+}
+
+func TestStartSSH_RejectsChangedHostKey(t *testing.T) {
+	useFakeSSH(t)
+	app, _ := hostKeyTestApp(t, "ED25519 SHA256:new")
+	app.config.RejectOnKeyChange = true
+	app.hostKey = "ED25519 SHA256:old"
+
+	if err := app.startSSH(app.logger); !errors.Is(err, errHostKeyChanged) {
+		t.Fatalf("startSSH error = %v, want %v", err, errHostKeyChanged)
+	}
+	if app.sshProcess != nil {
+		t.Error("no SSH process should be started")
+	}
+}
+
+func TestValidate_HostKeyScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		scan   bool
+		reject bool
+		proxy  string
+		ok     bool
+	}{
+		{"scan", true, false, "", true},
+		{"scan and reject", true, true, "", true},
+		{"reject without scan", false, true, "", false},
+		{"scan through HTTP proxy", true, false, "http://proxy.example.com:3128", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.HostKeyScan = tt.scan
+			cfg.RejectOnKeyChange = tt.reject
+			cfg.SSHHTTPProxy = tt.proxy
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
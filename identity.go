@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pemPrefix starts every PEM-encoded private key, including the OpenSSH format.
+const pemPrefix = "-----BEGIN"
+
+// decodeIdentityEnv decodes the base64 private key held in the environment variable name.
+func decodeIdentityEnv(name string) ([]byte, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return nil, fmt.Errorf("identity environment variable %s is empty or not set", name)
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("identity environment variable %s is not valid base64: %w", name, err)
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(key), []byte(pemPrefix)) {
+		return nil, fmt.Errorf("identity environment variable %s does not decode to a PEM private key", name)
+	}
+	return key, nil
+}
+
+// identityFile returns the private key passed to ssh -i: the file written from
+// SSH_TUNNEL_IDENTITY_ENV_VAR, or else SSH_TUNNEL_IDENTITY_FILE.
+func (c *config) identityFile() string {
+	if c.identityKeyFile != "" {
+		return c.identityKeyFile
+	}
+	return c.SSHIdentityFile
+}
+
+// writeIdentityKey writes the key decoded from SSH_TUNNEL_IDENTITY_ENV_VAR to a new
+// private temp directory, since ssh only reads keys from files. The file written for
+// current is reused while the key is unchanged; current may be nil.
+func (c *config) writeIdentityKey(current *config) error {
+	if c.identityKey == nil {
+		return nil
+	}
+	if current != nil && current.identityKeyFile != "" && bytes.Equal(c.identityKey, current.identityKey) {
+		c.identityKeyFile = current.identityKeyFile
+		return nil
+	}
+
+	// MkdirTemp creates the directory with 0700, owned by the current user
+	dir, err := os.MkdirTemp("", "ssh-tunnel-identity-")
+	if err != nil {
+		return fmt.Errorf("failed to create identity directory: %w", err)
+	}
+	path := filepath.Join(dir, "id")
+	if err := os.WriteFile(path, c.identityKey, 0600); err != nil {
+		_ = os.RemoveAll(dir)
+		return fmt.Errorf("failed to write identity file: %w", err)
+	}
+	c.identityKeyFile = path
+	return nil
+}
+
+// removeIdentityKey deletes the file written by writeIdentityKey, if any.
+func (c *config) removeIdentityKey() error {
+	if c.identityKeyFile == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Dir(c.identityKeyFile))
+}
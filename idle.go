@@ -0,0 +1,36 @@
+package main
+
+import "time"
+
+// idleDeadline returns when the tunnel counts as idle: MaxTunnelIdleTime after the
+// last successful traffic check or restart, whichever is later.
+func (app *Application) idleDeadline() time.Time {
+	last := max(app.lastCheckSuccess.Load(), app.lastRestartTime.Load())
+	return time.Unix(0, last).Add(app.config.MaxTunnelIdleTime)
+}
+
+// resetIdleTimer schedules the next idle check, or stops the timer when MaxTunnelIdleTime is 0.
+func (app *Application) resetIdleTimer(timer *time.Timer) {
+	if app.config.MaxTunnelIdleTime <= 0 {
+		timer.Stop()
+		return
+	}
+	timer.Reset(time.Until(app.idleDeadline()))
+}
+
+// checkIdle restarts the tunnel if no traffic check has succeeded within MaxTunnelIdleTime.
+// Appliances that silently drop long-lived connections are caught even when the
+// check interval is longer than their timeout. Returns the delay until the next idle check.
+func (app *Application) checkIdle(now time.Time) time.Duration {
+	deadline := app.idleDeadline()
+	if now.Before(deadline) {
+		return deadline.Sub(now)
+	}
+
+	if !app.paused.Load() {
+		app.componentLogger(componentTunnel).Warn("No successful traffic check within the maximum idle time, restarting tunnel",
+			"max_tunnel_idle_time", app.config.MaxTunnelIdleTime, "last_success", unixNanoTime(app.lastCheckSuccess.Load()))
+		app.restartTunnel()
+	}
+	return app.config.MaxTunnelIdleTime
+}
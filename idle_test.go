@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// countRestarts replaces the restart strategy with one that only counts calls.
+func countRestarts(app *Application) *int {
+	restarts := 0
+	app.restartStrategy = func(context.Context, *Application, *slog.Logger) error {
+		restarts++
+		return nil
+	}
+	return &restarts
+}
+
+func TestCheckIdle(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		lastSuccess time.Duration // before now
+		lastRestart time.Duration // before now, 0 if never restarted
+		paused      bool
+		restarts    int
+		next        time.Duration
+	}{
+		{"recent success", 10 * time.Minute, 0, false, 0, 50 * time.Minute},
+		{"idle", 2 * time.Hour, 0, false, 1, time.Hour},
+		{"recent restart", 2 * time.Hour, 30 * time.Minute, false, 0, 30 * time.Minute},
+		{"idle while paused", 2 * time.Hour, 0, true, 0, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.logger = slog.New(slog.DiscardHandler)
+			app.config.MaxTunnelIdleTime = time.Hour
+			app.config.MaxRestartsPerHour = 0
+			restarts := countRestarts(app)
+
+			app.lastCheckSuccess.Store(now.Add(-tt.lastSuccess).UnixNano())
+			if tt.lastRestart > 0 {
+				app.lastRestartTime.Store(now.Add(-tt.lastRestart).UnixNano())
+			}
+			app.paused.Store(tt.paused)
+
+			if next := app.checkIdle(now); next != tt.next {
+				t.Errorf("next idle check in %s, want %s", next, tt.next)
+			}
+			if *restarts != tt.restarts {
+				t.Errorf("restarts = %d, want %d", *restarts, tt.restarts)
+			}
+		})
+	}
+}
+
+func TestResetIdleTimer(t *testing.T) {
+	app := newTestApp(t)
+	app.lastCheckSuccess.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	app.config.MaxTunnelIdleTime = 0
+	app.resetIdleTimer(timer)
+	select {
+	case <-timer.C:
+		t.Fatal("timer fired while idle restarts are disabled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Already past the deadline, so the check is due right away
+	app.config.MaxTunnelIdleTime = time.Minute
+	app.resetIdleTimer(timer)
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire for an idle tunnel")
+	}
+}
+
+func TestRecordCheckStats_LastSuccess(t *testing.T) {
+	app := newTestApp(t)
+
+	app.recordCheckStats(false)
+	if app.lastCheckSuccess.Load() != 0 {
+		t.Error("a failed check must not count as success")
+	}
+	app.recordCheckStats(true)
+	if app.lastCheckSuccess.Load() == 0 {
+		t.Error("a successful check should be recorded")
+	}
+}
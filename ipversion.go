@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// IP versions of SSHIPVersion; empty lets ssh choose.
+const (
+	ipVersion4 = "4"
+	ipVersion6 = "6"
+)
+
+// validateIPVersion checks SSHIPVersion.
+func (c *config) validateIPVersion() error {
+	switch c.SSHIPVersion {
+	case "", ipVersion4, ipVersion6:
+		return nil
+	}
+	return fmt.Errorf("invalid IP version %q: must be 4, 6 or empty", c.SSHIPVersion)
+}
+
+// serverNetwork returns the network for direct connections to the SSH server,
+// restricted to SSHIPVersion like ssh's -4 and -6.
+func (c *config) serverNetwork() string {
+	return "tcp" + c.SSHIPVersion
+}
+
+// pickServerAddress returns the address of the SSH server to connect to. With an IP
+// version only an address of that family will do; otherwise SSHPreferIPv4 decides.
+func (c *config) pickServerAddress(addrs []string) (string, error) {
+	if c.SSHIPVersion == "" {
+		return pickAddress(addrs, c.SSHPreferIPv4), nil
+	}
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && (ip.To4() != nil) == (c.SSHIPVersion == ipVersion4) {
+			return addr, nil
+		}
+	}
+	return "", fmt.Errorf("no IPv%s address among %v", c.SSHIPVersion, addrs)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidate_IPVersion(t *testing.T) {
+	for _, version := range []string{"", "4", "6"} {
+		cfg := validConfig()
+		cfg.SSHIPVersion = version
+		if err := cfg.validate(); err != nil {
+			t.Errorf("IP version %q: unexpected error: %v", version, err)
+		}
+	}
+	for _, version := range []string{"ipv4", "46", "tcp4"} {
+		cfg := validConfig()
+		cfg.SSHIPVersion = version
+		if err := cfg.validate(); err == nil {
+			t.Errorf("IP version %q should be rejected", version)
+		}
+	}
+}
+
+func TestSerializeSSHOptions_IPVersion(t *testing.T) {
+	for _, tt := range []struct{ version, want string }{{"4", "-4"}, {"6", "-6"}} {
+		cfg := validConfig()
+		cfg.SSHIPVersion = tt.version
+		if opts := cfg.serializeSSHOptions(); !slices.Contains(opts, tt.want) {
+			t.Errorf("IP version %s: missing %s in %v", tt.version, tt.want, opts)
+		}
+	}
+
+	cfg := validConfig()
+	for _, opt := range cfg.serializeSSHOptions() {
+		if opt == "-4" || opt == "-6" {
+			t.Errorf("unexpected %s without an IP version", opt)
+		}
+	}
+}
+
+func TestPickServerAddress(t *testing.T) {
+	addrs := []string{"2001:db8::1", "192.0.2.1"}
+
+	tests := []struct {
+		version string
+		addrs   []string
+		want    string
+		ok      bool
+	}{
+		{"", addrs, "192.0.2.1", true}, // PREFER_IPV4
+		{"4", addrs, "192.0.2.1", true},
+		{"6", addrs, "2001:db8::1", true},
+		{"6", []string{"192.0.2.1"}, "", false},
+	}
+
+	for _, tt := range tests {
+		cfg := validConfig()
+		cfg.SSHPreferIPv4 = true
+		cfg.SSHIPVersion = tt.version
+		got, err := cfg.pickServerAddress(tt.addrs)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("IP version %q, %v: got %q, %v; want %q, ok=%v", tt.version, tt.addrs, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPreflightCheck_IPVersion(t *testing.T) {
+	app := preflightApp(t, true)
+	app.config.SSHIPVersion = ipVersion4
+	if err := app.preflightCheck(app.logger, app.config); err != nil {
+		t.Errorf("preflightCheck() = %v over IPv4, want nil", err)
+	}
+
+	// The server only resolves to 127.0.0.1
+	app.config.SSHIPVersion = ipVersion6
+	if err := app.preflightCheck(app.logger, app.config); err == nil || !strings.Contains(err.Error(), "no IPv6 address") {
+		t.Errorf("preflightCheck() = %v over IPv6, want no address", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"math/rand/v2"
+	"time"
+)
+
+// jitterRand is seeded from crypto/rand so instances started from the same
+// binary at the same moment don't pick correlated delays.
+// Only the main loop uses it, so it needs no locking.
+var jitterRand = newJitterRand()
+
+// newJitterRand returns a ChaCha8 generator with a cryptographically random seed.
+func newJitterRand() *rand.Rand {
+	var seed [32]byte
+	_, _ = cryptorand.Read(seed[:]) // never fails since Go 1.24
+	return rand.New(rand.NewChaCha8(seed))
+}
+
+// randomDuration returns a uniform random duration in [0, max].
+func randomDuration(maxDelay time.Duration) time.Duration {
+	if maxDelay <= 0 {
+		return 0
+	}
+	return time.Duration(jitterRand.Int64N(int64(maxDelay) + 1))
+}
+
+// sleepJitter waits a random part of MainLoopJitter before a health check.
+// Returns false if shutdown was requested while waiting.
+func (app *Application) sleepJitter() bool {
+	return app.sleepOrShutdown(randomDuration(app.config.MainLoopJitter))
+}
+
+// sleepOrShutdown waits for delay, returning false if shutdown was requested while waiting.
+func (app *Application) sleepOrShutdown(delay time.Duration) bool {
+	if delay == 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-app.shutdownChan:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRandomDuration_Range(t *testing.T) {
+	const maxDelay = 50 * time.Millisecond
+	for range 1000 {
+		d := randomDuration(maxDelay)
+		if d < 0 || d > maxDelay {
+			t.Fatalf("randomDuration(%v) = %v, out of range", maxDelay, d)
+		}
+	}
+}
+
+func TestRandomDuration_Disabled(t *testing.T) {
+	if d := randomDuration(0); d != 0 {
+		t.Errorf("randomDuration(0) = %v, want 0", d)
+	}
+}
+
+func TestSleepJitter_ShutdownInterrupts(t *testing.T) {
+	app := newTestApp(t)
+	app.config.MainLoopJitter = time.Hour
+	close(app.shutdownChan)
+
+	done := make(chan bool, 1)
+	go func() { done <- app.sleepJitter() }()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("sleepJitter should report shutdown")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sleepJitter did not return on shutdown")
+	}
+}
+
+func TestRestartTunnel_ShutdownDuringReconnectJitter(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.ReconnectJitter = time.Hour
+	close(app.shutdownChan)
+
+	done := make(chan struct{})
+	go func() {
+		app.restartTunnel()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("restartTunnel did not return on shutdown")
+	}
+	if app.sshProcess != nil {
+		t.Error("SSH should not be started after shutdown")
+	}
+}
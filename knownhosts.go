@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Markers in ssh's stderr output about host keys.
+const (
+	hostKeyAddedMarker   = "Permanently added '"
+	hostKeyChangedMarker = "REMOTE HOST IDENTIFICATION HAS CHANGED"
+)
+
+// sshKeygenBinary is the ssh-keygen executable used to look up fingerprints.
+var sshKeygenBinary = "ssh-keygen"
+
+// hostKeyFingerprint points to the known_hosts lookup and is replaced in tests.
+var hostKeyFingerprint = knownHostsFingerprint
+
+// knownHostsName returns the remote host as ssh records it in known_hosts.
+func (c *config) knownHostsName() string {
+	if c.remotePort() == 22 {
+		return c.remoteHost()
+	}
+	return "[" + c.remoteHost() + "]:" + strconv.Itoa(c.remotePort())
+}
+
+// hostKeyWatcher logs host key events found in ssh's stderr.
+type hostKeyWatcher struct {
+	logger *slog.Logger
+	host   string
+}
+
+// handleLine logs a host key that was accepted or refused.
+func (w *hostKeyWatcher) handleLine(line string) {
+	switch {
+	case strings.Contains(line, hostKeyChangedMarker):
+		w.logger.Error("SSH host key has changed, connection refused",
+			"host", w.host,
+			"hint", "if the server key was rotated on purpose, remove the old key with: ssh-keygen -R '"+w.host+"'")
+	case strings.Contains(line, hostKeyAddedMarker):
+		// e.g. "Warning: Permanently added '[example.com]:2212' (ED25519) to the list of known hosts."
+		rest := line[strings.Index(line, hostKeyAddedMarker)+len(hostKeyAddedMarker):]
+		host, _, _ := strings.Cut(rest, "'")
+		fingerprint, err := hostKeyFingerprint(host)
+		if err != nil {
+			w.logger.Warn("Accepted new SSH host key", "host", host, "fingerprint_error", err)
+			return
+		}
+		w.logger.Warn("Accepted new SSH host key", "host", host, "fingerprint", fingerprint)
+	}
+}
+
+// knownHostsFingerprint returns the fingerprints recorded for host in the user's known_hosts.
+func knownHostsFingerprint(host string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, sshKeygenBinary, "-l", "-F", host).Output() //nolint:gosec
+	if err != nil {
+		return "", err
+	}
+	return parseKeygenFingerprints(out), nil
+}
+
+// parseKeygenFingerprints extracts the fingerprints from "ssh-keygen -l -F" output,
+// whose entries look like "[example.com]:2212 ED25519 SHA256:...".
+func parseKeygenFingerprints(out []byte) string {
+	var fingerprints []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		fingerprints = append(fingerprints, fields[1]+" "+fields[2])
+	}
+	return strings.Join(fingerprints, ", ")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestKnownHostsName(t *testing.T) {
+	tests := []struct {
+		address string
+		port    int
+		want    string
+	}{
+		{"example.com", 22, "example.com"},
+		{"user@example.com", 22, "example.com"},
+		{"user@example.com", 2212, "[example.com]:2212"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s:%d", tt.address, tt.port), func(t *testing.T) {
+			cfg := validConfig()
+			cfg.SSHRemoteAddress = tt.address
+			cfg.SSHRemotePort = tt.port
+			if got := cfg.knownHostsName(); got != tt.want {
+				t.Errorf("knownHostsName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeygenFingerprints(t *testing.T) {
+	out := []byte("# Host [example.com]:2212 found: line 1 \n" +
+		"[example.com]:2212 ED25519 SHA256:wl/DC+/T2jEh2lLOaIzlPPcbpwsEo3CkII6+E/ngDG4\n" +
+		"# Host [example.com]:2212 found: line 2 \n" +
+		"[example.com]:2212 RSA SHA256:abc\n")
+
+	want := "ED25519 SHA256:wl/DC+/T2jEh2lLOaIzlPPcbpwsEo3CkII6+E/ngDG4, RSA SHA256:abc"
+	if got := parseKeygenFingerprints(out); got != want {
+		t.Errorf("parseKeygenFingerprints() = %q, want %q", got, want)
+	}
+}
+
+// useHostKeyFingerprint replaces the known_hosts lookup for the duration of the test.
+func useHostKeyFingerprint(t *testing.T, lookup func(host string) (string, error)) {
+	t.Helper()
+	original := hostKeyFingerprint
+	hostKeyFingerprint = lookup
+	t.Cleanup(func() { hostKeyFingerprint = original })
+}
+
+// watchStderr feeds chunks through the ssh output writer and returns the decoded log records.
+func watchStderr(t *testing.T, chunks ...string) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	w := &sshOutput{out: io.Discard, logger: logger, hostKeys: &hostKeyWatcher{logger: logger, host: "[example.com]:2212"}}
+	for _, chunk := range chunks {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestHostKeyWatcher_Added(t *testing.T) {
+	var lookedUp string
+	useHostKeyFingerprint(t, func(host string) (string, error) {
+		lookedUp = host
+		return "ED25519 SHA256:abc", nil
+	})
+
+	// ssh may write a line in several pieces
+	records := watchStderr(t,
+		"Warning: Permanently added '[example.com]:2212' ",
+		"(ED25519) to the list of known hosts.\r\n",
+	)
+
+	if lookedUp != "[example.com]:2212" {
+		t.Errorf("looked up %q, want [example.com]:2212", lookedUp)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d log records, want 1: %v", len(records), records)
+	}
+	if records[0]["level"] != "WARN" || records[0]["fingerprint"] != "ED25519 SHA256:abc" {
+		t.Errorf("unexpected record %v", records[0])
+	}
+}
+
+func TestHostKeyWatcher_AddedLookupFails(t *testing.T) {
+	useHostKeyFingerprint(t, func(string) (string, error) {
+		return "", errors.New("not found")
+	})
+
+	records := watchStderr(t, "Warning: Permanently added 'example.com' (ED25519) to the list of known hosts.\n")
+	if len(records) != 1 {
+		t.Fatalf("got %d log records, want 1: %v", len(records), records)
+	}
+	if records[0]["level"] != "WARN" || records[0]["fingerprint_error"] != "not found" {
+		t.Errorf("unexpected record %v", records[0])
+	}
+}
+
+func TestHostKeyWatcher_Changed(t *testing.T) {
+	records := watchStderr(t,
+		"@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@\n"+
+			"@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\n"+
+			"@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@\n"+
+			"Host key verification failed.\n",
+	)
+
+	if len(records) != 1 {
+		t.Fatalf("got %d log records, want 1: %v", len(records), records)
+	}
+	if records[0]["level"] != "ERROR" {
+		t.Errorf("level = %v, want ERROR", records[0]["level"])
+	}
+	if hint, _ := records[0]["hint"].(string); !strings.Contains(hint, "ssh-keygen -R '[example.com]:2212'") {
+		t.Errorf("hint = %q, want the ssh-keygen -R command", hint)
+	}
+}
+
+func TestHostKeyWatcher_OtherOutput(t *testing.T) {
+	records := watchStderr(t, "channel 2: open failed: connect failed: Connection refused\n", "partial line")
+	if len(records) != 0 {
+		t.Errorf("got %d log records, want 0: %v", len(records), records)
+	}
+}
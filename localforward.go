@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// forwardRule is one -L or -R rule of the local or remote tunnel mode.
+type forwardRule struct {
+	spec  string // the -L or -R argument
+	check string // host:port health checks connect to, empty for a socket
+}
+
+// parseLocalForward checks an OpenSSH -L rule, "[bind_address:]port:host:hostport"
+// or "local_socket:host:hostport". IPv6 addresses are written in brackets.
+func parseLocalForward(rule string) (forwardRule, error) {
+	fields := splitForwardFields(rule)
+	if len(fields) != 3 && len(fields) != 4 {
+		return forwardRule{}, fmt.Errorf("invalid local forward %q: want [bind_address:]port:host:hostport or socket:host:hostport", rule)
+	}
+	host, hostPort := fields[len(fields)-2], fields[len(fields)-1]
+	if strings.Trim(host, "[]") == "" {
+		return forwardRule{}, fmt.Errorf("invalid local forward %q: missing host", rule)
+	}
+	if !validForwardPort(hostPort) {
+		return forwardRule{}, fmt.Errorf("invalid local forward %q: invalid port %q", rule, hostPort)
+	}
+
+	// A local socket is only reachable by path; its forward isn't health checked
+	if len(fields) == 3 && strings.HasPrefix(fields[0], "/") {
+		return forwardRule{spec: rule}, nil
+	}
+
+	bind, port := "", fields[0]
+	if len(fields) == 4 {
+		bind, port = strings.Trim(fields[0], "[]"), fields[1]
+	}
+	if !validForwardPort(port) {
+		return forwardRule{}, fmt.Errorf("invalid local forward %q: invalid local port %q", rule, port)
+	}
+	switch bind {
+	case "", "*", "0.0.0.0", "localhost":
+		bind = "127.0.0.1"
+	case "::":
+		bind = "::1"
+	}
+	return forwardRule{spec: rule, check: net.JoinHostPort(bind, port)}, nil
+}
+
+// splitForwardFields splits a forwarding rule at colons outside brackets.
+func splitForwardFields(rule string) []string {
+	var fields []string
+	start, depth := 0, 0
+	for i, ch := range rule {
+		switch ch {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				fields = append(fields, rule[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, rule[start:])
+}
+
+// validForwardPort reports whether port is a number from 1 to 65535.
+func validForwardPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// parseLocalForwards parses SSHLocalForwards for the local tunnel mode. Every
+// local port may appear once, and at least one rule must listen on a port, which
+// identifies the instance.
+func (c *config) parseLocalForwards() ([]forwardRule, error) {
+	if len(c.SSHLocalForwards) == 0 {
+		return nil, fmt.Errorf("local tunnel mode requires SSH_TUNNEL_LOCAL_FORWARDS")
+	}
+	forwards := make([]forwardRule, 0, len(c.SSHLocalForwards))
+	ports := make(map[string]bool, len(c.SSHLocalForwards))
+	for _, rule := range c.SSHLocalForwards {
+		forward, err := parseLocalForward(strings.TrimSpace(rule))
+		if err != nil {
+			return nil, err
+		}
+		if forward.check != "" {
+			_, port, _ := net.SplitHostPort(forward.check)
+			if ports[port] {
+				return nil, fmt.Errorf("duplicate local forward port: %s", port)
+			}
+			ports[port] = true
+		}
+		forwards = append(forwards, forward)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("local forwards need at least one rule with a local port")
+	}
+	return forwards, nil
+}
+
+// deriveLocalForwards makes the local ports of the -L rules the addresses health
+// checks connect to; the first one identifies the instance.
+func (c *config) deriveLocalForwards() error {
+	forwards, err := c.parseLocalForwards()
+	if err != nil {
+		return err
+	}
+
+	c.proxyHosts = nil
+	for _, forward := range forwards {
+		if forward.check != "" {
+			c.proxyHosts = append(c.proxyHosts, forward.check)
+		}
+	}
+	c.proxyHost = c.proxyHosts[0]
+	_, c.proxyPort, _ = net.SplitHostPort(c.proxyHost)
+	c.forwards = forwards
+	c.Tunnels = c.flatTunnels()
+	return nil
+}
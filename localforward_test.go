@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// localForwardConfig returns a valid config in local forwarding mode.
+func localForwardConfig() config {
+	cfg := validConfig()
+	cfg.TunnelMode = "local"
+	cfg.SSHLocalForwards = []string{"5432:db:5432", "0.0.0.0:6379:cache:6379", "/tmp/api.sock:api:80"}
+	return cfg
+}
+
+func TestParseLocalForward(t *testing.T) {
+	tests := []struct {
+		rule      string
+		wantCheck string
+		ok        bool
+	}{
+		{"5432:db:5432", "127.0.0.1:5432", true},
+		{"127.0.0.2:5432:db:5432", "127.0.0.2:5432", true},
+		{"*:8080:web:80", "127.0.0.1:8080", true},
+		{"[::]:8080:web:80", "[::1]:8080", true},
+		{"[::1]:8080:[2001:db8::1]:80", "[::1]:8080", true},
+		{"/run/web.sock:web:80", "", true},
+		{"8080:web", "", false},
+		{"8080::80", "", false},
+		{"0:web:80", "", false},
+		{"8080:web:99999", "", false},
+		{"a:b:c:d:e", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			forward, err := parseLocalForward(tt.rule)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if forward.check != tt.wantCheck {
+				t.Errorf("check = %q, want %q", forward.check, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestValidate_LocalForwards(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		ok     bool
+	}{
+		{"valid", func(*config) {}, true},
+		{"missing rules", func(c *config) { c.SSHLocalForwards = nil }, false},
+		{"duplicate port", func(c *config) { c.SSHLocalForwards = []string{"5432:db:5432", "127.0.0.2:5432:db2:5432"} }, false},
+		{"sockets only", func(c *config) { c.SSHLocalForwards = []string{"/tmp/a.sock:db:5432"} }, false},
+		{"invalid rule", func(c *config) { c.SSHLocalForwards = []string{"5432:db"} }, false},
+		{"with overlap restart", func(c *config) { c.OverlapRestart = true }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := localForwardConfig()
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLocalForward_ProxyHosts(t *testing.T) {
+	cfg := localForwardConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if want := []string{"127.0.0.1:5432", "127.0.0.1:6379"}; !slices.Equal(cfg.proxyHosts, want) {
+		t.Errorf("proxyHosts = %v, want %v", cfg.proxyHosts, want)
+	}
+	if got := cfg.getPortSpecificPIDFile(); got != "ssh-tunnel-5432.pid" {
+		t.Errorf("PID file = %q, want it suffixed with the first local port", got)
+	}
+
+	joined := strings.Join(cfg.serializeSSHOptions(), " ")
+	for _, want := range []string{"-L 5432:db:5432", "-L 0.0.0.0:6379:cache:6379", "-L /tmp/api.sock:api:80", "-o ExitOnForwardFailure=yes"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "-D ") {
+		t.Errorf("unexpected dynamic forwarding: %s", joined)
+	}
+}
+
+func TestMgmtAPI_StatusForwards(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	cfg := localForwardConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	app.config = &cfg
+
+	app.forwardStates.Store("127.0.0.1:5432", true)
+	app.forwardStates.Store("127.0.0.1:6379", false)
+
+	var body statusResponse
+	decodeBody(t, doRequest(t, http.MethodGet, srv.URL+"/api/v1/status", "", ""), &body)
+	want := []forwardStatus{
+		{Rule: "-L 5432:db:5432", Address: "127.0.0.1:5432", State: forwardStateUp},
+		{Rule: "-L 0.0.0.0:6379:cache:6379", Address: "127.0.0.1:6379", State: forwardStateDown},
+		{Rule: "-L /tmp/api.sock:api:80", State: forwardStateUnchecked},
+	}
+	if !slices.Equal(body.Tunnels[0].Forwards, want) {
+		t.Errorf("forwards = %+v, want %+v", body.Tunnels[0].Forwards, want)
+	}
+}
+
+func TestCheckProxies_RecordsForwardStates(t *testing.T) {
+	app := newTestApp(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+
+	hosts := []string{ln.Addr().String(), closedAddr}
+	if err := app.checkProxies(context.Background(), slog.New(slog.DiscardHandler), hosts); err == nil {
+		t.Fatal("expected the closed port to fail the check")
+	}
+	if up, _ := app.forwardStates.Load(hosts[0]); up != true {
+		t.Errorf("listening port recorded as %v, want true", up)
+	}
+	if up, _ := app.forwardStates.Load(closedAddr); up != false {
+		t.Errorf("closed port recorded as %v, want false", up)
+	}
+}
+
+func TestForwardStatuses_Unknown(t *testing.T) {
+	app := newTestApp(t)
+	statuses := app.forwardStatuses()
+	if len(statuses) != 1 || statuses[0].State != forwardStateUnknown || statuses[0].Rule != "-D 127.0.0.1:8080" {
+		t.Errorf("forwards = %+v, want one unchecked dynamic forward", statuses)
+	}
+}
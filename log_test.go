@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bufferCloser is an in-memory syslog writer.
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+// redirectStd points *std at a temp file for the duration of the test.
+func redirectStd(t *testing.T, std **os.File) *os.File {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), "std")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	original := *std
+	*std = file
+	t.Cleanup(func() {
+		*std = original
+		_ = file.Close()
+	})
+	return file
+}
+
+// readAll returns the contents of path.
+func readAll(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestCreateLogger_File(t *testing.T) {
+	app := newTestApp(t)
+
+	logger, err := app.createLogger()
+	if err != nil {
+		t.Fatalf("createLogger: %v", err)
+	}
+	defer func() { _ = app.logFile.Close() }()
+	logger.Info("hello file")
+
+	if out := readAll(t, app.config.getPortSpecificLogFile()); !strings.Contains(out, "hello file") {
+		t.Errorf("log file missing record: %s", out)
+	}
+}
+
+func TestCreateLogger_Console(t *testing.T) {
+	tests := []struct {
+		output string
+		std    **os.File
+	}{
+		{"stdout", &os.Stdout},
+		{"stderr", &os.Stderr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			app := newTestApp(t)
+			app.config.LogOutput = tt.output
+			file := redirectStd(t, tt.std)
+
+			logger, err := app.createLogger()
+			if err != nil {
+				t.Fatalf("createLogger: %v", err)
+			}
+			logger.Info("hello console")
+
+			if app.logFile != nil {
+				t.Error("console output must not be closed on cleanup")
+			}
+			if _, err := os.Stat(app.config.getPortSpecificLogFile()); !os.IsNotExist(err) {
+				t.Error("log file must not be created for console output")
+			}
+
+			out := readAll(t, file.Name())
+			if !strings.Contains(out, "hello console") {
+				t.Errorf("%s missing record: %s", tt.output, out)
+			}
+			// newTestApp uses a custom LOG_FILE, so the ignored setting is reported
+			if !strings.Contains(out, "Log file setting is ignored") {
+				t.Errorf("%s missing ignored log file warning: %s", tt.output, out)
+			}
+		})
+	}
+}
+
+func TestCreateLogger_Syslog(t *testing.T) {
+	app := newTestApp(t)
+	app.config.LogOutput = "syslog"
+
+	writer := &bufferCloser{}
+	var gotPriority string
+	originalOpenSyslog := openSyslog
+	openSyslog = func(priority string) (io.WriteCloser, error) {
+		gotPriority = priority
+		return writer, nil
+	}
+	t.Cleanup(func() { openSyslog = originalOpenSyslog })
+
+	app.config.SyslogPriority = "LOG_LOCAL0|LOG_NOTICE"
+	logger, err := app.createLogger()
+	if err != nil {
+		t.Fatalf("createLogger: %v", err)
+	}
+	logger.Info("hello syslog")
+
+	if gotPriority != "LOG_LOCAL0|LOG_NOTICE" {
+		t.Errorf("priority = %q, want configured value", gotPriority)
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal(writer.Bytes(), &rec); err != nil {
+		t.Fatalf("syslog record is not JSON: %v", err)
+	}
+	if rec["msg"] != "hello syslog" {
+		t.Errorf("msg = %v, want %q", rec["msg"], "hello syslog")
+	}
+
+	if err := app.logFile.Close(); err != nil || !writer.closed {
+		t.Error("syslog writer should be closed via logFile")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// logErrorKey is the attribute key the tunnel logs errors under.
+const logErrorKey = "error"
+
+// logFieldNames maps the standard top-level log keys to the names written out.
+type logFieldNames struct {
+	message, time, level, error string
+}
+
+// logFieldNames returns the configured field names, keeping slog's for unset ones.
+func (c *config) logFieldNames() logFieldNames {
+	orDefault := func(name, def string) string {
+		if name == "" {
+			return def
+		}
+		return name
+	}
+	return logFieldNames{
+		message: orDefault(c.LogFieldMessage, slog.MessageKey),
+		time:    orDefault(c.LogFieldTime, slog.TimeKey),
+		level:   orDefault(c.LogFieldLevel, slog.LevelKey),
+		error:   orDefault(c.LogFieldError, logErrorKey),
+	}
+}
+
+// validateLogFieldNames rejects field names that would collide in one record.
+func (c *config) validateLogFieldNames() error {
+	names := c.logFieldNames()
+	seen := make(map[string]bool)
+	for _, name := range []string{names.message, names.time, names.level, names.error} {
+		if seen[name] {
+			return fmt.Errorf("log field name %q is used twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// isDefault reports whether no field is renamed.
+func (n logFieldNames) isDefault() bool {
+	return n == logFieldNames{message: slog.MessageKey, time: slog.TimeKey, level: slog.LevelKey, error: logErrorKey}
+}
+
+// replaceBuiltin renames the time, level and message keys, which handlers write
+// themselves and a wrapping handler never sees as attributes. It is meant for
+// slog.HandlerOptions.ReplaceAttr and leaves attributes inside groups alone.
+func (n logFieldNames) replaceBuiltin(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = n.time
+	case slog.LevelKey:
+		a.Key = n.level
+	case slog.MessageKey:
+		a.Key = n.message
+	}
+	return a
+}
+
+// renamingHandler renames top-level error attributes before delegating, so log
+// aggregators find them under the key they expect. Attributes added after a
+// WithGroup are nested and keep their names.
+type renamingHandler struct {
+	handler  slog.Handler
+	errorKey string
+	grouped  bool
+}
+
+// newRenamingHandler wraps handler unless no field is renamed.
+func newRenamingHandler(handler slog.Handler, names logFieldNames) slog.Handler {
+	if names.error == logErrorKey {
+		return handler
+	}
+	return &renamingHandler{handler: handler, errorKey: names.error}
+}
+
+func (h *renamingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *renamingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.grouped {
+		return h.handler.Handle(ctx, r)
+	}
+	renamed := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		renamed.AddAttrs(h.rename(a))
+		return true
+	})
+	return h.handler.Handle(ctx, renamed)
+}
+
+func (h *renamingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if !h.grouped {
+		renamed := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			renamed[i] = h.rename(a)
+		}
+		attrs = renamed
+	}
+	return &renamingHandler{handler: h.handler.WithAttrs(attrs), errorKey: h.errorKey, grouped: h.grouped}
+}
+
+func (h *renamingHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &renamingHandler{handler: h.handler.WithGroup(name), errorKey: h.errorKey, grouped: true}
+}
+
+func (h *renamingHandler) rename(a slog.Attr) slog.Attr {
+	if a.Key == logErrorKey {
+		a.Key = h.errorKey
+	}
+	return a
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// newRenamedLogger builds a logger the way createLogger does, writing to buf.
+func newRenamedLogger(cfg config, format string, buf *bytes.Buffer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	names := cfg.logFieldNames()
+	if !names.isDefault() {
+		opts.ReplaceAttr = names.replaceBuiltin
+	}
+	return slog.New(newRenamingHandler(newLogHandler(format, buf, opts), names))
+}
+
+func renamedConfig() config {
+	cfg := validConfig()
+	cfg.LogFieldMessage = "message"
+	cfg.LogFieldTime = "timestamp"
+	cfg.LogFieldLevel = "status"
+	cfg.LogFieldError = "err"
+	return cfg
+}
+
+func TestRenamingHandler_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRenamedLogger(renamedConfig(), "json", &buf)
+	logger.With("error", "from with").Error("failed", "host", "example.com")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	for _, key := range []string{"message", "timestamp", "status", "err"} {
+		if _, ok := record[key]; !ok {
+			t.Errorf("missing %q in %v", key, record)
+		}
+	}
+	for _, key := range []string{"msg", "time", "level", "error"} {
+		if _, ok := record[key]; ok {
+			t.Errorf("unexpected %q in %v", key, record)
+		}
+	}
+	if record["message"] != "failed" || record["err"] != "from with" {
+		t.Errorf("unexpected values: %v", record)
+	}
+}
+
+func TestRenamingHandler_NestedKeysUnchanged(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRenamedLogger(renamedConfig(), "json", &buf)
+	logger.WithGroup("check").Info("done", "error", errors.New("boom"), slog.Group("inner", "msg", "kept"))
+	logger.Info("inline", slog.Group("probe", "error", "nested"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 records, got %q", buf.String())
+	}
+	var grouped, inline map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &grouped); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &inline); err != nil {
+		t.Fatal(err)
+	}
+
+	check, _ := grouped["check"].(map[string]any)
+	if check["error"] != "boom" {
+		t.Errorf("grouped error should keep its key: %v", grouped)
+	}
+	if inner, _ := check["inner"].(map[string]any); inner["msg"] != "kept" {
+		t.Errorf("nested msg should keep its key: %v", grouped)
+	}
+	if probe, _ := inline["probe"].(map[string]any); probe["error"] != "nested" {
+		t.Errorf("inline group should keep its keys: %v", inline)
+	}
+}
+
+func TestRenamingHandler_Logfmt(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newRenamedLogger(renamedConfig(), "logfmt", &buf)
+	logger.Warn("slow", "error", "timeout")
+
+	line := buf.String()
+	for _, want := range []string{"timestamp=", "status=WARN", "message=slow", "err=timeout"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("missing %s in %q", want, line)
+		}
+	}
+	for _, unwanted := range []string{"time=", "level=", "msg=", "error="} {
+		if strings.Contains(line, unwanted) {
+			t.Errorf("unexpected %s in %q", unwanted, line)
+		}
+	}
+}
+
+func TestRenamingHandler_DefaultsUnwrapped(t *testing.T) {
+	cfg := validConfig()
+	base := newLogHandler("json", &bytes.Buffer{}, nil)
+	if h := newRenamingHandler(base, cfg.logFieldNames()); h != base {
+		t.Errorf("default field names should not wrap the handler")
+	}
+}
+
+func TestValidate_LogFieldNames(t *testing.T) {
+	cfg := renamedConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.LogFieldError = "message"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for duplicate field names")
+	}
+
+	cfg = validConfig()
+	cfg.LogFieldTime = "msg"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for a name clashing with the default message key")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// logfmtHandler is a slog.Handler that writes one key=value line per record.
+type logfmtHandler struct {
+	mu      *sync.Mutex // shared by handlers derived via WithAttrs/WithGroup
+	w       io.Writer
+	level   slog.Leveler
+	replace func([]string, slog.Attr) slog.Attr // applied to the time, level and message keys only
+	attrs   []byte                              // preformatted attrs from WithAttrs, each with a leading space
+	prefix  string                              // dotted group prefix from WithGroup
+}
+
+// newLogfmtHandler returns a handler writing logfmt records to w.
+func newLogfmtHandler(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+	var level slog.Leveler = slog.LevelInfo
+	var replace func([]string, slog.Attr) slog.Attr
+	if opts != nil {
+		if opts.Level != nil {
+			level = opts.Level
+		}
+		replace = opts.ReplaceAttr
+	}
+	return &logfmtHandler{mu: &sync.Mutex{}, w: w, level: level, replace: replace}
+}
+
+func (h *logfmtHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *logfmtHandler) Handle(_ context.Context, r slog.Record) error {
+	buf := make([]byte, 0, 256)
+	if !r.Time.IsZero() {
+		buf = appendPair(buf, h.key(slog.TimeKey), r.Time.Format(time.RFC3339Nano))
+	}
+	buf = appendPair(buf, h.key(slog.LevelKey), r.Level.String())
+	buf = appendPair(buf, h.key(slog.MessageKey), r.Message)
+	buf = append(buf, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		buf = appendAttr(buf, h.prefix, a)
+		return true
+	})
+	buf = append(buf[1:], '\n') // drop the leading space
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *logfmtHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = slices.Clone(h.attrs)
+	for _, a := range attrs {
+		clone.attrs = appendAttr(clone.attrs, h.prefix, a)
+	}
+	return &clone
+}
+
+func (h *logfmtHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// key returns the name written for a built-in key.
+func (h *logfmtHandler) key(builtin string) string {
+	if h.replace == nil {
+		return builtin
+	}
+	return h.replace(nil, slog.String(builtin, "")).Key
+}
+
+// appendAttr formats a, flattening groups into dotted keys.
+func appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			buf = appendAttr(buf, prefix, ga)
+		}
+		return buf
+	}
+
+	var value string
+	if a.Value.Kind() == slog.KindTime {
+		value = a.Value.Time().Format(time.RFC3339Nano)
+	} else {
+		value = a.Value.String()
+	}
+	return appendPair(buf, prefix+a.Key, value)
+}
+
+// appendPair writes " key=value", quoting the value when needed.
+func appendPair(buf []byte, key, value string) []byte {
+	buf = append(buf, ' ')
+	buf = append(buf, key...)
+	buf = append(buf, '=')
+	if needsQuoting(value) {
+		return strconv.AppendQuote(buf, value)
+	}
+	return append(buf, value...)
+}
+
+// needsQuoting reports whether a logfmt value must be quoted.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
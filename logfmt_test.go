@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseLogfmt splits a logfmt line into key/value pairs, unquoting quoted values.
+func parseLogfmt(line string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || key == "" || strings.Contains(key, " ") {
+			return nil, errors.New("missing key=value separator")
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, err
+			}
+			if value, err = strconv.Unquote(quoted); err != nil {
+				return nil, err
+			}
+			rest = rest[len(quoted):]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+			rest = " " + rest
+		}
+
+		fields[key] = value
+		line = strings.TrimLeft(rest, " ")
+	}
+	return fields, nil
+}
+
+func TestLogHandler_FormatsAreParseable(t *testing.T) {
+	for _, format := range []string{"json", "text", "logfmt"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(newLogHandler(format, &buf, nil))
+			logger.Info("tunnel ready", "host", "127.0.0.1:8080", "note", `a "quoted" value`)
+
+			line := strings.TrimSpace(buf.String())
+			fields := map[string]string{}
+			if format == "json" {
+				var rec map[string]any
+				if err := json.Unmarshal([]byte(line), &rec); err != nil {
+					t.Fatalf("invalid JSON %q: %v", line, err)
+				}
+				for k, v := range rec {
+					fields[k], _ = v.(string)
+				}
+			} else {
+				var err error
+				if fields, err = parseLogfmt(line); err != nil {
+					t.Fatalf("invalid logfmt %q: %v", line, err)
+				}
+			}
+
+			if fields["msg"] != "tunnel ready" || fields["host"] != "127.0.0.1:8080" {
+				t.Errorf("unexpected fields: %v", fields)
+			}
+			if fields["note"] != `a "quoted" value` {
+				t.Errorf("note = %q, want quoted value preserved", fields["note"])
+			}
+		})
+	}
+}
+
+func TestLogfmtHandler_AttrsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newLogfmtHandler(&buf, nil)).With("component", "ssh").WithGroup("req")
+	logger.Info("done", "status", 200, slog.Group("timing", "took", time.Second))
+
+	fields, err := parseLogfmt(strings.TrimSpace(buf.String()))
+	if err != nil {
+		t.Fatalf("invalid logfmt %q: %v", buf.String(), err)
+	}
+
+	want := map[string]string{
+		"level":           "INFO",
+		"msg":             "done",
+		"component":       "ssh",
+		"req.status":      "200",
+		"req.timing.took": "1s",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %q, want %q (line: %s)", k, fields[k], v, buf.String())
+		}
+	}
+}
+
+func TestLogfmtHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newLogfmtHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("info record should be filtered: %s", buf.String())
+	}
+
+	logger.Warn("shown")
+	if !strings.Contains(buf.String(), "msg=shown") {
+		t.Errorf("warn record missing: %s", buf.String())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Log components with independently configurable levels.
+const (
+	componentSSH         = "ssh"
+	componentHealthCheck = "healthcheck"
+	componentTunnel      = "tunnel"
+)
+
+// levelHandler filters records below its own level before delegating.
+// It lets loggers sharing one output handler use different levels.
+type levelHandler struct {
+	handler slog.Handler
+	level   slog.Leveler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{handler: h.handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
+
+// parseLogLevel parses a level name such as "debug", "INFO" or "warn+2".
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+// componentLevel returns the configured level for a component, falling back to LogLevel.
+func (c *config) componentLevel(component string) string {
+	var level string
+	switch component {
+	case componentSSH:
+		level = c.LogLevelSSH
+	case componentHealthCheck:
+		level = c.LogLevelHealthCheck
+	case componentTunnel:
+		level = c.LogLevelTunnel
+	}
+	if level == "" {
+		return c.LogLevel
+	}
+	return level
+}
+
+// newLeveledLoggers builds the global logger and one logger per component on top of base.
+// Every logger carries the instance name and the tunnel tags.
+func (c *config) newLeveledLoggers(base slog.Handler) (*slog.Logger, map[string]*slog.Logger, error) {
+	globalLevel, err := parseLogLevel(c.LogLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	attrs := append([]any{"instance_name", c.instanceName()}, c.tagAttrs()...)
+	logger := slog.New(&levelHandler{handler: base, level: globalLevel}).With(attrs...)
+
+	components := make(map[string]*slog.Logger, 3)
+	for _, name := range []string{componentSSH, componentHealthCheck, componentTunnel} {
+		level, err := parseLogLevel(c.componentLevel(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		components[name] = slog.New(&levelHandler{handler: base, level: level}).With(attrs...).With("component", name)
+	}
+
+	return logger, components, nil
+}
+
+// componentLogger returns the logger for a component, tagged with a "component" attribute.
+// Falls back to the global logger when per-component loggers aren't set up (e.g. in tests).
+func (app *Application) componentLogger(name string) *slog.Logger {
+	if logger, ok := app.componentLoggers[name]; ok {
+		return logger
+	}
+	return app.logger.With("component", name)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestComponentLogger_Levels(t *testing.T) {
+	cfg := validConfig()
+	cfg.LogLevel = "info"
+	cfg.LogLevelSSH = "debug"
+	cfg.LogLevelHealthCheck = "warn"
+
+	var buf bytes.Buffer
+	logger, components, err := cfg.newLeveledLoggers(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if err != nil {
+		t.Fatalf("newLeveledLoggers: %v", err)
+	}
+	app := &Application{logger: logger, componentLoggers: components}
+
+	app.componentLogger(componentSSH).Debug("ssh debug")
+	app.componentLogger(componentHealthCheck).Info("check info")
+	app.componentLogger(componentHealthCheck).Warn("check warn")
+	app.componentLogger(componentTunnel).Debug("tunnel debug")
+	app.componentLogger(componentTunnel).Info("tunnel info")
+	app.logger.Debug("global debug")
+
+	out := buf.String()
+	for _, want := range []string{"ssh debug", "check warn", "tunnel info"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"check info", "tunnel debug", "global debug"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q in output:\n%s", unwanted, out)
+		}
+	}
+	if !strings.Contains(out, "component=ssh") || !strings.Contains(out, "component=healthcheck") {
+		t.Errorf("component attribute missing:\n%s", out)
+	}
+}
+
+func TestComponentLogger_Fallback(t *testing.T) {
+	var buf bytes.Buffer
+	app := &Application{logger: slog.New(slog.NewTextHandler(&buf, nil))}
+
+	app.componentLogger(componentSSH).Info("hello")
+	if !strings.Contains(buf.String(), "component=ssh") {
+		t.Errorf("fallback logger missing component attribute: %s", buf.String())
+	}
+}
+
+func TestComponentLogger_InstanceName(t *testing.T) {
+	for _, tt := range []struct{ name, want string }{{"", "8080"}, {"db-proxy", "db-proxy"}} {
+		cfg := validConfig()
+		cfg.LogLevel = "info"
+		cfg.InstanceName = tt.name
+		if err := cfg.validate(); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+
+		var buf bytes.Buffer
+		logger, components, err := cfg.newLeveledLoggers(slog.NewTextHandler(&buf, nil))
+		if err != nil {
+			t.Fatalf("newLeveledLoggers: %v", err)
+		}
+		logger.Info("global")
+		components[componentSSH].Info("ssh")
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if !strings.Contains(line, "instance_name="+tt.want) {
+				t.Errorf("instance name %q: want instance_name=%s in %s", tt.name, tt.want, line)
+			}
+		}
+	}
+}
@@ -1,50 +1,173 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"strconv"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/net/http2"
 	"golang.org/x/net/proxy"
 )
 
 // Application is the root state of the ssh-tunnel service.
 type Application struct {
-	config        *config         // parsed configuration
-	httpTransport *http.Transport // SOCKS5-based transport for traffic checks
-	logger        *slog.Logger    // structured logger
-	logFile       *os.File        // log file handle
-	sshProcess    *exec.Cmd       // current SSH child process
-	sshMutex      sync.RWMutex    // protects sshProcess
-	shutdownChan  chan struct{}   // closed on shutdown signal
+	config           *config                 // parsed configuration
+	httpTransport    *http.Transport         // SOCKS5-based transport for traffic checks
+	resolver         *net.Resolver           // DNS resolver for traffic checks, nil for the system default
+	logger           *slog.Logger            // structured logger for components without their own level
+	componentLoggers map[string]*slog.Logger // per-component loggers, see componentLogger
+	logFile          io.Closer               // log file or syslog handle, nil for stdout/stderr
+	auditLogger      *slog.Logger            // security audit logger, nil when disabled
+	auditFile        *os.File                // audit log file handle
+	sshProcess       *exec.Cmd               // current SSH child process
+	sshMutex         sync.RWMutex            // protects sshProcess
+	configMutex      sync.RWMutex            // guards config against reloads from the main loop
+	checkMutex       sync.Mutex              // held while a traffic check runs, see checkTraffic
+	mgmtServer       *http.Server            // management API server, nil when disabled
+	pprofServer      *http.Server            // net/http/pprof server, nil when disabled
+	accessFilter     net.Listener            // source address filter in front of ssh's SOCKS port, nil when disabled
+	shutdownChan     chan struct{}           // closed on shutdown signal
+	restartChan      chan struct{}           // restart requests from the management API
+	processDied      chan struct{}           // the current SSH process exited on its own, see watchSSHProcess
+	masterDied       chan struct{}           // the control master stopped answering, see runControlMasterCheck
+	reloadChan       chan *config            // validated config updates from the management API
+	pauseChan        chan os.Signal          // pause/resume signals, nil unless SIGUSR1_ACTION=pause
+	tunnelDown       bool                    // last traffic check failed; only touched by the main loop
+	restartID        string                  // current restart cycle ID until its first check; main loop only
+	restartTimes     []time.Time             // restarts within restartWindow, oldest first; main loop only
+	healthResults    []bool                  // outcomes of the last HealthWindowSize checks, oldest first; main loop only
+	hostKey          string                  // SSH server fingerprints from the last scan, see checkHostKey; guarded by sshMutex
+	daemonPID        int                     // PID of the --daemon background process, 0 in the foreground
+	agentPID         int                     // ssh-agent started by ensureAgent, 0 if none; stopped in cleanup
+	baseGoroutines   int                     // goroutines at startup, see goroutineLeakCheck
+	restartStrategy  restartStrategy         // how restartTunnel replaces the SSH process, see newRestartStrategy
+	sshOutputFilter  *sshOutputFilter        // compiled SSH_OUTPUT_FILTER patterns, nil when unset
+
+	// Tunnel health counters, read via Stats()
+	totalRestarts       atomic.Int64 // restarts since startup
+	consecutiveFailures atomic.Int64 // failed traffic checks since the last success
+	lastRestartTime     atomic.Int64 // Unix nanoseconds, 0 if never restarted
+	tunnelUpSince       atomic.Int64 // Unix nanoseconds, 0 while down
+	lastCheckSuccess    atomic.Int64 // Unix nanoseconds of the last successful traffic check
+	currentState        atomic.Int32 // tunnelState, updated via setState
+	bytesIn             atomic.Int64 // bytes read by the current SSH process
+	bytesOut            atomic.Int64 // bytes written by the current SSH process
+	paused              atomic.Bool  // SSH process stopped via SIGUSR1; health checks are skipped
+	lastLoopTick        atomic.Int64 // Unix nanoseconds of the last main loop tick, for /livez
+	loopBusy            atomic.Bool  // main loop is handling a tick, restart or reload, for /livez
+	tunnelStarted       atomic.Bool  // the tunnel has become ready at least once, for /startupz
+	lastRequestID       atomic.Value // string ID of the last traffic check request, for /api/v1/status
+
+	overlapChecksSkipped atomic.Int64 // traffic checks skipped because the previous one was still running
+	forwardStates        sync.Map     // proxy address to bool, whether its last port check succeeded
+	tickTimeouts         atomic.Int64 // main loop ticks cut off by TickTimeout
+
+	processExits sync.Map // *exec.Cmd to *processExit of watched SSH processes, see watchSSHProcess
+
+	sessionMutex sync.Mutex   // guards sessionLog
+	sessionLog   []SessionLog // last maxSessions SSH processes, oldest first, see recordSessionStart
+
+	metrics metricsCollector // durations of checks and SSH starts and stops, read via Stats()
+	quality qualityTracker   // recent port check round trips, read via Stats()
+
+	retryBudget retryBudget // tokens for retries of all components, see configureRetryBudget
+
+	// float64 values stored as bits, see storeFloat
+	sshCPUPercent atomic.Uint64 // CPU usage of the current SSH process
+	sshMemoryMB   atomic.Uint64 // resident memory of the current SSH process
+	healthScore   atomic.Uint64 // share of successful checks in the health window, see recordHealth
+
+	portCheckRTT atomic.Int64 // moving average of port check round trips in nanoseconds, see loopSleep
 }
 
+// sshBinary is the SSH client executable and is replaced in tests.
+var sshBinary = "ssh"
+
 // checkProcessAlive points to the platform process check and is replaced in tests.
 var checkProcessAlive = isProcessAlive
 
 func main() {
+	dryRun := flag.Bool("dry-run", false, "validate config and connectivity without starting the tunnel")
+	selfTest := flag.Bool("self-test", false, "start the tunnel, check traffic through it once and exit")
+	daemon := flag.Bool("daemon", false, "run in the background, logging to the log file")
+	envPrefix := flag.String("env-prefix", defaultEnvPrefix, "prefix of the configuration variables")
+	flag.Parse()
+
 	// Initialize configuration
-	config, err := newConfig()
+	cfg, err := newConfig(*envPrefix)
 	if err != nil {
 		slog.Error("Failed to load configuration", "error", err)
 		os.Exit(1)
 	}
+	if *dryRun {
+		cfg.DryRun = true
+	}
+	if *selfTest {
+		cfg.SelfTest = true
+	}
+	if cfg.usesStateDir() {
+		dir, err := stateDirFor(runtime.GOOS, os.Getenv)
+		if err != nil {
+			slog.Warn("No state directory, keeping the default PID and log files in the working directory", "error", err)
+		}
+		cfg.stateDir = dir
+	}
 
 	// Initialize application
 	app := &Application{
-		config:       config,
-		shutdownChan: make(chan struct{}),
+		config:          cfg,
+		shutdownChan:    make(chan struct{}),
+		restartChan:     make(chan struct{}, 1),
+		processDied:     make(chan struct{}, 1),
+		masterDied:      make(chan struct{}, 1),
+		reloadChan:      make(chan *config, 1),
+		restartStrategy: newRestartStrategy(cfg),
+	}
+
+	if cfg.DryRun {
+		app.logger = slog.Default()
+		if err := app.dryRun(os.Stdout); err != nil {
+			slog.Error("Dry run failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.SelfTest {
+		app.logger = slog.Default()
+		if err := app.selfTest(os.Stdout); err != nil {
+			slog.Error("Self-test failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *daemon {
+		app.logger = slog.Default()
+		started, err := app.daemonize()
+		if err != nil {
+			slog.Error("Failed to start daemon", "error", err)
+			os.Exit(1)
+		}
+		if started {
+			return
+		}
 	}
 
 	if err := app.initialize(); err != nil {
@@ -59,6 +182,19 @@ func main() {
 
 // initialize sets up the application components.
 func (app *Application) initialize() error {
+	// Before anything below starts a goroutine
+	app.baseGoroutines = runtime.NumGoroutine()
+
+	// Select a free proxy port before the port-specific file names are used
+	portSelected, err := app.config.autoSelectPort()
+	if err != nil {
+		return fmt.Errorf("port selection failed: %w", err)
+	}
+
+	if err := app.config.createStateDir(); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
 	// Initialize logger
 	logger, err := app.createLogger()
 	if err != nil {
@@ -66,11 +202,79 @@ func (app *Application) initialize() error {
 	}
 	app.logger = logger
 
+	if portSelected {
+		logger.Warn("Configured proxy port is in use, selected the next free port",
+			"configured", app.config.selectedFrom, "bind", app.config.SSHBindHost)
+	}
+
+	// Compile SSH output patterns
+	filter, err := newSSHOutputFilter(app.config.SSHOutputFilter, app.config.SSHOutputPromotePattern)
+	if err != nil {
+		return err
+	}
+	app.sshOutputFilter = filter
+
+	// Initialize audit logger
+	auditLogger, err := app.createAuditLogger()
+	if err != nil {
+		return fmt.Errorf("audit logger initialization failed: %w", err)
+	}
+	app.auditLogger = auditLogger
+
 	// Create PID file
 	if pidErr := app.createPIDFile(); pidErr != nil {
 		return fmt.Errorf("PID file creation failed: %w", pidErr)
 	}
 
+	if len(app.config.TunnelTags) > maxTunnelTagKeys {
+		logger.Warn("Many tunnel tags, each key adds to the cardinality of log and alert labels",
+			"tag_keys", len(app.config.TunnelTags), "recommended_max", maxTunnelTagKeys)
+	}
+
+	if app.config.SSHPostQuantumOnly {
+		_, ok := app.config.postQuantumKex()
+		switch {
+		case app.config.sshVersion == (openSSHVersion{}):
+			logger.Warn("Could not detect the ssh version, assuming it supports post-quantum key exchange")
+		case !ok:
+			logger.Warn("ssh has no post-quantum key exchange, using "+classicalKex,
+				"ssh_version", app.config.sshVersion.String(), "required_version", sntrupKexVersion.String())
+		}
+	}
+
+	if !app.config.SSHNoTTY && app.config.TunnelMode == tunnelModeDynamic {
+		logger.Warn("A pseudo-terminal is requested for a pure SOCKS forward, which rarely needs one", "no_tty", false)
+	}
+
+	app.configureRetryBudget()
+	app.warnUnusualRemotePorts(logger)
+
+	// Sessions of earlier runs
+	if err := app.loadSessionLog(); err != nil {
+		logger.Warn("Starting with an empty session log", "error", err)
+	}
+
+	// Write the identity from the environment for ssh -i
+	if app.config.SSHIdentityEnvVar != "" && app.config.SSHIdentityFile != "" {
+		logger.Warn("Both an identity environment variable and an identity file are set, using the environment variable",
+			"identity_env_var", app.config.SSHIdentityEnvVar)
+	}
+	if err := app.config.writeIdentityKey(nil); err != nil {
+		return err
+	}
+
+	// Create SSH control socket directory
+	if app.config.SSHControlMaster {
+		if err := app.config.createControlSocketDir(); err != nil {
+			return err
+		}
+	}
+
+	// Take over the bind host before the traffic checks are pointed at ssh's port
+	if err := app.startAccessFilter(); err != nil {
+		return fmt.Errorf("access filter initialization failed: %w", err)
+	}
+
 	// Setup HTTP transport
 	transport, err := app.createHTTPTransport()
 	if err != nil {
@@ -78,45 +282,156 @@ func (app *Application) initialize() error {
 	}
 	app.httpTransport = transport
 
+	// Start management API
+	if err := app.startMgmtServer(); err != nil {
+		return fmt.Errorf("management API initialization failed: %w", err)
+	}
+
+	// Start profiling server
+	if err := app.startPprofServer(); err != nil {
+		return fmt.Errorf("profiling server initialization failed: %w", err)
+	}
+
 	// Setup signal handling
 	app.setupSignalHandler()
 
+	// Watch the config file for changes
+	if app.config.WatchConfig {
+		go app.watchConfigFile(statConfigFile(app.config.ConfigFile))
+	}
+
+	// Start bandwidth monitoring
+	if app.config.BandwidthMonitor {
+		go app.runBandwidthMonitor()
+	}
+
+	// Start resource monitoring
+	if app.config.MonitorResources {
+		go app.runResourceMonitor()
+	}
+
+	// Watch the control master, which traffic checks don't see directly
+	if app.config.SSHControlMasterAliveInterval > 0 {
+		go app.runControlMasterCheck(app.config.SSHControlMasterAliveInterval)
+	}
+
 	return nil
 }
 
-// createLogger initializes the application logger.
+// createLogger initializes the application logger for the configured output.
 func (app *Application) createLogger() (*slog.Logger, error) {
-	logFile := filepath.Clean(app.config.getPortSpecificLogFile())
-	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	var out io.Writer
+	switch app.config.LogOutput {
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	case "syslog":
+		writer, err := openSyslog(app.config.SyslogPriority)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open syslog: %w", err)
+		}
+		app.logFile = writer
+		out = writer
+	default:
+		logFile := filepath.Clean(app.config.getPortSpecificLogFile())
+		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		app.logFile = file
+
+		out = file
+		if app.config.LogStdout {
+			out = io.MultiWriter(file, os.Stdout)
+		}
+	}
+
+	// The base handler accepts everything; levels are applied per logger
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	names := app.config.logFieldNames()
+	if !names.isDefault() {
+		opts.ReplaceAttr = names.replaceBuiltin
+	}
+	base := newRenamingHandler(newLogHandler(app.config.LogFormat, out, opts), names)
+	logger, components, err := app.config.newLeveledLoggers(base)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+		return nil, err
 	}
-	app.logFile = file
+	app.componentLoggers = components
 
-	var out io.Writer = file
-	if app.config.LogStdout {
-		out = io.MultiWriter(file, os.Stdout)
+	isConsole := app.config.LogOutput == "stdout" || app.config.LogOutput == "stderr"
+	if isConsole && app.config.LogFile != defaultLogFile {
+		logger.Warn("Log file setting is ignored for console output", "log_file", app.config.LogFile, "log_output", app.config.LogOutput)
 	}
 
-	return slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	})), nil
+	return logger, nil
+}
+
+// newLogHandler returns the slog handler for the configured log format.
+func newLogHandler(format string, out io.Writer, opts *slog.HandlerOptions) slog.Handler {
+	switch format {
+	case "text":
+		return slog.NewTextHandler(out, opts)
+	case "logfmt":
+		return newLogfmtHandler(out, opts)
+	default:
+		return slog.NewJSONHandler(out, opts)
+	}
 }
 
 // createHTTPTransport creates a configured HTTP transport.
 func (app *Application) createHTTPTransport() (*http.Transport, error) {
-	dialer, err := proxy.SOCKS5("tcp", app.config.proxyHost, nil, &net.Dialer{
-		Timeout: app.config.PortCheckTimeout,
-	})
+	app.resolver = newResolver(app.config.TrafficCheckDNSServer, app.config.PortCheckTimeout)
+
+	dialer, err := proxy.SOCKS5("tcp", app.config.proxyHost, nil, app.config.dialer(app.newProxyDialer()))
 	if err != nil {
 		return nil, err
 	}
 
 	dialContext := app.makeSocksDialContext(dialer)
 
-	return &http.Transport{
-		DialContext: dialContext,
-	}, nil
+	transport := &http.Transport{
+		DialContext:           dialContext,
+		MaxIdleConns:          app.config.HTTPMaxIdleConns,
+		MaxConnsPerHost:       app.config.HTTPMaxConnsPerHost,
+		IdleConnTimeout:       app.config.HTTPIdleConnTimeout,
+		TLSHandshakeTimeout:   app.config.HTTPTLSHandshakeTimeout,
+		ExpectContinueTimeout: app.config.HTTPExpectContinueTimeout,
+		DisableKeepAlives:     app.config.HTTPDisableKeepAlives,
+		TLSClientConfig:       app.config.trafficCheckTLSConfig(),
+	}
+
+	// A custom DialContext turns off HTTP/2; offer it via ALPN again, falling back to
+	// HTTP/1.1 when the endpoint doesn't pick it
+	if app.config.TrafficCheckHTTP2 {
+		if err := http2.ConfigureTransport(transport); err != nil {
+			return nil, fmt.Errorf("failed to enable HTTP/2: %w", err)
+		}
+	}
+	return transport, nil
+}
+
+// newProxyDialer returns the dialer for connections to the SOCKS5 proxy.
+// Pooled connections may stay open for a while, so they send TCP keepalives.
+func (app *Application) newProxyDialer() *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:  app.config.PortCheckTimeout,
+		Resolver: app.resolver,
+	}
+
+	if app.config.TCPKeepAliveInterval == 0 {
+		dialer.KeepAlive = -1
+		return dialer
+	}
+	// Count is ignored on platforms without TCP_KEEPCNT; 0 keeps the system default
+	dialer.KeepAliveConfig = net.KeepAliveConfig{
+		Enable:   true,
+		Idle:     app.config.TCPKeepAliveInterval,
+		Interval: app.config.TCPKeepAliveInterval,
+		Count:    app.config.TCPKeepAliveCount,
+	}
+	return dialer
 }
 
 // makeSocksDialContext wraps a SOCKS5 dialer into a context-aware DialContext function.
@@ -171,7 +486,12 @@ func (app *Application) resolveAddr(ctx context.Context, addr string) (string, e
 		return addr, nil
 	}
 
-	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	resolver := app.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ips, err := resolver.LookupIPAddr(ctx, host)
 	if err != nil {
 		return "", err
 	}
@@ -181,6 +501,22 @@ func (app *Application) resolveAddr(ctx context.Context, addr string) (string, e
 	return net.JoinHostPort(ips[0].IP.String(), port), nil
 }
 
+// newResolver returns a resolver that queries server directly, bypassing the
+// system configuration. Returns nil (system resolver) when server is empty.
+func newResolver(server string, timeout time.Duration) *net.Resolver {
+	if server == "" {
+		return nil
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: timeout}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
 // setupSignalHandler configures OS signal handling.
 func (app *Application) setupSignalHandler() {
 	sigCh := make(chan os.Signal, 1)
@@ -189,149 +525,735 @@ func (app *Application) setupSignalHandler() {
 	go func() {
 		sig := <-sigCh
 		app.logger.Info("Received signal, shutting down", "signal", sig)
+		app.audit(auditSignalReceived, "signal", sig.String())
 		close(app.shutdownChan)
 	}()
+
+	if app.config.SIGUSR1Action == sigusr1ActionPause {
+		app.pauseChan = make(chan os.Signal, 1)
+		signal.Notify(app.pauseChan, pauseSignals()...)
+	}
 }
 
 // run executes the main application loop.
 func (app *Application) run() {
-	app.logger.Info("Starting SSH tunnel application")
+	app.logStartupBanner()
+	app.logCertificate()
+	app.resetHealth()
+
+	if !app.waitForStartup() {
+		app.logger.Info("Shutting down...")
+		return
+	}
 
-	ticker := time.NewTicker(app.config.MainLoopSleep)
+	ticker := time.NewTicker(app.loopSleep())
 	defer ticker.Stop()
 
+	// The tunnel was ready at startup, so idle time counts from here
+	app.lastCheckSuccess.CompareAndSwap(0, time.Now().UnixNano())
+	idleTimer := time.NewTimer(0)
+	defer idleTimer.Stop()
+	app.resetIdleTimer(idleTimer)
+
 	for {
+		app.markLoopActive(false)
 		select {
 		case <-app.shutdownChan:
 			app.logger.Info("Shutting down...")
 			return
 		case <-ticker.C:
-			if !app.checkTraffic() {
+			app.markLoopActive(true)
+			// Ticker drops ticks nobody receives, so skipped checks don't pile up
+			if app.paused.Load() {
+				continue
+			}
+			if !app.sleepJitter() {
+				continue
+			}
+			app.tick()
+			if app.config.AdaptiveLoop {
+				ticker.Reset(app.loopSleep())
+			}
+		case <-idleTimer.C:
+			app.markLoopActive(true)
+			idleTimer.Reset(app.checkIdle(time.Now()))
+		case <-app.restartChan:
+			app.markLoopActive(true)
+			app.logger.Info("Restart requested via management API")
+			app.restartTunnel()
+		case <-app.processDied:
+			app.markLoopActive(true)
+			// A traffic check may have restarted it in the meantime
+			app.sshMutex.RLock()
+			running := app.isProcessRunning(app.sshProcess)
+			app.sshMutex.RUnlock()
+			if !running {
 				app.restartTunnel()
 			}
+		case <-app.masterDied:
+			app.markLoopActive(true)
+			app.restartTunnel()
+		case sig := <-app.pauseChan:
+			app.markLoopActive(true)
+			app.handlePauseSignal(sig)
+		case cfg := <-app.reloadChan:
+			app.markLoopActive(true)
+			app.applyConfig(cfg)
+			ticker.Reset(app.loopSleep())
+			app.resetIdleTimer(idleTimer)
+		}
+	}
+}
+
+// tick checks the traffic and restarts the tunnel if the check calls for it. With
+// TickTimeout set, both share that budget: an expired budget cancels whatever is
+// still running and the loop waits for the next tick.
+func (app *Application) tick() {
+	ctx := context.Background()
+	if app.config.TickTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, app.config.TickTimeout)
+		defer cancel()
+	}
+
+	err := app.checkTrafficContext(ctx)
+	if errors.Is(err, errCheckInProgress) {
+		return
+	}
+	// A check cut off by the budget says nothing about the tunnel
+	if ctx.Err() == nil {
+		app.recordCheckResult(err)
+		app.restartID = ""
+		if err != nil && app.restartOnFailure(err) || err == nil && app.qualityDegraded() {
+			app.restartTunnelContext(ctx)
+		}
+	}
+
+	if ctx.Err() != nil {
+		app.tickTimeouts.Add(1)
+		app.componentLogger(componentTunnel).Error("Main loop tick timed out, waiting for the next one",
+			"tick_timeout", app.config.TickTimeout)
+	}
+}
+
+// applyConfig swaps in a reloaded config and restarts the tunnel if SSH arguments changed.
+// Only the main loop writes the config, so reads from the loop itself need no lock.
+func (app *Application) applyConfig(cfg *config) {
+	logger := app.componentLogger(componentTunnel)
+
+	// The access filter keeps its listener until restart; only the allowed networks reload
+	if app.accessFilter != nil {
+		if len(cfg.allowedNets) == 0 {
+			logger.Warn("Turning off the access filter takes a restart, keeping the allowed hosts")
+			cfg.AllowHosts, cfg.allowedNets = app.config.AllowHosts, app.config.allowedNets
+		}
+		cfg.activeBindHost = app.config.activeBindHost
+		if err := cfg.deriveProxyHost(); err != nil {
+			logger.Error("Failed to keep ssh behind the access filter", "error", err)
 		}
 	}
+
+	// The loggers carry the tags they were built with
+	if !maps.Equal(cfg.TunnelTags, app.config.TunnelTags) {
+		logger.Warn("Changing the tunnel tags takes a restart, keeping the current tags")
+		cfg.TunnelTags = app.config.TunnelTags
+	}
+	// The name is in the loggers and the PID and log file names
+	if cfg.InstanceName != app.config.InstanceName {
+		logger.Warn("Changing the instance name takes a restart, keeping the current name",
+			"instance_name", app.config.instanceName())
+		cfg.InstanceName = app.config.InstanceName
+	}
+	// The state directory is resolved once at startup, the PID file stays where it is
+	cfg.stateDir = app.config.stateDir
+
+	// Keep the address resolved at the last start while the server name is unchanged.
+	// A reloaded config starts out on the primary, so a fallback's address is dropped.
+	cfg.resolvedHost = ""
+	if cfg.SSHResolveOnRestart && cfg.SSHRemoteAddress == app.config.SSHRemoteAddress && !app.config.useFallback {
+		cfg.resolvedHost = app.config.resolvedHost
+	}
+
+	// Reuse the identity file while the key from the environment is unchanged
+	oldIdentityKeyFile := app.config.identityKeyFile
+	if err := cfg.writeIdentityKey(app.config); err != nil {
+		logger.Error("Failed to write identity file", "error", err)
+	}
+
+	oldArgs := strings.Join(app.config.serializeSSHOptions(), " ")
+	changed := Diff(app.config, cfg)
+
+	app.configMutex.Lock()
+	*app.config = *cfg
+	app.configMutex.Unlock()
+	if oldIdentityKeyFile != "" && oldIdentityKeyFile != app.config.identityKeyFile {
+		if err := os.RemoveAll(filepath.Dir(oldIdentityKeyFile)); err != nil {
+			logger.Error("Failed to remove identity file", "error", err)
+		}
+	}
+	app.restartStrategy = newRestartStrategy(app.config)
+	app.configureRetryBudget()
+
+	logger.Info("Configuration reloaded", "changed", changed, "config", app.config.String())
+	app.audit(auditConfigReload, "changed", changed)
+
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		logger.Error("Failed to recreate HTTP transport", "error", err)
+	} else {
+		app.httpTransport.CloseIdleConnections()
+		app.httpTransport = transport
+	}
+
+	if strings.Join(app.config.serializeSSHOptions(), " ") != oldArgs {
+		logger.Info("SSH options changed, restarting tunnel")
+		app.restartTunnel()
+	}
+}
+
+// recordCheckResult tracks tunnel up/down transitions and sends webhook notifications on change.
+func (app *Application) recordCheckResult(err error) {
+	app.recordCheckStats(err == nil)
+	app.recordHealth(err == nil)
+
+	switch {
+	case err != nil && !app.tunnelDown:
+		app.tunnelDown = true
+		app.notifyWebhook(webhookTunnelDown, err.Error())
+	case err == nil && app.tunnelDown:
+		app.tunnelDown = false
+		app.notifyWebhook(webhookTunnelRecovered, "")
+	}
 }
 
 // restartTunnel stops and starts the SSH tunnel.
+// Every record of the cycle, up to the first traffic check, carries the same restart_id.
 func (app *Application) restartTunnel() {
-	app.stopSSH()
-	if err := app.startSSH(); err != nil {
-		app.logger.Error("Failed to restart SSH tunnel", "error", err)
+	app.restartTunnelContext(context.Background())
+}
+
+// restartTunnelContext is restartTunnel that gives up on the restart once ctx is done.
+func (app *Application) restartTunnelContext(ctx context.Context) {
+	if app.paused.Load() {
+		app.componentLogger(componentTunnel).Warn("Tunnel is paused, skipping restart")
+		return
 	}
+
+	if !app.allowRestart(time.Now()) {
+		app.componentLogger(componentTunnel).Error("Restart limit reached, skipping restart",
+			"max_restarts_per_hour", app.config.MaxRestartsPerHour, "next_allowed", app.restartTimes[0].Add(restartWindow))
+		return
+	}
+
+	app.restartID = newUUID()
+	app.recordRestart()
+	app.resetHealth()
+
+	sshLogger := app.componentLogger(componentSSH).With("restart_id", app.restartID)
+	if err := app.restartStrategy(ctx, app, sshLogger); err != nil {
+		app.componentLogger(componentTunnel).Error("Failed to restart SSH tunnel", "error", err, "restart_id", app.restartID)
+		return
+	}
+
+	// Pooled connections went through the old process and are dead now; the next
+	// traffic check must dial the new one instead of failing on a stale connection
+	app.httpTransport.CloseIdleConnections()
 }
 
-// checkTraffic verifies if the tunnel is functioning properly.
-func (app *Application) checkTraffic() bool {
-	if !app.checkPort() {
+// restartWindow is the sliding window for MaxRestartsPerHour.
+const restartWindow = time.Hour
+
+// allowRestart reports whether a restart at now fits in MaxRestartsPerHour and, if so, records it.
+// Only called from the main loop.
+func (app *Application) allowRestart(now time.Time) bool {
+	if app.config.MaxRestartsPerHour <= 0 {
+		return true
+	}
+
+	// Drop restarts that have left the window
+	cutoff := now.Add(-restartWindow)
+	i := 0
+	for i < len(app.restartTimes) && !app.restartTimes[i].After(cutoff) {
+		i++
+	}
+	app.restartTimes = app.restartTimes[i:]
+
+	if len(app.restartTimes) >= app.config.MaxRestartsPerHour {
 		return false
 	}
+	app.restartTimes = append(app.restartTimes, now)
+	return true
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID, used for restart and request IDs.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // never fails since Go 1.24
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// checkLogger returns the health check logger, tagged with the restart ID until
+// the first check after a restart has completed.
+func (app *Application) checkLogger() *slog.Logger {
+	logger := app.componentLogger(componentHealthCheck)
+	if app.restartID != "" {
+		logger = logger.With("restart_id", app.restartID)
+	}
+	return logger
+}
+
+// errCheckInProgress is returned by checkTraffic while another check is still running.
+var errCheckInProgress = errors.New("traffic check already in progress")
+
+// checkTraffic verifies if the tunnel is functioning properly.
+// Returns the failure reason, or nil if the tunnel is healthy.
+// At most one check runs at a time; an overlapping call returns errCheckInProgress.
+func (app *Application) checkTraffic() error {
+	return app.checkTrafficContext(context.Background())
+}
+
+// checkTrafficContext is checkTraffic with a context that can abort the check.
+func (app *Application) checkTrafficContext(ctx context.Context) (err error) {
+	if !app.checkMutex.TryLock() {
+		app.overlapChecksSkipped.Add(1)
+		app.checkLogger().Warn("Previous traffic check is still running, skipping check")
+		return errCheckInProgress
+	}
+	defer app.checkMutex.Unlock()
+
+	defer func() {
+		if err != nil {
+			app.markDegraded()
+		} else {
+			app.setState(StateRunning)
+		}
+	}()
+
+	return app.checkTrafficWithRetry(ctx)
+}
+
+// checkTrafficWithRetry runs up to TrafficCheckRetries+1 traffic checks, TrafficCheckRetryDelay
+// apart, until one succeeds. Failed attempts are logged at DEBUG, only the final one at ERROR.
+// Each retry takes a token from the retry budget; without one the check fails right away.
+func (app *Application) checkTrafficWithRetry(ctx context.Context) error {
+	logger := app.checkLogger()
+	retries := max(app.config.TrafficCheckRetries, 0)
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := app.checkTrafficOnce(ctx, logger)
+		if err == nil {
+			return nil
+		}
+		// Nothing listens on the port, another attempt won't bring it back
+		final := attempt > retries || errors.Is(err, errPortRefused)
+		if !final && !app.retryBudget.take(time.Now()) {
+			logger.Warn("Retry budget exhausted, not retrying the traffic check")
+			final = true
+		}
+		if final {
+			logger.Error("Traffic check failed", "attempts", attempt, "error", err, "elapsed", time.Since(start))
+			return err
+		}
+		logger.Debug("Traffic check attempt failed", "attempt", attempt, "error", err, "elapsed", time.Since(start))
+
+		timer := time.NewTimer(app.config.TrafficCheckRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-app.shutdownChan:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// checkTrafficOnce performs a single traffic check through the proxy.
+func (app *Application) checkTrafficOnce(ctx context.Context, logger *slog.Logger) (err error) {
+	portCheckStart := time.Now()
+	if err := app.checkPortContext(ctx, logger); err != nil {
+		app.quality.observe(0, false)
+		return fmt.Errorf("proxy port unavailable: %w", err)
+	}
+	rtt := time.Since(portCheckStart)
+	app.recordPortCheckRTT(rtt)
+	app.quality.observe(rtt, true)
+
+	// Forwarded ports lead to fixed services, there's no proxy to send traffic through
+	switch app.config.TunnelMode {
+	case tunnelModeRemote:
+		if app.config.SSHRemoteForwardCheckURL != "" {
+			return app.checkRemoteForwardURL(ctx, logger)
+		}
+		return nil
+	case tunnelModeLocal:
+		return nil
+	}
+
+	if app.config.TrafficCheckMode == trafficCheckSocks5Connect {
+		ctx, cancel := context.WithTimeout(ctx, socks5CheckTimeout)
+		defer cancel()
+		if !app.checkViaSocks5Connect(ctx) {
+			return errors.New("SOCKS5 CONNECT check failed")
+		}
+		return nil
+	}
 
 	client := &http.Client{
 		Transport: app.httpTransport,
 		Timeout:   10 * time.Second,
 	}
 
-	req, err := http.NewRequest(http.MethodHead, "https://google.com", nil)
+	req, err := app.newTrafficCheckRequest()
 	if err != nil {
-		app.logger.Error("Failed to create request", "error", err)
-		return false
+		logger.Error("Failed to create request", "error", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req = req.WithContext(ctx)
+	logger = logger.With("request_id", req.Header.Get(requestIDHeader))
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	app.metrics.since(metricTrafficCheck, start)
 	if err != nil {
-		app.logger.Error("Traffic check failed", "error", err)
-		return false
+		return fmt.Errorf("traffic check failed: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			app.logger.Error("Failed to close response body", "error", err)
+			logger.Error("Failed to close response body", "error", err)
 		}
 	}()
 
-	return resp.StatusCode >= 200 && resp.StatusCode < 400
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
 }
 
-// checkPort verifies if the proxy port is available.
-func (app *Application) checkPort() bool {
-	conn, err := net.DialTimeout("tcp", app.config.proxyHost, app.config.PortCheckTimeout)
+// requestIDHeader carries the ID of a traffic check request, for matching server-side access logs.
+const requestIDHeader = "X-Request-ID"
+
+// newTrafficCheckRequest builds the HEAD request of an HTTP traffic check
+// with a fresh request ID, which is remembered for /api/v1/status.
+func (app *Application) newTrafficCheckRequest() (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodHead, "https://google.com", nil)
 	if err != nil {
-		app.logger.Error("Proxy port unavailable", "host", app.config.proxyHost, "error", err)
-		return false
+		return nil, err
 	}
-	if err := conn.Close(); err != nil {
-		app.logger.Error("Failed to close proxy connection", "error", err)
+	// One HEAD per tick: don't keep the connection around between checks
+	req.Close = true
+
+	id := newUUID()
+	req.Header.Set(requestIDHeader, id)
+	req.Header.Set("User-Agent", "ssh-tunnel/"+buildInfo.Version)
+	app.lastRequestID.Store(id)
+	return req, nil
+}
+
+// lastCheckRequestID returns the ID of the last traffic check request, or "" before the first.
+func (app *Application) lastCheckRequestID() string {
+	id, _ := app.lastRequestID.Load().(string)
+	return id
+}
+
+// checkPortContext verifies that every proxy port is available; ctx can abort the dial.
+// A port that isn't available yields errPortRefused or errPortTimedOut where the dial
+// error allows telling them apart, see classifyDialError.
+func (app *Application) checkPortContext(ctx context.Context, logger *slog.Logger) error {
+	defer app.metrics.since(metricPortCheck, time.Now())
+	return app.checkProxies(ctx, logger, app.config.proxyHosts)
+}
+
+// checkProxies verifies that every address in hosts accepts connections, stopping
+// at the first that doesn't. The result for each checked address is kept for the status.
+func (app *Application) checkProxies(ctx context.Context, logger *slog.Logger, hosts []string) error {
+	dialer := app.config.dialer(&net.Dialer{Timeout: app.config.PortCheckTimeout})
+	for _, host := range hosts {
+		conn, err := dialer.DialContext(ctx, "tcp", host)
+		app.forwardStates.Store(host, err == nil)
+		if err != nil {
+			err = classifyDialError(err)
+			switch {
+			case errors.Is(err, errPortRefused):
+				logger.Error("Proxy port refused the connection, SSH is likely dead", "host", host, "error", err)
+			case errors.Is(err, errPortTimedOut):
+				logger.Error("Proxy port timed out, SSH may be alive with traffic blocked", "host", host, "error", err)
+			default:
+				logger.Error("Proxy port unavailable", "host", host, "error", err)
+			}
+			return err
+		}
+		if err := conn.Close(); err != nil {
+			logger.Error("Failed to close proxy connection", "error", err)
+		}
 	}
-	return true
+	return nil
 }
 
 // startSSH starts the SSH tunnel process.
-func (app *Application) startSSH() error {
+func (app *Application) startSSH(logger *slog.Logger) error {
+	return app.startSSHContext(context.Background(), logger)
+}
+
+// selectServer picks, resolves and checks the SSH server for the next ssh process.
+// It runs without sshMutex and leaves the config alone: the returned copy holds the
+// chosen server. An error skips the start.
+func (app *Application) selectServer(logger *slog.Logger) (*config, error) {
+	target := app.config.forServer(app.config.useFallback, app.config.resolvedHost)
+	if app.config.FallbackRemoteAddress != "" {
+		var err error
+		if target, err = app.selectRemote(logger); err != nil {
+			return nil, err
+		}
+	}
+
+	if app.config.SSHResolveOnRestart {
+		addr, err := target.resolveRemote(logger)
+		if err != nil {
+			app.recordCheckStats(false)
+			return nil, err
+		}
+		target.resolvedHost = addr
+	}
+
+	if app.config.PreflightCheck {
+		if err := app.preflightCheck(logger, target); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// prepareSSHStart selects the SSH server, scans its host key, makes sure an agent
+// is reachable and removes a stale control socket. It returns the config to switch to with useRemote and the scanned
+// fingerprint for checkHostKey. It runs without sshMutex.
+func (app *Application) prepareSSHStart(logger *slog.Logger) (*config, string, error) {
+	target, err := app.selectServer(logger)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var fingerprint string
+	if app.config.HostKeyScan {
+		fingerprint = app.scanServerHostKey(logger, target)
+	}
+
+	if app.config.SSHAgentSocketAutoRefresh {
+		refreshAgentSocket(logger)
+	}
+	if app.config.SSHSpawnAgent {
+		app.ensureAgent(logger)
+	}
+	app.removeStaleControlSocket(logger, target)
+	return target, fingerprint, nil
+}
+
+// startSSHContext is startSSH that stops waiting for the tunnel once ctx is done.
+func (app *Application) startSSHContext(parent context.Context, logger *slog.Logger) error {
+	app.sshMutex.RLock()
+	running := app.isProcessRunning(app.sshProcess)
+	app.sshMutex.RUnlock()
+	if running {
+		logger.Info("SSH process is already running")
+		return nil
+	}
+	defer app.metrics.since(metricSSHStart, time.Now())
+
+	// Dials and external commands run before the lock, which status requests,
+	// the monitors and stopSSH would otherwise wait on
+	target, fingerprint, err := app.prepareSSHStart(logger)
+	if err != nil {
+		return err
+	}
+	// A control master outlives its ssh on purpose and shares the forward
+	if app.config.ZombieDetection && !app.config.SSHControlMaster {
+		app.detectZombieSSH(logger)
+	}
+
 	app.sshMutex.Lock()
-	if app.sshProcess != nil && app.isProcessRunning(app.sshProcess) {
+	// Another start may have won while the checks ran
+	if app.isProcessRunning(app.sshProcess) {
 		app.sshMutex.Unlock()
-		app.logger.Info("SSH process is already running")
+		logger.Info("SSH process is already running")
 		return nil
 	}
+	app.useRemote(target)
+
+	if err := app.config.checkBindHostsFree(); err != nil {
+		app.sshMutex.Unlock()
+		app.recordCheckStats(false)
+		return err
+	}
+
+	if app.config.HostKeyScan {
+		if err := app.checkHostKey(logger, target, fingerprint); err != nil {
+			app.sshMutex.Unlock()
+			app.recordCheckStats(false)
+			return err
+		}
+	}
 
-	app.logger.Info("Starting SSH process")
-	cmd := exec.Command("ssh", app.config.serializeSSHOptions()...) //nolint:gosec
+	ctx, cancel := context.WithTimeout(parent, app.config.TunnelStartTimeout)
+	defer cancel()
+
+	app.setState(StateStarting)
+	logger.Info("Starting SSH process")
+	cmd := app.config.sshCommand(app.config.serializeSSHOptions())
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = app.sshStderr(logger)
 
 	if err := cmd.Start(); err != nil {
 		app.sshMutex.Unlock()
+		app.setState(StateStopped)
 		return fmt.Errorf("failed to start SSH: %w", err)
 	}
 
 	app.sshProcess = cmd
+	app.watchSSHProcess(cmd, logger)
 	app.sshMutex.Unlock()
+	app.audit(auditTunnelStart, "ssh_pid", cmd.Process.Pid, "remote", app.config.remoteAddress(), "bind", app.config.SSHBindHost)
+
+	// The login timeout only covers the connection phase: once the tunnel is
+	// ready, nothing kills the process on its account.
+	loginCtx := ctx
+	if app.config.SSHLoginTimeout > 0 {
+		var cancelLogin context.CancelFunc
+		loginCtx, cancelLogin = context.WithTimeout(ctx, app.config.SSHLoginTimeout)
+		defer cancelLogin()
+	}
 
 	// Verify the tunnel is ready
-	if !app.waitForTunnelReady() {
-		app.stopSSH()
-		return fmt.Errorf("tunnel failed to become ready")
+	if err := app.waitForTunnelReady(loginCtx, logger); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			app.killSSH(cmd, logger)
+			if ctx.Err() == nil {
+				return fmt.Errorf("SSH login did not complete within %s", app.config.SSHLoginTimeout)
+			}
+			if parent.Err() != nil {
+				return fmt.Errorf("tunnel start aborted: %w", parent.Err())
+			}
+			return fmt.Errorf("tunnel did not start within %s", app.config.TunnelStartTimeout)
+		}
+		app.stopSSH(logger)
+		return err
 	}
 
+	app.setState(StateRunning)
+	app.publishEnv(logger, cmd.Process.Pid)
+	app.runHook(logger, "on_start", app.config.OnStartCommand)
 	return nil
 }
 
+// killSSH forcibly kills an SSH process that failed to start in time.
+func (app *Application) killSSH(cmd *exec.Cmd, logger *slog.Logger) {
+	app.sshMutex.Lock()
+	defer app.sshMutex.Unlock()
+
+	logger.Warn("SSH process start timed out, killing", "pid", cmd.Process.Pid)
+	if err := cmd.Process.Kill(); err != nil {
+		logger.Error("Failed to kill process", "error", err)
+	}
+	if err := app.waitSSH(cmd); err != nil {
+		logger.Error("Error waiting for process after kill", "error", err)
+	}
+
+	if app.sshProcess == cmd {
+		app.sshProcess = nil
+	}
+	app.setState(StateStopped)
+}
+
 // isProcessRunning checks if a process is running.
 func (app *Application) isProcessRunning(cmd *exec.Cmd) bool {
-	return cmd != nil && cmd.Process != nil && cmd.ProcessState == nil
+	if cmd == nil || cmd.Process == nil {
+		return false
+	}
+	if value, ok := app.processExits.Load(cmd); ok {
+		select {
+		case <-value.(*processExit).done:
+			return false
+		default:
+			return true
+		}
+	}
+	return cmd.ProcessState == nil
 }
 
 // waitForTunnelReady waits for the tunnel to become available.
-func (app *Application) waitForTunnelReady() bool {
-	for range 5 {
-		if app.checkPort() {
-			app.logger.Info("SSH tunnel is ready")
-			return true
+// Returns ctx.Err() if the context expires first.
+func (app *Application) waitForTunnelReady(ctx context.Context, logger *slog.Logger) error {
+	return app.waitForProxies(ctx, logger, app.config.proxyHosts)
+}
+
+// waitForProxies is waitForTunnelReady for an explicit set of proxy addresses.
+// The proxies are polled every second for up to SSHTunnelReadyTimeout.
+func (app *Application) waitForProxies(ctx context.Context, logger *slog.Logger, hosts []string) error {
+	readyCtx, cancel := context.WithTimeout(ctx, app.config.SSHTunnelReadyTimeout)
+	defer cancel()
+
+	for {
+		if app.checkProxies(readyCtx, logger, hosts) == nil {
+			app.tunnelStarted.Store(true)
+			logger.Info("SSH tunnel is ready")
+			return nil
+		}
+
+		select {
+		case <-readyCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("tunnel failed to become ready within %s", app.config.SSHTunnelReadyTimeout)
+		case <-time.After(1 * time.Second):
 		}
-		time.Sleep(1 * time.Second)
 	}
-	return false
 }
 
 // stopSSH stops the SSH tunnel process.
-func (app *Application) stopSSH() {
-	app.sshMutex.Lock()
-	defer app.sshMutex.Unlock()
+func (app *Application) stopSSH(logger *slog.Logger) {
+	// Run the hook before taking the write lock so status requests aren't blocked by it
+	app.sshMutex.RLock()
+	running := app.sshProcess != nil && app.isProcessRunning(app.sshProcess)
+	app.sshMutex.RUnlock()
+	if running {
+		app.runHook(logger, "on_stop", app.config.OnStopCommand)
+	}
 
+	app.sshMutex.Lock()
 	if app.sshProcess == nil || !app.isProcessRunning(app.sshProcess) {
+		app.sshMutex.Unlock()
 		return
 	}
 
 	cmd := app.sshProcess
-	app.logger.Info("Stopping SSH process", "pid", cmd.Process.Pid)
+	defer app.metrics.since(metricSSHStop, time.Now())
+	app.setState(StateStopping)
+	logger.Info("Stopping SSH process", "pid", cmd.Process.Pid)
+	app.audit(auditTunnelStop, "ssh_pid", cmd.Process.Pid)
+
+	app.terminateSSH(cmd, logger)
+
+	app.sshProcess = nil
+	app.sshMutex.Unlock()
+
+	// ssh -O exit may take seconds, don't block status requests on it
+	app.stopControlMaster(logger)
+	app.setState(StateStopped)
+}
 
+// terminateSSH asks cmd to exit and waits for it, killing it if it doesn't exit within 5 seconds.
+func (app *Application) terminateSSH(cmd *exec.Cmd, logger *slog.Logger) {
 	if err := terminateProcess(cmd.Process); err != nil {
-		app.logger.Error("Failed to terminate process", "error", err)
+		logger.Error("Failed to terminate process", "error", err)
 	}
 
 	waitCh := make(chan error, 1)
 	go func() {
-		waitCh <- cmd.Wait()
+		waitCh <- app.waitSSH(cmd)
 	}()
 
 	termTimer := time.NewTimer(5 * time.Second)
@@ -340,62 +1262,106 @@ func (app *Application) stopSSH() {
 	select {
 	case err := <-waitCh:
 		if err != nil {
-			app.logger.Error("Error waiting for process", "error", err)
+			logger.Error("Error waiting for process", "error", err)
 		}
 	case <-termTimer.C:
-		app.logger.Warn("SSH process did not exit, killing", "pid", cmd.Process.Pid)
+		logger.Warn("SSH process did not exit, killing", "pid", cmd.Process.Pid)
 		if err := cmd.Process.Kill(); err != nil {
-			app.logger.Error("Failed to kill process", "error", err)
+			logger.Error("Failed to kill process", "error", err)
 		}
 		if err := <-waitCh; err != nil {
-			app.logger.Error("Error waiting for process after kill", "error", err)
+			logger.Error("Error waiting for process after kill", "error", err)
 		}
 	}
-
-	app.sshProcess = nil
 }
 
-// createPIDFile creates the PID file.
+// createPIDFile creates the PID file. An existing file counts as stale if its process
+// is gone or it was written by a different binary, so an upgrade isn't blocked by it.
 func (app *Application) createPIDFile() error {
+	return app.claimPIDFile(os.Getpid())
+}
+
+// claimPIDFile writes pid to the PID file, see createPIDFile. A file that already names
+// the --daemon background process belongs to it and is taken over, see daemonize.
+func (app *Application) claimPIDFile(pid int) error {
 	pidFile := filepath.Clean(app.config.getPortSpecificPIDFile())
 
-	if _, err := os.Stat(pidFile); err == nil {
-		content, err := os.ReadFile(pidFile)
-		if err != nil {
-			return fmt.Errorf("failed to read PID file: %w", err)
-		}
+	hash, err := binaryHash()
+	if err != nil {
+		app.logger.Warn("Failed to hash the executable, PID file will not detect upgrades", "error", err)
+	}
 
-		pid, err := strconv.Atoi(string(bytes.TrimSpace(content)))
+	if _, err := os.Stat(pidFile); err == nil {
+		existing, err := readPIDFile(pidFile)
 		if err != nil {
-			return fmt.Errorf("failed to parse PID: %w", err)
+			return err
 		}
 
-		alive, err := checkProcessAlive(pid)
+		alive, err := checkProcessAlive(existing.PID)
 		if err != nil {
-			return fmt.Errorf("failed to check PID %d: %w", pid, err)
+			return fmt.Errorf("failed to check PID %d: %w", existing.PID, err)
 		}
-		if alive {
-			return fmt.Errorf("another instance is already running on port %s with PID %d", app.config.proxyPort, pid)
+		switch {
+		case existing.PID == app.daemonPID:
+		case alive && existing.otherBinary(hash):
+			app.logger.Warn("PID file was written by a different binary, treating it as stale",
+				"pid_file", pidFile, "existing_pid", existing.PID, "existing_hash", existing.Hash)
+		case alive:
+			app.audit(auditPIDConflict, "pid_file", pidFile, "existing_pid", existing.PID)
+			return fmt.Errorf("another instance %s is already running with PID %d", app.config.instanceName(), existing.PID)
 		}
 
+		// Compare the SSH server's key with the one the previous run saw
+		app.hostKey = existing.HostKey
+
 		if err := os.Remove(pidFile); err != nil {
 			return fmt.Errorf("failed to remove stale PID file: %w", err)
 		}
 	}
 
-	return os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0600)
+	return writePIDFile(pidFile, pidFileContent{PID: pid, Hash: hash, Started: time.Now().UTC(), HostKey: app.hostKey})
+}
+
+// readPIDFile reads and parses pidFile.
+func readPIDFile(pidFile string) (pidFileContent, error) {
+	data, err := os.ReadFile(filepath.Clean(pidFile))
+	if err != nil {
+		return pidFileContent{}, fmt.Errorf("failed to read PID file: %w", err)
+	}
+	return parsePIDFile(data)
 }
 
 // cleanup performs application cleanup tasks.
 func (app *Application) cleanup() {
-	app.stopSSH()
+	app.stopMgmtServer()
+	app.stopPprofServer()
+	// A stopped process would only handle SIGTERM after SIGCONT
+	if app.paused.Load() {
+		app.resumeTunnel()
+	}
+	app.stopSSH(app.componentLogger(componentSSH))
+	app.stopAccessFilter()
+	app.removeStaleControlSocket(app.componentLogger(componentSSH), app.config)
+	app.stopAgent(app.componentLogger(componentSSH))
+	if err := app.config.removeIdentityKey(); err != nil {
+		app.logger.Error("Failed to remove identity file", "error", err)
+	}
 
 	pidFile := app.config.getPortSpecificPIDFile()
 	if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
 		app.logger.Error("Failed to remove PID file", "error", err)
 	}
+	if err := app.config.removePublishedEnv(); err != nil {
+		app.logger.Error("Failed to remove published env file", "error", err)
+	}
 
+	app.goroutineLeakCheck()
 	app.logger.Info("Application shutdown complete")
+	if app.auditFile != nil {
+		if err := app.auditFile.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to close audit log file:", err)
+		}
+	}
 	if app.logFile != nil {
 		if err := app.logFile.Close(); err != nil {
 			fmt.Fprintln(os.Stderr, "Failed to close log file:", err)
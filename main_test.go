@@ -1,13 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // --- resolveAddr ---
@@ -124,8 +136,15 @@ func newTestApp(t *testing.T) *Application {
 	}
 
 	return &Application{
-		config:       &cfg,
-		shutdownChan: make(chan struct{}),
+		config:          &cfg,
+		logger:          slog.New(slog.DiscardHandler),
+		shutdownChan:    make(chan struct{}),
+		restartChan:     make(chan struct{}, 1),
+		processDied:     make(chan struct{}, 1),
+		masterDied:      make(chan struct{}, 1),
+		reloadChan:      make(chan *config, 1),
+		restartStrategy: newRestartStrategy(&cfg),
+		httpTransport:   &http.Transport{},
 	}
 }
 
@@ -136,15 +155,16 @@ func TestCreatePIDFile_New(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	pidFile := filepath.Clean(app.config.getPortSpecificPIDFile())
-	data, err := os.ReadFile(pidFile)
+	content, err := readPIDFile(app.config.getPortSpecificPIDFile())
 	if err != nil {
-		t.Fatalf("failed to read PID file: %v", err)
+		t.Fatalf("readPIDFile: %v", err)
 	}
-
-	pid := string(data)
-	if pid != strconv.Itoa(os.Getpid()) {
-		t.Errorf("PID file contains %q, want %d", pid, os.Getpid())
+	hash, err := binaryHash()
+	if err != nil {
+		t.Fatalf("binaryHash: %v", err)
+	}
+	if content.PID != os.Getpid() || content.Hash != hash || content.Started.IsZero() {
+		t.Errorf("PID file contains %+v, want pid %d and hash %s", content, os.Getpid(), hash)
 	}
 }
 
@@ -177,12 +197,116 @@ func TestCreatePIDFile_StaleFile(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	data, err := os.ReadFile(filepath.Clean(pidFile))
+	content, err := readPIDFile(pidFile)
 	if err != nil {
-		t.Fatalf("failed to read PID file: %v", err)
+		t.Fatalf("readPIDFile: %v", err)
 	}
-	if string(data) != strconv.Itoa(os.Getpid()) {
-		t.Errorf("PID file contains %q, want %d", string(data), os.Getpid())
+	if content.PID != os.Getpid() {
+		t.Errorf("PID file contains pid %d, want %d", content.PID, os.Getpid())
+	}
+}
+
+// useBinaryHash replaces the executable hash for the duration of the test.
+func useBinaryHash(t *testing.T, hash string) {
+	t.Helper()
+	original := binaryHash
+	binaryHash = func() (string, error) { return hash, nil }
+	t.Cleanup(func() { binaryHash = original })
+}
+
+func TestCreatePIDFile_RunningProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		ok      bool
+	}{
+		{"same binary", `{"pid":%d,"hash":"new"}`, false},
+		{"other binary", `{"pid":%d,"hash":"old"}`, true},
+		{"plain PID from before upgrade", `%d`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useBinaryHash(t, "new")
+			app := newTestApp(t)
+			pidFile := app.config.getPortSpecificPIDFile()
+
+			// The test process itself is the running process
+			if err := os.WriteFile(pidFile, fmt.Appendf(nil, tt.content, os.Getpid()), 0600); err != nil {
+				t.Fatalf("failed to write PID file: %v", err)
+			}
+
+			err := app.createPIDFile()
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			content, err := readPIDFile(pidFile)
+			if err != nil {
+				t.Fatalf("readPIDFile: %v", err)
+			}
+			if tt.ok && content.Hash != "new" {
+				t.Errorf("PID file hash = %q, want it rewritten by the new binary", content.Hash)
+			}
+		})
+	}
+}
+
+func TestClaimPIDFile_Daemon(t *testing.T) {
+	useBinaryHash(t, "new")
+	app := newTestApp(t)
+	pidFile := app.config.getPortSpecificPIDFile()
+
+	// The test process stands in for a daemon child that already wrote its PID file
+	app.daemonPID = os.Getpid()
+	if err := writePIDFile(pidFile, pidFileContent{PID: app.daemonPID, Hash: "new", HostKey: "ED25519 SHA256:kept"}); err != nil {
+		t.Fatalf("writePIDFile: %v", err)
+	}
+
+	if err := app.claimPIDFile(app.daemonPID); err != nil {
+		t.Fatalf("claimPIDFile: %v", err)
+	}
+	content, err := readPIDFile(pidFile)
+	if err != nil {
+		t.Fatalf("readPIDFile: %v", err)
+	}
+	if content.PID != app.daemonPID || content.HostKey != "ED25519 SHA256:kept" {
+		t.Errorf("PID file contains %+v, want the daemon's PID and host key", content)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(pidFile))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestParsePIDFile(t *testing.T) {
+	tests := []struct {
+		data string
+		want pidFileContent
+		ok   bool
+	}{
+		{"1234\n", pidFileContent{PID: 1234}, true},
+		{`{"pid":1234,"hash":"abc","started":"2026-01-02T03:04:05Z"}`,
+			pidFileContent{PID: 1234, Hash: "abc", Started: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}, true},
+		{`{"hash":"abc"}`, pidFileContent{}, false},
+		{"not-a-number", pidFileContent{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			got, err := parsePIDFile([]byte(tt.data))
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("parsePIDFile() = %+v, want %+v", got, tt.want)
+			}
+		})
 	}
 }
 
@@ -259,3 +383,475 @@ func TestCleanup_RemovesPIDFile(t *testing.T) {
 		t.Error("PID file should be removed after cleanup")
 	}
 }
+
+// --- waitForTunnelReady ---
+
+// useProxyListener points the app's proxy host at a fresh local listener.
+func useProxyListener(t *testing.T, app *Application) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	app.config.proxyHost = listener.Addr().String()
+	app.config.proxyHosts = []string{app.config.proxyHost}
+	return listener
+}
+
+func TestWaitForTunnelReady_Ready(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	useProxyListener(t, app)
+
+	if err := app.waitForTunnelReady(context.Background(), app.logger); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitForTunnelReady_Timeout(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	listener := useProxyListener(t, app)
+	_ = listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := app.waitForTunnelReady(ctx, app.logger)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitForTunnelReady_ReadyTimeout(t *testing.T) {
+	app := newTestApp(t)
+	app.config.SSHTunnelReadyTimeout = 100 * time.Millisecond
+	listener := useProxyListener(t, app)
+	_ = listener.Close()
+
+	err := app.waitForTunnelReady(context.Background(), app.logger)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the ready timeout rather than the caller's deadline", err)
+	}
+}
+
+func TestStartSSH_LoginTimeout(t *testing.T) {
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	app.config.SSHLoginTimeout = 200 * time.Millisecond
+	// The fake ssh listens on the bind host, but readiness is checked on a closed port
+	listener := useProxyListener(t, app)
+	_ = listener.Close()
+
+	start := time.Now()
+	err = app.startSSH(app.logger)
+	if err == nil || !strings.Contains(err.Error(), "login did not complete") {
+		t.Fatalf("startSSH() error = %v, want a login timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("startSSH() took %s, want it to give up after the login timeout", elapsed)
+	}
+	if app.sshProcess != nil || app.state() != StateStopped {
+		t.Error("SSH process should be killed after the login timeout")
+	}
+}
+
+// --- createHTTPTransport ---
+
+func TestCreateHTTPTransport_PoolSettings(t *testing.T) {
+	app := newTestApp(t)
+	app.config.HTTPMaxIdleConns = 7
+	app.config.HTTPMaxConnsPerHost = 3
+	app.config.HTTPIdleConnTimeout = 42 * time.Second
+	app.config.HTTPDisableKeepAlives = true
+
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		t.Fatalf("createHTTPTransport: %v", err)
+	}
+
+	if transport.MaxIdleConns != 7 || transport.MaxConnsPerHost != 3 {
+		t.Errorf("conn limits = %d/%d, want 7/3", transport.MaxIdleConns, transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 42*time.Second {
+		t.Errorf("IdleConnTimeout = %v, want 42s", transport.IdleConnTimeout)
+	}
+	if transport.TLSHandshakeTimeout != 10*time.Second || transport.ExpectContinueTimeout != time.Second {
+		t.Errorf("timeouts = %v/%v, want 10s/1s", transport.TLSHandshakeTimeout, transport.ExpectContinueTimeout)
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("DisableKeepAlives should be set")
+	}
+}
+
+func TestCreateHTTPTransport_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	rootCAs := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	tests := []struct {
+		name  string
+		http2 bool
+		want  int
+	}{
+		{"disabled", false, 1},
+		{"enabled", true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.config.TrafficCheckHTTP2 = tt.http2
+			transport, err := app.createHTTPTransport()
+			if err != nil {
+				t.Fatalf("createHTTPTransport: %v", err)
+			}
+			// Connect directly instead of through the SOCKS proxy
+			transport.DialContext = (&net.Dialer{}).DialContext
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			transport.TLSClientConfig.RootCAs = rootCAs
+
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.ProtoMajor != tt.want {
+				t.Errorf("protocol = %s, want HTTP/%d", resp.Proto, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProxyDialer_KeepAlive(t *testing.T) {
+	app := newTestApp(t)
+	app.config.TCPKeepAliveInterval = 20 * time.Second
+	app.config.TCPKeepAliveCount = 5
+
+	dialer := app.newProxyDialer()
+	want := net.KeepAliveConfig{Enable: true, Idle: 20 * time.Second, Interval: 20 * time.Second, Count: 5}
+	if dialer.KeepAliveConfig != want {
+		t.Errorf("KeepAliveConfig = %+v, want %+v", dialer.KeepAliveConfig, want)
+	}
+	if dialer.Timeout != app.config.PortCheckTimeout {
+		t.Errorf("Timeout = %v, want %v", dialer.Timeout, app.config.PortCheckTimeout)
+	}
+
+	app.config.TCPKeepAliveInterval = 0
+	dialer = app.newProxyDialer()
+	if dialer.KeepAliveConfig.Enable || dialer.KeepAlive >= 0 {
+		t.Errorf("keepalives should be disabled, got KeepAlive=%v config=%+v", dialer.KeepAlive, dialer.KeepAliveConfig)
+	}
+}
+
+func TestResolveAddr_CustomDNSServer(t *testing.T) {
+	// A UDP listener that records queries but never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	queried := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 512)
+		if _, _, err := conn.ReadFrom(buf); err == nil {
+			queried <- struct{}{}
+		}
+	}()
+
+	app := &Application{resolver: newResolver(conn.LocalAddr().String(), time.Second)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := app.resolveAddr(ctx, "example.invalid:443"); err == nil {
+		t.Error("expected error from unresponsive DNS server")
+	}
+
+	select {
+	case <-queried:
+	case <-time.After(time.Second):
+		t.Error("custom DNS server was not queried")
+	}
+}
+
+func TestNewResolver_Disabled(t *testing.T) {
+	if newResolver("", time.Second) != nil {
+		t.Error("expected nil resolver when no DNS server is configured")
+	}
+}
+
+// --- newUUID ---
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUID(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		id := newUUID()
+		if !uuidV4.MatchString(id) {
+			t.Fatalf("newUUID() = %q, not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("newUUID() returned duplicate %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+// --- newTrafficCheckRequest ---
+
+func TestNewTrafficCheckRequest(t *testing.T) {
+	app := newTestApp(t)
+	if id := app.lastCheckRequestID(); id != "" {
+		t.Fatalf("lastCheckRequestID() = %q before the first check, want empty", id)
+	}
+
+	var got http.Header
+	handler := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = r.Header })
+
+	var ids []string
+	for range 2 {
+		req, err := app.newTrafficCheckRequest()
+		if err != nil {
+			t.Fatalf("newTrafficCheckRequest() error: %v", err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		id := got.Get(requestIDHeader)
+		if !uuidV4.MatchString(id) {
+			t.Errorf("%s = %q, not a version 4 UUID", requestIDHeader, id)
+		}
+		if last := app.lastCheckRequestID(); last != id {
+			t.Errorf("lastCheckRequestID() = %q, want %q", last, id)
+		}
+		if ua := got.Get("User-Agent"); ua != "ssh-tunnel/"+buildInfo.Version {
+			t.Errorf("User-Agent = %q, want ssh-tunnel/%s", ua, buildInfo.Version)
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("request ID %q was reused", ids[0])
+	}
+}
+
+// --- checkTrafficWithRetry ---
+
+// useFlakyTrafficServer routes the app's HTTP traffic checks to a local TLS server that
+// answers the first failures requests with 503, and returns the number of requests served.
+func useFlakyTrafficServer(t *testing.T, app *Application, failures int32) *atomic.Int32 {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.InsecureSkipVerify = true //nolint:gosec // test server certificate is not issued for the check URL
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	app.httpTransport = transport
+	useProxyListener(t, app)
+	return &requests
+}
+
+func TestCheckTrafficWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int32
+		wantOK       bool
+		wantRequests int32
+		wantDebug    int
+	}{
+		{"healthy", 1, 0, true, 1, 0},
+		{"recovers on retry", 1, 1, true, 2, 1},
+		{"recovers on last retry", 3, 3, true, 4, 3},
+		{"retries exhausted", 2, 5, false, 3, 2},
+		{"retries disabled", 0, 1, false, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.config.TrafficCheckRetries = tt.retries
+			app.config.TrafficCheckRetryDelay = time.Millisecond
+			requests := useFlakyTrafficServer(t, app, tt.failures)
+			var buf bytes.Buffer
+			app.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			err := app.checkTrafficWithRetry(context.Background())
+			if (err == nil) != tt.wantOK {
+				t.Errorf("checkTrafficWithRetry() error = %v, want ok=%v", err, tt.wantOK)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantRequests)
+			}
+
+			logs := buf.String()
+			if got := strings.Count(logs, `"msg":"Traffic check attempt failed"`); got != tt.wantDebug {
+				t.Errorf("logged %d failed attempts, want %d:\n%s", got, tt.wantDebug, logs)
+			}
+			wantErrors := 0
+			if !tt.wantOK {
+				wantErrors = 1
+			}
+			if got := strings.Count(logs, `"level":"ERROR"`); got != wantErrors {
+				t.Errorf("logged %d errors, want one only for a final failure:\n%s", got, logs)
+			}
+			if tt.wantDebug > 0 && (!strings.Contains(logs, `"attempt":1`) || !strings.Contains(logs, `"elapsed"`)) {
+				t.Errorf("failed attempt log lacks attempt number or elapsed time:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestCheckTrafficWithRetry_Shutdown(t *testing.T) {
+	app := newTestApp(t)
+	app.config.TrafficCheckRetries = 5
+	app.config.TrafficCheckRetryDelay = time.Hour
+	requests := useFlakyTrafficServer(t, app, 10)
+	close(app.shutdownChan)
+
+	if err := app.checkTrafficWithRetry(context.Background()); err == nil {
+		t.Fatal("expected the failed check to be reported")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want no retries after shutdown", got)
+	}
+}
+
+func TestTick_Timeout(t *testing.T) {
+	app := newTestApp(t)
+	app.config.TickTimeout = 50 * time.Millisecond
+	app.config.TrafficCheckRetries = 5
+	app.config.TrafficCheckRetryDelay = time.Hour
+	useFlakyTrafficServer(t, app, 10)
+	restarts := countRestarts(app)
+
+	start := time.Now()
+	app.tick()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("tick took %s, want it cut off by the tick timeout", elapsed)
+	}
+	if got := app.tickTimeouts.Load(); got != 1 {
+		t.Errorf("tickTimeouts = %d, want 1", got)
+	}
+	if *restarts != 0 {
+		t.Errorf("restarted %d times, want no restart after a timed out check", *restarts)
+	}
+	if got := app.consecutiveFailures.Load(); got != 0 {
+		t.Errorf("consecutiveFailures = %d, a timed out check should not count", got)
+	}
+}
+
+func TestTick_WithinTimeout(t *testing.T) {
+	app := newTestApp(t)
+	app.config.TickTimeout = 5 * time.Second
+	useFlakyTrafficServer(t, app, 0)
+
+	app.tick()
+	if got := app.tickTimeouts.Load(); got != 0 {
+		t.Errorf("tickTimeouts = %d, want 0", got)
+	}
+	if got := app.Stats().TickTimeouts; got != 0 {
+		t.Errorf("Stats().TickTimeouts = %d, want 0", got)
+	}
+}
+
+func TestCheckTraffic_SkipsOverlappingCheck(t *testing.T) {
+	app := newTestApp(t)
+	useFlakyTrafficServer(t, app, 0)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	transport := app.httpTransport
+	dial := transport.DialContext
+	var once sync.Once
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		once.Do(func() { close(entered) })
+		<-release
+		return dial(ctx, network, addr)
+	}
+
+	first := make(chan error, 1)
+	go func() { first <- app.checkTraffic() }()
+	<-entered
+
+	if err := app.checkTraffic(); !errors.Is(err, errCheckInProgress) {
+		t.Errorf("overlapping checkTraffic() = %v, want errCheckInProgress", err)
+	}
+	if got := app.Stats().OverlapChecksSkipped; got != 1 {
+		t.Errorf("OverlapChecksSkipped = %d, want 1", got)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Errorf("first checkTraffic() = %v, want nil", err)
+	}
+	if err := app.checkTraffic(); errors.Is(err, errCheckInProgress) {
+		t.Error("check after the first finished should not be skipped")
+	}
+}
+
+// --- allowRestart ---
+
+func TestAllowRestart_SlidingWindow(t *testing.T) {
+	app := newTestApp(t)
+	app.config.MaxRestartsPerHour = 2
+	start := time.Now()
+
+	if !app.allowRestart(start) || !app.allowRestart(start.Add(time.Minute)) {
+		t.Fatal("restarts within the limit should be allowed")
+	}
+	if app.allowRestart(start.Add(30 * time.Minute)) {
+		t.Error("restart over the limit should be skipped")
+	}
+	if !app.allowRestart(start.Add(restartWindow + time.Second)) {
+		t.Error("restart should be allowed once the oldest entry leaves the window")
+	}
+	if app.allowRestart(start.Add(restartWindow + 2*time.Second)) {
+		t.Error("window should be full again")
+	}
+}
+
+func TestAllowRestart_Unlimited(t *testing.T) {
+	app := newTestApp(t)
+	app.config.MaxRestartsPerHour = 0
+	now := time.Now()
+	for range 100 {
+		if !app.allowRestart(now) {
+			t.Fatal("restarts should be unlimited when MaxRestartsPerHour is 0")
+		}
+	}
+}
+
+func TestRestartTunnel_SkipsWhenLimitReached(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.MaxRestartsPerHour = 1
+	app.restartTimes = []time.Time{time.Now()}
+
+	app.restartTunnel()
+
+	if got := app.Stats().TotalRestarts; got != 0 {
+		t.Errorf("TotalRestarts = %d, want 0 for a skipped restart", got)
+	}
+}
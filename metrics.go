@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Operations timed by metricsCollector, also the keys of TunnelStats.Durations.
+const (
+	metricPortCheck    = "port_check"
+	metricTrafficCheck = "traffic_check"
+	metricSSHStart     = "ssh_start"
+	metricSSHStop      = "ssh_stop"
+)
+
+// durationSamples is how many recent durations are kept per operation.
+const durationSamples = 128
+
+// DurationPercentiles summarizes the recent durations of one operation.
+type DurationPercentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_seconds"`
+	P95   float64 `json:"p95_seconds"`
+	P99   float64 `json:"p99_seconds"`
+}
+
+// metricsCollector keeps the last durationSamples durations of each operation in a
+// ring buffer. The zero value is ready to use and it is safe for concurrent use.
+type metricsCollector struct {
+	mu    sync.Mutex
+	rings map[string]*durationRing
+}
+
+// durationRing is a fixed-size buffer of the most recent durations.
+type durationRing struct {
+	samples []time.Duration
+	next    int // index overwritten by the next sample once the buffer is full
+}
+
+// since records the time elapsed since start for op, e.g. defer m.since(op, time.Now()).
+func (m *metricsCollector) since(op string, start time.Time) {
+	m.observe(op, time.Since(start))
+}
+
+// observe records one duration of op.
+func (m *metricsCollector) observe(op string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rings == nil {
+		m.rings = make(map[string]*durationRing)
+	}
+	ring := m.rings[op]
+	if ring == nil {
+		ring = &durationRing{samples: make([]time.Duration, 0, durationSamples)}
+		m.rings[op] = ring
+	}
+	if len(ring.samples) < durationSamples {
+		ring.samples = append(ring.samples, d)
+		return
+	}
+	ring.samples[ring.next] = d
+	ring.next = (ring.next + 1) % durationSamples
+}
+
+// percentiles returns the percentiles of every operation recorded so far.
+func (m *metricsCollector) percentiles() map[string]DurationPercentiles {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.rings) == 0 {
+		return nil
+	}
+	result := make(map[string]DurationPercentiles, len(m.rings))
+	for op, ring := range m.rings {
+		sorted := slices.Clone(ring.samples)
+		slices.Sort(sorted)
+		result[op] = DurationPercentiles{
+			Count: len(sorted),
+			P50:   percentile(sorted, 0.50).Seconds(),
+			P95:   percentile(sorted, 0.95).Seconds(),
+			P99:   percentile(sorted, 0.99).Seconds(),
+		}
+	}
+	return result
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted, which must not be empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricsCollector_Percentiles(t *testing.T) {
+	var m metricsCollector
+	if got := m.percentiles(); got != nil {
+		t.Errorf("percentiles() = %v before any sample, want nil", got)
+	}
+
+	// Shuffled order: percentiles must not depend on arrival order
+	for i := range 100 {
+		m.observe(metricPortCheck, time.Duration((i*37)%100+1)*time.Millisecond)
+	}
+
+	got := m.percentiles()[metricPortCheck]
+	want := DurationPercentiles{Count: 100, P50: 0.050, P95: 0.095, P99: 0.099}
+	if got != want {
+		t.Errorf("percentiles = %+v, want %+v", got, want)
+	}
+}
+
+func TestMetricsCollector_KeepsRecentSamples(t *testing.T) {
+	var m metricsCollector
+	for range durationSamples {
+		m.observe(metricSSHStart, time.Hour)
+	}
+	for range durationSamples {
+		m.observe(metricSSHStart, time.Second)
+	}
+
+	got := m.percentiles()[metricSSHStart]
+	if got.Count != durationSamples || got.P99 != 1 {
+		t.Errorf("percentiles = %+v, want %d samples of the last second-long starts only", got, durationSamples)
+	}
+}
+
+func TestStats_Durations(t *testing.T) {
+	app := newTestApp(t)
+	if got := app.Stats().Durations; got != nil {
+		t.Errorf("Durations = %v before any check, want nil", got)
+	}
+
+	useProxyListener(t, app)
+	if err := app.checkPortContext(t.Context(), app.logger); err != nil {
+		t.Fatalf("port check failed: %v", err)
+	}
+
+	if got := app.Stats().Durations[metricPortCheck]; got.Count != 1 || got.P50 <= 0 {
+		t.Errorf("port check durations = %+v, want one positive sample", got)
+	}
+}
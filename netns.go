@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+)
+
+// netnsName matches the names ip netns accepts under /var/run/netns.
+var netnsName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// validateNetns checks SSH_TUNNEL_NETNS; an empty name keeps the current namespace.
+func validateNetns(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !netnsSupported {
+		return fmt.Errorf("network namespaces are only supported on Linux")
+	}
+	if !netnsName.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid network namespace %q: only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	return nil
+}
+
+// sshCommand returns the command that runs ssh with args, inside the configured
+// network namespace via ip netns exec when one is set. ip replaces itself with
+// ssh, so the process PID stays that of ssh.
+func (c *config) sshCommand(args []string) *exec.Cmd {
+	if c.Netns == "" {
+		return exec.Command(sshBinary, args...) //nolint:gosec
+	}
+	return exec.Command(ipBinary, append([]string{"netns", "exec", c.Netns, sshBinary}, args...)...) //nolint:gosec
+}
+
+// ipBinary is the iproute2 executable and is replaced in tests.
+var ipBinary = "ip"
+
+// nsDialer dials from the network namespace ssh runs in, so the proxy ports it
+// listens on and the routes it uses are the ones being checked.
+type nsDialer struct {
+	*net.Dialer
+	netns string
+}
+
+// dialer wraps d to dial from the configured network namespace.
+func (c *config) dialer(d *net.Dialer) nsDialer {
+	return nsDialer{Dialer: d, netns: c.Netns}
+}
+
+// DialContext connects to address, switching to the network namespace for the dial.
+func (d nsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.netns == "" {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+	return dialInNetns(ctx, d.Dialer, d.netns, network, address)
+}
+
+// Dial is DialContext without a context, for proxy.Dialer.
+func (d nsDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
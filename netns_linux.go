@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+const netnsSupported = true
+
+// netnsDir is where ip netns keeps its named namespaces; replaced in tests.
+var netnsDir = "/var/run/netns"
+
+// dialInNetns dials from the named network namespace. The socket is created on a
+// locked OS thread that has joined the namespace and keeps it after the thread
+// switches back. Dials that fan out to several addresses run on other threads,
+// so address should be a literal IP, as proxy and server addresses usually are.
+func dialInNetns(ctx context.Context, d *net.Dialer, name, network, address string) (net.Conn, error) {
+	target, err := os.Open(filepath.Join(netnsDir, name)) //nolint:gosec // name is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to open network namespace: %w", err)
+	}
+	defer func() { _ = target.Close() }()
+
+	runtime.LockOSThread()
+	current, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("failed to open current network namespace: %w", err)
+	}
+	defer func() { _ = current.Close() }()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil { //nolint:gosec // file descriptors fit in an int
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("failed to enter network namespace %s: %w", name, err)
+	}
+	conn, dialErr := d.DialContext(ctx, network, address)
+
+	// A thread left in the other namespace stays locked and exits with the goroutine
+	if err := unix.Setns(int(current.Fd()), unix.CLONE_NEWNET); err != nil { //nolint:gosec // file descriptors fit in an int
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return nil, fmt.Errorf("failed to leave network namespace %s: %w", name, err)
+	}
+	runtime.UnlockOSThread()
+	return conn, dialErr
+}
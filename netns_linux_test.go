@@ -0,0 +1,54 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useNetnsDir(t *testing.T, dir string) {
+	t.Helper()
+	old := netnsDir
+	netnsDir = dir
+	t.Cleanup(func() { netnsDir = old })
+}
+
+func TestDialInNetns_Missing(t *testing.T) {
+	useNetnsDir(t, t.TempDir())
+
+	_, err := dialInNetns(context.Background(), &net.Dialer{}, "vpn1", "tcp", "127.0.0.1:1")
+	if err == nil || !strings.Contains(err.Error(), "failed to open network namespace") {
+		t.Errorf("want open error, got %v", err)
+	}
+}
+
+func TestDialInNetns_CurrentNamespace(t *testing.T) {
+	// A link to the test's own namespace exercises the switch without creating one
+	dir := t.TempDir()
+	if err := os.Symlink("/proc/self/ns/net", filepath.Join(dir, "self")); err != nil {
+		t.Fatal(err)
+	}
+	useNetnsDir(t, dir)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	conn, err := dialInNetns(context.Background(), &net.Dialer{Timeout: time.Second}, "self", "tcp", ln.Addr().String())
+	if errors.Is(err, os.ErrPermission) {
+		t.Skipf("setns not permitted: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+const netnsSupported = false
+
+// dialInNetns is unavailable outside Linux; validate rejects SSH_TUNNEL_NETNS there.
+func dialInNetns(context.Context, *net.Dialer, string, string, string) (net.Conn, error) {
+	return nil, errors.New("network namespaces are only supported on Linux")
+}
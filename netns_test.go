@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateNetns(t *testing.T) {
+	if err := validateNetns(""); err != nil {
+		t.Errorf("empty namespace: unexpected error: %v", err)
+	}
+	if runtime.GOOS != "linux" {
+		if err := validateNetns("vpn1"); err == nil {
+			t.Error("expected error outside Linux")
+		}
+		return
+	}
+	for _, name := range []string{"vpn1", "tunnel.a", "ns_2-b"} {
+		if err := validateNetns(name); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"..", "a/b", "vpn 1"} {
+		if err := validateNetns(name); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSSHCommand_Netns(t *testing.T) {
+	cfg := validConfig()
+	cmd := cfg.sshCommand([]string{"-N", "host"})
+	if got := strings.Join(cmd.Args, " "); got != sshBinary+" -N host" {
+		t.Errorf("without namespace: got %q", got)
+	}
+
+	cfg.Netns = "vpn1"
+	cmd = cfg.sshCommand([]string{"-N", "host"})
+	if got, want := strings.Join(cmd.Args, " "), ipBinary+" netns exec vpn1 "+sshBinary+" -N host"; got != want {
+		t.Errorf("with namespace: got %q, want %q", got, want)
+	}
+}
+
+func TestNsDialer_NoNetns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	cfg := validConfig()
+	conn, err := cfg.dialer(&net.Dialer{Timeout: time.Second}).Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+
+	if _, err := cfg.dialer(&net.Dialer{}).DialContext(context.Background(), "tcp", "127.0.0.1:0"); err == nil {
+		t.Error("expected error dialing port 0")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import "os"
+
+// SIGUSR1 actions.
+const sigusr1ActionPause = "pause"
+
+// handlePauseSignal pauses or resumes the tunnel for a signal from pauseChan.
+// Only called from the main loop.
+func (app *Application) handlePauseSignal(sig os.Signal) {
+	app.componentLogger(componentTunnel).Info("Received signal", "signal", sig)
+	app.audit(auditSignalReceived, "signal", sig.String())
+
+	if isResumeSignal(sig) || app.paused.Load() {
+		app.resumeTunnel()
+		return
+	}
+	app.pauseTunnel()
+}
+
+// pauseTunnel stops the SSH process with SIGSTOP and suspends health checks.
+// Established SOCKS5 connections stay open but carry no traffic until resumed.
+func (app *Application) pauseTunnel() {
+	logger := app.componentLogger(componentTunnel)
+
+	app.sshMutex.RLock()
+	defer app.sshMutex.RUnlock()
+
+	if !app.isProcessRunning(app.sshProcess) {
+		logger.Warn("No SSH process to pause")
+		return
+	}
+	if err := suspendProcess(app.sshProcess.Process); err != nil {
+		logger.Error("Failed to pause SSH process", "error", err)
+		return
+	}
+	app.paused.Store(true)
+	logger.Info("Tunnel paused, health checks suspended", "pid", app.sshProcess.Process.Pid)
+}
+
+// resumeTunnel continues a paused SSH process and re-enables health checks.
+func (app *Application) resumeTunnel() {
+	logger := app.componentLogger(componentTunnel)
+	if !app.paused.Load() {
+		logger.Info("Tunnel is not paused")
+		return
+	}
+
+	app.sshMutex.RLock()
+	defer app.sshMutex.RUnlock()
+
+	if app.isProcessRunning(app.sshProcess) {
+		if err := resumeProcess(app.sshProcess.Process); err != nil {
+			logger.Error("Failed to resume SSH process", "error", err)
+			return
+		}
+	}
+	app.paused.Store(false)
+	logger.Info("Tunnel resumed, health checks enabled")
+}
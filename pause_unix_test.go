@@ -0,0 +1,80 @@
+//go:build !windows
+
+package main
+
+import (
+	"log/slog"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// newPauseTestApp returns an app whose SSH process is a running sleep.
+func newPauseTestApp(t *testing.T) *Application {
+	t.Helper()
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start process: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	app.sshProcess = cmd
+	return app
+}
+
+func TestHandlePauseSignal_Toggle(t *testing.T) {
+	app := newPauseTestApp(t)
+
+	app.handlePauseSignal(syscall.SIGUSR1)
+	if !app.paused.Load() {
+		t.Fatal("first SIGUSR1 should pause")
+	}
+
+	app.handlePauseSignal(syscall.SIGUSR1)
+	if app.paused.Load() {
+		t.Error("second SIGUSR1 should resume")
+	}
+}
+
+func TestHandlePauseSignal_SIGUSR2Resumes(t *testing.T) {
+	app := newPauseTestApp(t)
+
+	app.handlePauseSignal(syscall.SIGUSR1)
+	app.handlePauseSignal(syscall.SIGUSR2)
+	if app.paused.Load() {
+		t.Error("SIGUSR2 should resume")
+	}
+
+	app.handlePauseSignal(syscall.SIGUSR2)
+	if app.paused.Load() {
+		t.Error("SIGUSR2 should never pause")
+	}
+}
+
+func TestPauseTunnel_NoProcess(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+
+	app.pauseTunnel()
+	if app.paused.Load() {
+		t.Error("should not pause without an SSH process")
+	}
+}
+
+func TestRestartTunnel_SkippedWhilePaused(t *testing.T) {
+	app := newPauseTestApp(t)
+	app.pauseTunnel()
+	defer app.resumeTunnel()
+
+	app.restartTunnel()
+
+	if got := app.Stats().TotalRestarts; got != 0 {
+		t.Errorf("TotalRestarts = %d, want 0 while paused", got)
+	}
+}
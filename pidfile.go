@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pidFileContent is the JSON stored in the PID file.
+type pidFileContent struct {
+	PID     int       `json:"pid"`
+	Hash    string    `json:"hash,omitempty"` // SHA-256 of the binary that wrote the file
+	Started time.Time `json:"started,omitzero"`
+	HostKey string    `json:"host_key,omitempty"` // SSH server fingerprints, see checkHostKey
+}
+
+// binaryHash returns the SHA-256 of the running executable, computed once; replaced in tests.
+var binaryHash = sync.OnceValues(executableHash)
+
+// executableHash hashes the file at os.Executable().
+func executableHash() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// otherBinary reports whether the PID file was written by a different binary than hash,
+// e.g. before an upgrade. Files in the old plain-PID format count as another binary.
+// An unknown current hash matches anything.
+func (c pidFileContent) otherBinary(hash string) bool {
+	return hash != "" && c.Hash != hash
+}
+
+// writePIDFile stores content in pidFile, readable only by the current user.
+// The file is replaced atomically: with --daemon, parent and child may write it concurrently.
+func writePIDFile(pidFile string, content pidFileContent) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(pidFile, data)
+}
+
+// writeFileAtomic replaces path with data via a temporary file in the same directory,
+// so readers see either the old or the new content. The file is readable only by the
+// current user.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// parsePIDFile decodes PID file content, either JSON or the old plain PID format.
+func parsePIDFile(data []byte) (pidFileContent, error) {
+	data = bytes.TrimSpace(data)
+	if pid, err := strconv.Atoi(string(data)); err == nil {
+		return pidFileContent{PID: pid}, nil
+	}
+
+	var content pidFileContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return pidFileContent{}, fmt.Errorf("failed to parse PID: %w", err)
+	}
+	if content.PID <= 0 {
+		return pidFileContent{}, fmt.Errorf("failed to parse PID: invalid pid %d", content.PID)
+	}
+	return content, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// openSSHVersion is the major.minor release of the ssh binary.
+type openSSHVersion struct {
+	major, minor int
+}
+
+// pkcs11OptionVersion is the first release where the provider is passed as -o PKCS11Provider=.
+var pkcs11OptionVersion = openSSHVersion{8, 2}
+
+// channelTimeoutVersion is the first release with the ChannelTimeout option.
+var channelTimeoutVersion = openSSHVersion{9, 2}
+
+// detectSSHVersion points to the ssh -V lookup and is replaced in tests.
+var detectSSHVersion = sshVersion
+
+// openSSHVersionPattern matches e.g. "OpenSSH_9.2p1" and "OpenSSH_for_Windows_8.1p1".
+var openSSHVersionPattern = regexp.MustCompile(`OpenSSH_(?:for_Windows_)?(\d+)\.(\d+)`)
+
+// parseOpenSSHVersion extracts the release from "ssh -V" output.
+func parseOpenSSHVersion(output string) (openSSHVersion, bool) {
+	m := openSSHVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return openSSHVersion{}, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return openSSHVersion{major, minor}, true
+}
+
+// less reports whether v is an older release than other.
+func (v openSSHVersion) less(other openSSHVersion) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	return v.minor < other.minor
+}
+
+// detectVersion records the release of the ssh binary in sshVersion, or leaves it
+// unset if ssh -V fails or its output isn't recognized.
+func (c *config) detectVersion() {
+	if _, output, err := detectSSHVersion(); err == nil {
+		if v, ok := parseOpenSSHVersion(output); ok {
+			c.sshVersion = v
+		}
+	}
+}
+
+// validatePKCS11Provider checks the provider library.
+func (c *config) validatePKCS11Provider() error {
+	info, err := os.Stat(c.SSHPKCS11Provider)
+	if err != nil {
+		return fmt.Errorf("invalid PKCS#11 provider: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("invalid PKCS#11 provider: %s is a directory", c.SSHPKCS11Provider)
+	}
+	return nil
+}
+
+// pkcs11Options returns the ssh arguments loading the PKCS#11 provider; an unknown
+// ssh version is treated as current.
+func (c *config) pkcs11Options() []string {
+	if c.sshVersion != (openSSHVersion{}) && c.sshVersion.less(pkcs11OptionVersion) {
+		return []string{"-I", c.SSHPKCS11Provider}
+	}
+	return []string{"-o", "PKCS11Provider=" + c.SSHPKCS11Provider}
+}
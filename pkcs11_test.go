@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useSSHVersion makes version detection report output for the duration of the test.
+func useSSHVersion(t *testing.T, output string, err error) {
+	t.Helper()
+	original := detectSSHVersion
+	detectSSHVersion = func() (string, string, error) { return "/usr/bin/ssh", output, err }
+	t.Cleanup(func() { detectSSHVersion = original })
+}
+
+// mockPKCS11Provider creates an empty file standing in for a provider library.
+func mockPKCS11Provider(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "opensc-pkcs11.so")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("failed to write provider: %v", err)
+	}
+	return path
+}
+
+func TestParseOpenSSHVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   openSSHVersion
+		ok     bool
+	}{
+		{"OpenSSH_9.2p1 Debian-2+deb12u7, OpenSSL 3.0.17 1 Jul 2025", openSSHVersion{9, 2}, true},
+		{"OpenSSH_8.1p1, LibreSSL 2.7.3", openSSHVersion{8, 1}, true},
+		{"OpenSSH_for_Windows_8.1p1, LibreSSL 3.0.2", openSSHVersion{8, 1}, true},
+		{"Sun_SSH_1.1", openSSHVersion{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			got, ok := parseOpenSSHVersion(tt.output)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseOpenSSHVersion() = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSerializeSSHOptions_PKCS11Provider(t *testing.T) {
+	provider := mockPKCS11Provider(t)
+
+	tests := []struct {
+		name    string
+		version string
+		err     error
+		want    []string
+	}{
+		{"current release", "OpenSSH_9.2p1", nil, []string{"-o", "PKCS11Provider=" + provider}},
+		{"first option release", "OpenSSH_8.2p1", nil, []string{"-o", "PKCS11Provider=" + provider}},
+		{"older release", "OpenSSH_8.1p1", nil, []string{"-I", provider}},
+		{"unknown version", "", errors.New("ssh not found"), []string{"-o", "PKCS11Provider=" + provider}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSSHVersion(t, tt.version, tt.err)
+
+			cfg := validConfig()
+			cfg.SSHPKCS11Provider = provider
+			if err := cfg.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			joined := strings.Join(cfg.serializeSSHOptions(), " ")
+			if want := strings.Join(tt.want, " "); !strings.Contains(joined, want) {
+				t.Errorf("options %q do not contain %q", joined, want)
+			}
+		})
+	}
+}
+
+func TestValidate_PKCS11Provider(t *testing.T) {
+	useSSHVersion(t, "OpenSSH_9.2p1", nil)
+
+	cfg := validConfig()
+	cfg.SSHPKCS11Provider = filepath.Join(t.TempDir(), "missing.so")
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for missing provider")
+	}
+
+	cfg = validConfig()
+	cfg.SSHPKCS11Provider = t.TempDir()
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for provider directory")
+	}
+
+	cfg = validConfig()
+	if opts := cfg.serializeSSHOptions(); slices.Contains(opts, "-I") {
+		t.Errorf("unexpected -I without provider: %v", opts)
+	}
+}
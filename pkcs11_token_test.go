@@ -0,0 +1,41 @@
+//go:build pkcs11
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestPKCS11Provider_Token loads a real provider with an attached token.
+// Run with: SSH_TUNNEL_TEST_PKCS11_PROVIDER=/path/to/provider.so go test -tags pkcs11 -run TestPKCS11Provider_Token
+func TestPKCS11Provider_Token(t *testing.T) {
+	if os.Getenv("CI") != "" {
+		t.Skip("requires a hardware token")
+	}
+	provider := os.Getenv("SSH_TUNNEL_TEST_PKCS11_PROVIDER")
+	if provider == "" {
+		t.Skip("SSH_TUNNEL_TEST_PKCS11_PROVIDER not set")
+	}
+
+	cfg := validConfig()
+	cfg.SSHPKCS11Provider = provider
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	opts := strings.Join(cfg.serializeSSHOptions(), " ")
+	if !strings.Contains(opts, provider) {
+		t.Errorf("options %q do not load the provider", opts)
+	}
+
+	// ssh-keygen -D lists the public keys on the token through the same provider
+	out, err := exec.Command("ssh-keygen", "-D", provider).CombinedOutput() //nolint:gosec
+	if err != nil {
+		t.Fatalf("ssh-keygen -D failed: %v: %s", err, out)
+	}
+	if !strings.Contains(string(out), "ssh-") && !strings.Contains(string(out), "ecdsa-") {
+		t.Errorf("no public keys found on the token: %s", out)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+)
+
+// policyRule is one CEL expression from the policy file.
+type policyRule struct {
+	line       int
+	expression string
+	message    string // comment directly above the rule, if any
+}
+
+// readPolicyFile reads one CEL expression per line. Blank lines are ignored, and
+// lines starting with # are comments; the comment right above a rule is reported
+// when the rule fails.
+func readPolicyFile(path string) ([]policyRule, error) {
+	file, err := os.Open(path) //nolint:gosec // path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to open policy file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var rules []policyRule
+	var comment string
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			comment = ""
+		case strings.HasPrefix(line, "#"):
+			comment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		default:
+			rules = append(rules, policyRule{line: lineNum, expression: line, message: comment})
+			comment = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return rules, nil
+}
+
+// policyInput returns the fields set from the environment, keyed by field name,
+// for the policy's config variable.
+func (c *config) policyInput() map[string]any {
+	input := make(map[string]any)
+	value := reflect.ValueOf(c).Elem()
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		if !field.IsExported() || field.Tag.Get("env") == "" || field.Tag.Get("env") == "-" {
+			continue
+		}
+		input[field.Name] = value.Field(i).Interface()
+	}
+	return input
+}
+
+// checkPolicy evaluates the rules of PolicyFile against the config and returns
+// the first one that doesn't hold.
+func (c *config) checkPolicy() error {
+	if c.PolicyFile == "" {
+		return nil
+	}
+	rules, err := readPolicyFile(c.PolicyFile)
+	if err != nil {
+		return err
+	}
+
+	celEnv, err := cel.NewEnv(cel.Variable("config", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return fmt.Errorf("failed to set up policy evaluation: %w", err)
+	}
+	input := map[string]any{"config": c.policyInput()}
+	for _, rule := range rules {
+		ast, issues := celEnv.Compile(rule.expression)
+		if issues.Err() != nil {
+			return fmt.Errorf("policy file %s line %d: %w", c.PolicyFile, rule.line, issues.Err())
+		}
+		program, err := celEnv.Program(ast)
+		if err != nil {
+			return fmt.Errorf("policy file %s line %d: %w", c.PolicyFile, rule.line, err)
+		}
+		out, _, err := program.Eval(input)
+		if err != nil {
+			return fmt.Errorf("policy file %s line %d: %w", c.PolicyFile, rule.line, err)
+		}
+		passed, ok := out.Value().(bool)
+		if !ok {
+			return fmt.Errorf("policy file %s line %d: rule must evaluate to a bool, got %s", c.PolicyFile, rule.line, out.Type())
+		}
+		if !passed {
+			message := rule.message
+			if message == "" {
+				message = rule.expression
+			}
+			return fmt.Errorf("policy violation: %s", message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.cel")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr string
+	}{
+		{"passes", "config.SSHRemotePort in [22, 2212]\nconfig.MainLoopSleep >= duration('10s')\n", ""},
+		{"comments and blank lines", "# only known ports\n\nconfig.SSHRemotePort > 0\n", ""},
+		{"violation with message", "# remote port must be 22 or 2222\nconfig.SSHRemotePort in [22, 2222]\n", "policy violation: remote port must be 22 or 2222"},
+		{"violation without message", "config.SSHRemotePort in [22, 2222]\n", "policy violation: config.SSHRemotePort in [22, 2222]"},
+		{"blank line detaches comment", "# unrelated\n\nconfig.SSHStrictHostChecking\n", "policy violation: config.SSHStrictHostChecking"},
+		{"string fields", "config.SSHRemoteAddress.endsWith('@host')\n", ""},
+		{"syntax error", "config.SSHRemotePort in [22,\n", "line 1"},
+		{"not a bool", "config.SSHRemotePort\n", "must evaluate to a bool"},
+		{"unknown field", "config.NoSuchField == 1\n", "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.PolicyFile = writePolicy(t, tt.policy)
+			err := cfg.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckPolicy_MissingFile(t *testing.T) {
+	cfg := validConfig()
+	cfg.PolicyFile = filepath.Join(t.TempDir(), "missing.cel")
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "failed to open policy file") {
+		t.Errorf("want open error, got %v", err)
+	}
+}
+
+func TestPolicyInput_SkipsUnparsedFields(t *testing.T) {
+	cfg := validConfig()
+	input := cfg.policyInput()
+	if _, ok := input["SSHRemotePort"]; !ok {
+		t.Error("missing SSHRemotePort")
+	}
+	if _, ok := input["Tunnels"]; ok {
+		t.Error("Tunnels is not set from the environment and should be left out")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Proxy port check failures that decide how soon the tunnel restarts.
+var (
+	// errPortRefused: nothing listens on the port, e.g. ssh died or its socket was
+	// reclaimed by the OS after hibernation or a network interface change.
+	errPortRefused = errors.New("connection refused")
+	// errPortTimedOut: the port didn't answer in time, ssh may be alive with traffic blocked.
+	errPortTimedOut = errors.New("connection timed out")
+)
+
+// classifyDialError wraps a failed port check dial in errPortRefused or errPortTimedOut
+// when it is one of those, and returns other errors unchanged.
+func classifyDialError(err error) error {
+	var netErr net.Error
+	switch {
+	case isConnRefused(err):
+		return fmt.Errorf("%w: %w", errPortRefused, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", errPortTimedOut, err)
+	}
+	return err
+}
+
+// restartOnFailure reports whether a failed traffic check restarts the tunnel. A refused
+// proxy port restarts it right away. A timed out one is tolerated for up to
+// TrafficCheckFailureThreshold consecutive failures, then the restart policy decides,
+// like for any other failure. Main loop only, after the result was recorded.
+func (app *Application) restartOnFailure(err error) bool {
+	logger := app.componentLogger(componentTunnel)
+	switch {
+	case errors.Is(err, errPortRefused):
+		logger.Warn("Proxy port refused the connection, restarting without waiting for the failure threshold")
+		return true
+	case errors.Is(err, errPortTimedOut):
+		failures := app.consecutiveFailures.Load()
+		if failures <= int64(app.config.TrafficCheckFailureThreshold) {
+			logger.Info("Proxy port timed out, waiting for the failure threshold before restarting",
+				"consecutive_failures", failures, "failure_threshold", app.config.TrafficCheckFailureThreshold)
+			return false
+		}
+	}
+	return app.shouldRestart()
+}
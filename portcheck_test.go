@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCheckProxies_ClassifiesFailures(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+
+	// A port that was just released refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	_ = ln.Close()
+
+	err = app.checkProxies(t.Context(), app.logger, []string{closed})
+	if !errors.Is(err, errPortRefused) {
+		t.Errorf("closed port: err = %v, want errPortRefused", err)
+	}
+
+	ctx, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+	err = app.checkProxies(ctx, app.logger, []string{closed})
+	if !errors.Is(err, errPortTimedOut) {
+		t.Errorf("expired deadline: err = %v, want errPortTimedOut", err)
+	}
+}
+
+func TestClassifyDialError_Other(t *testing.T) {
+	other := errors.New("no route to host")
+	if err := classifyDialError(other); err != other {
+		t.Errorf("classifyDialError() = %v, want the error unchanged", err)
+	}
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	refused := fmt.Errorf("proxy port unavailable: %w", errPortRefused)
+	timedOut := fmt.Errorf("proxy port unavailable: %w", errPortTimedOut)
+
+	tests := []struct {
+		name     string
+		err      error
+		policy   string
+		failures int64
+		want     bool
+	}{
+		{"refused", refused, restartPolicyOnFailure, 1, true},
+		{"refused ignores health score", refused, restartPolicyHealthScore, 1, true},
+		{"timed out below threshold", timedOut, restartPolicyOnFailure, 1, false},
+		{"timed out at threshold", timedOut, restartPolicyOnFailure, 3, false},
+		{"timed out above threshold", timedOut, restartPolicyOnFailure, 4, true},
+		{"timed out above threshold, healthy score", timedOut, restartPolicyHealthScore, 4, false},
+		{"other failure", errors.New("unexpected status code: 502"), restartPolicyOnFailure, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.logger = slog.New(slog.DiscardHandler)
+			app.config.TrafficCheckFailureThreshold = 3
+			app.config.RestartPolicy = tt.policy
+			app.config.HealthRestartThreshold = 0.5
+			storeFloat(&app.healthScore, 0.9)
+			app.consecutiveFailures.Store(tt.failures)
+
+			if got := app.restartOnFailure(tt.err); got != tt.want {
+				t.Errorf("restartOnFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// autoSelectPort moves SSHBindHost to the next free port when the configured one is in use,
+// trying up to SSHAutoSelectPortRange ports. It reports whether the port was changed.
+// Must run before the port-specific PID and log files are created.
+func (c *config) autoSelectPort() (bool, error) {
+	if !c.SSHAutoSelectPort {
+		return false, nil
+	}
+
+	host, port, err := net.SplitHostPort(c.SSHBindHost)
+	if err != nil {
+		return false, fmt.Errorf("invalid bind host: %w", err)
+	}
+	first, err := strconv.Atoi(port)
+	if err != nil {
+		return false, fmt.Errorf("invalid bind host port: %s", port)
+	}
+
+	for candidate := first; candidate < first+c.SSHAutoSelectPortRange && candidate <= 65535; candidate++ {
+		bindHost := net.JoinHostPort(host, strconv.Itoa(candidate))
+		if !portAvailable(bindHost) {
+			continue
+		}
+		if candidate == first {
+			return false, nil
+		}
+
+		c.selectedFrom = c.SSHBindHost
+		c.SSHBindHost = bindHost
+		return true, c.deriveProxyHost()
+	}
+
+	return false, fmt.Errorf("no free port in %d attempts starting at %s", c.SSHAutoSelectPortRange, c.SSHBindHost)
+}
+
+// keepSelectedPort carries an auto-selected port over to a reloaded config
+// that still names the originally configured bind host.
+func (c *config) keepSelectedPort(current *config) error {
+	if current.selectedFrom == "" || c.SSHBindHost != current.selectedFrom {
+		return nil
+	}
+	c.selectedFrom = current.selectedFrom
+	c.SSHBindHost = current.SSHBindHost
+	return c.deriveProxyHost()
+}
+
+// checkBindHostsFree fails when another process already listens on a proxy address.
+// ssh would only warn about the failed bind on its stderr and keep running without the proxy.
+// Skipped with SSH_TUNNEL_AUTO_SELECT_PORT, which moves to a free port instead.
+func (c *config) checkBindHostsFree() error {
+	if c.SSHAutoSelectPort {
+		return nil
+	}
+	for _, bindHost := range c.bindHosts() {
+		listener, err := net.Listen("tcp", bindHost)
+		if err != nil {
+			if isAddrInUse(err) {
+				return fmt.Errorf("proxy address %s is already in use by another process", bindHost)
+			}
+			// Let ssh report anything else
+			continue
+		}
+		_ = listener.Close()
+	}
+	return nil
+}
+
+// portAvailable reports whether bindHost can currently be listened on.
+func portAvailable(bindHost string) bool {
+	listener, err := net.Listen("tcp", bindHost)
+	if err != nil {
+		return false
+	}
+	_ = listener.Close()
+	return true
+}
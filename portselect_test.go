@@ -0,0 +1,228 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// bindFreePorts listens on n consecutive loopback ports and returns the first one.
+// Retries from a new base when one of the following ports is taken.
+func bindFreePorts(t *testing.T, n int) int {
+	t.Helper()
+
+	for attempt := 0; attempt < 20; attempt++ {
+		first, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		base := listenerPort(t, first)
+		listeners := []net.Listener{first}
+		for port := base + 1; port < base+n; port++ {
+			l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+			if err != nil {
+				break
+			}
+			listeners = append(listeners, l)
+		}
+		if len(listeners) == n {
+			t.Cleanup(func() {
+				for _, l := range listeners {
+					_ = l.Close()
+				}
+			})
+			return base
+		}
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+	t.Fatalf("could not bind %d consecutive ports", n)
+	return 0
+}
+
+func TestAutoSelectPort(t *testing.T) {
+	base := bindFreePorts(t, 2)
+	bindHost := func(port int) string { return net.JoinHostPort("127.0.0.1", strconv.Itoa(port)) }
+
+	cfg := validConfig()
+	cfg.SSHBindHost = bindHost(base)
+	cfg.SSHAutoSelectPort = true
+	cfg.SSHAutoSelectPortRange = 10
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	selected, err := cfg.autoSelectPort()
+	if err != nil {
+		t.Fatalf("autoSelectPort: %v", err)
+	}
+	if !selected {
+		t.Fatal("expected a different port to be selected")
+	}
+
+	port, err := strconv.Atoi(cfg.proxyPort)
+	if err != nil {
+		t.Fatalf("invalid proxy port %q", cfg.proxyPort)
+	}
+	if port < base+2 || port >= base+10 {
+		t.Errorf("selected port %d outside [%d, %d)", port, base+2, base+10)
+	}
+	if cfg.SSHBindHost != bindHost(port) || cfg.proxyHost != bindHost(port) {
+		t.Errorf("bind host %q / proxy host %q do not match port %d", cfg.SSHBindHost, cfg.proxyHost, port)
+	}
+	if cfg.selectedFrom != bindHost(base) {
+		t.Errorf("selectedFrom = %q, want %q", cfg.selectedFrom, bindHost(base))
+	}
+	if want := "ssh-tunnel-" + cfg.proxyPort + ".log"; cfg.getPortSpecificLogFile() != want {
+		t.Errorf("log file = %q, want %q", cfg.getPortSpecificLogFile(), want)
+	}
+}
+
+func TestAutoSelectPort_Exhausted(t *testing.T) {
+	base := bindFreePorts(t, 2)
+
+	cfg := validConfig()
+	cfg.SSHBindHost = net.JoinHostPort("127.0.0.1", strconv.Itoa(base))
+	cfg.SSHAutoSelectPort = true
+	cfg.SSHAutoSelectPortRange = 2
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if _, err := cfg.autoSelectPort(); err == nil {
+		t.Fatal("expected an error when every port in the range is in use")
+	}
+}
+
+func TestAutoSelectPort_ConfiguredPortFree(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listenerPort(t, listener)
+	_ = listener.Close()
+
+	cfg := validConfig()
+	cfg.SSHBindHost = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	cfg.SSHAutoSelectPort = true
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	selected, err := cfg.autoSelectPort()
+	if err != nil {
+		t.Fatalf("autoSelectPort: %v", err)
+	}
+	if selected || cfg.proxyPort != strconv.Itoa(port) {
+		t.Errorf("selected = %v, proxy port = %s; want the configured port %d", selected, cfg.proxyPort, port)
+	}
+}
+
+func TestAutoSelectPort_Disabled(t *testing.T) {
+	base := bindFreePorts(t, 1)
+
+	cfg := validConfig()
+	cfg.SSHBindHost = net.JoinHostPort("127.0.0.1", strconv.Itoa(base))
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	selected, err := cfg.autoSelectPort()
+	if err != nil || selected {
+		t.Errorf("autoSelectPort() = %v, %v; want false, nil", selected, err)
+	}
+}
+
+func TestKeepSelectedPort(t *testing.T) {
+	current := validConfig()
+	current.SSHBindHost = "127.0.0.1:8081"
+	current.selectedFrom = "127.0.0.1:8080"
+
+	tests := []struct {
+		name     string
+		bindHost string
+		want     string
+	}{
+		{"configured bind host unchanged", "127.0.0.1:8080", "127.0.0.1:8081"},
+		{"bind host changed in file", "127.0.0.1:9090", "127.0.0.1:9090"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.SSHBindHost = tt.bindHost
+			if err := cfg.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			if err := cfg.keepSelectedPort(&current); err != nil {
+				t.Fatalf("keepSelectedPort: %v", err)
+			}
+			if cfg.SSHBindHost != tt.want || cfg.proxyHost != tt.want {
+				t.Errorf("bind host %q / proxy host %q, want %q", cfg.SSHBindHost, cfg.proxyHost, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBindHostsFree(t *testing.T) {
+	inUse := net.JoinHostPort("127.0.0.1", strconv.Itoa(bindFreePorts(t, 1)))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	free := listener.Addr().String()
+	_ = listener.Close()
+
+	tests := []struct {
+		name       string
+		bindHosts  []string
+		autoSelect bool
+		ok         bool
+	}{
+		{"free", []string{free}, false, true},
+		{"in use", []string{inUse}, false, false},
+		{"second of several in use", []string{free, inUse}, false, false},
+		{"in use with auto select", []string{inUse}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.SSHBindHosts = tt.bindHosts
+			cfg.SSHAutoSelectPort = tt.autoSelect
+			err := cfg.checkBindHostsFree()
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if err != nil && !strings.Contains(err.Error(), inUse+" is already in use") {
+				t.Errorf("error %q should name the busy address", err)
+			}
+		})
+	}
+}
+
+func TestStartSSH_BindHostInUseSkipsStart(t *testing.T) {
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.SSHBindHost = net.JoinHostPort("127.0.0.1", strconv.Itoa(bindFreePorts(t, 1)))
+	if err := app.config.deriveProxyHost(); err != nil {
+		t.Fatalf("deriveProxyHost: %v", err)
+	}
+
+	if err := app.startSSH(app.logger); err == nil {
+		t.Fatal("expected error when the proxy address is in use")
+	}
+	if app.sshProcess != nil {
+		t.Error("no SSH process should be started")
+	}
+	if got := app.Stats().ConsecutiveFailures; got != 1 {
+		t.Errorf("ConsecutiveFailures = %d, want 1", got)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "fmt"
+
+// Releases adding hybrid post-quantum key exchange methods.
+var (
+	sntrupKexVersion = openSSHVersion{8, 5} // sntrup761x25519-sha512@openssh.com
+	mlkemKexVersion  = openSSHVersion{9, 9} // mlkem768x25519-sha256
+)
+
+// Algorithms offered with SSHPostQuantumOnly. The ciphers and MACs all use 256-bit keys
+// or hashes, which keep a 128-bit margin against Grover's algorithm.
+const (
+	postQuantumCiphers = "aes256-gcm@openssh.com,chacha20-poly1305@openssh.com,aes256-ctr"
+	postQuantumMACs    = "hmac-sha2-512-etm@openssh.com,hmac-sha2-256-etm@openssh.com"
+	classicalKex       = "curve25519-sha256"
+)
+
+// postQuantumKex returns the KexAlgorithms of SSHPostQuantumOnly for the ssh release,
+// and false when the release has no post-quantum method and classicalKex stands in.
+// An unknown ssh version is treated as current.
+func (c *config) postQuantumKex() (string, bool) {
+	unknown := c.sshVersion == (openSSHVersion{})
+	switch {
+	case unknown || !c.sshVersion.less(mlkemKexVersion):
+		return "mlkem768x25519-sha256,sntrup761x25519-sha512@openssh.com", true
+	case !c.sshVersion.less(sntrupKexVersion):
+		return "sntrup761x25519-sha512@openssh.com", true
+	default:
+		return classicalKex, false
+	}
+}
+
+// postQuantumOptions returns the ssh arguments restricting key exchange, ciphers and MACs.
+func (c *config) postQuantumOptions() []string {
+	kex, _ := c.postQuantumKex()
+	return []string{
+		"-o", "KexAlgorithms=" + kex,
+		"-o", "Ciphers=" + postQuantumCiphers,
+		"-o", "MACs=" + postQuantumMACs,
+	}
+}
+
+// String formats the release as e.g. "9.2".
+func (v openSSHVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSerializeSSHOptions_PostQuantumOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantKex string
+	}{
+		{"ML-KEM", "OpenSSH_9.9p1, OpenSSL 3.4.0 22 Oct 2024", nil, "mlkem768x25519-sha256,sntrup761x25519-sha512@openssh.com"},
+		{"sntrup only", "OpenSSH_9.2p1 Debian-2+deb12u7, OpenSSL 3.0.17 1 Jul 2025", nil, "sntrup761x25519-sha512@openssh.com"},
+		{"no post-quantum method", "OpenSSH_8.4p1, OpenSSL 1.1.1k 25 Mar 2021", nil, "curve25519-sha256"},
+		{"unknown version", "", errors.New("ssh not found"), "mlkem768x25519-sha256,sntrup761x25519-sha512@openssh.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSSHVersion(t, tt.output, tt.err)
+			cfg := validConfig()
+			cfg.SSHPostQuantumOnly = true
+			if err := cfg.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			opts := cfg.serializeSSHOptions()
+			for _, want := range []string{
+				"KexAlgorithms=" + tt.wantKex,
+				"Ciphers=" + postQuantumCiphers,
+				"MACs=" + postQuantumMACs,
+			} {
+				if !slices.Contains(opts, want) {
+					t.Errorf("missing %q: %v", want, opts)
+				}
+			}
+		})
+	}
+}
+
+func TestSerializeSSHOptions_PostQuantumOff(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	for _, opt := range cfg.serializeSSHOptions() {
+		if strings.HasPrefix(opt, "KexAlgorithms=") || strings.HasPrefix(opt, "Ciphers=") || strings.HasPrefix(opt, "MACs=") {
+			t.Errorf("unexpected %q without POST_QUANTUM_ONLY", opt)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// goroutineLeakThreshold is how many goroutines above the startup baseline
+// goroutineLeakCheck tolerates at shutdown.
+const goroutineLeakThreshold = 5
+
+// goroutineLeakWait bounds the wait for goroutines that exit on shutdownChan.
+const goroutineLeakWait = time.Second
+
+// startPprofServer serves the net/http/pprof handlers on PprofAddr, if set.
+func (app *Application) startPprofServer() error {
+	if app.config.PprofAddr == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", app.config.PprofAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", app.config.PprofAddr, err)
+	}
+
+	app.pprofServer = &http.Server{
+		Handler:           pprofHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := app.pprofServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Error("Profiling server failed", "error", err)
+		}
+	}()
+
+	app.logger.Info("Profiling server listening", "addr", listener.Addr().String())
+	return nil
+}
+
+// stopPprofServer shuts down the profiling server.
+func (app *Application) stopPprofServer() {
+	if app.pprofServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A running CPU profile or trace would hold up Shutdown until it ends
+	if err := app.pprofServer.Shutdown(ctx); err != nil {
+		_ = app.pprofServer.Close()
+	}
+}
+
+// pprofHandler routes /debug/pprof/ on its own mux, so the handlers that importing
+// net/http/pprof registers on http.DefaultServeMux are never served.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// goroutineLeakCheck warns when more than goroutineLeakThreshold goroutines are left
+// over at shutdown compared with the baseline taken at startup. Background loops exit
+// on shutdownChan without being waited for, so they get goroutineLeakWait to finish.
+func (app *Application) goroutineLeakCheck() {
+	if app.baseGoroutines == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(goroutineLeakWait)
+	count := runtime.NumGoroutine()
+	for count > app.baseGoroutines+goroutineLeakThreshold && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		count = runtime.NumGoroutine()
+	}
+	if count > app.baseGoroutines+goroutineLeakThreshold {
+		app.logger.Warn("Goroutines left over at shutdown", "goroutines", count, "baseline", app.baseGoroutines)
+	}
+}
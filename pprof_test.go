@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestValidate_PprofAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr        string
+		allowRemote bool
+		want        string
+		ok          bool
+	}{
+		{"unset", "", false, "", true},
+		{"port only", ":6060", false, "127.0.0.1:6060", true},
+		{"localhost", "localhost:6060", false, "localhost:6060", true},
+		{"IPv6 loopback", "[::1]:6060", false, "[::1]:6060", true},
+		{"all interfaces", "0.0.0.0:6060", false, "", false},
+		{"all interfaces allowed", "0.0.0.0:6060", true, "0.0.0.0:6060", true},
+		{"port only allowed remote", ":6060", true, ":6060", true},
+		{"invalid", "6060", false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.PprofAddr = tt.addr
+			cfg.PprofAllowRemote = tt.allowRemote
+			err := cfg.validate()
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if err == nil && cfg.PprofAddr != tt.want {
+				t.Errorf("PprofAddr = %q, want %q", cfg.PprofAddr, tt.want)
+			}
+		})
+	}
+}
+
+func TestPprofHandler(t *testing.T) {
+	server := httptest.NewServer(pprofHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body.String(), "goroutine profile") {
+		t.Errorf("status %d, body %q, want the goroutine profile", resp.StatusCode, body.String())
+	}
+}
+
+func TestStartPprofServer(t *testing.T) {
+	app := newTestApp(t)
+	app.config.PprofAddr = "127.0.0.1:0"
+
+	if err := app.startPprofServer(); err != nil {
+		t.Fatalf("startPprofServer: %v", err)
+	}
+	if app.pprofServer == nil {
+		t.Fatal("profiling server should be running")
+	}
+	app.stopPprofServer()
+}
+
+func TestGoroutineLeakCheck(t *testing.T) {
+	app := newTestApp(t)
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&buf, nil))
+	app.baseGoroutines = runtime.NumGoroutine()
+
+	app.goroutineLeakCheck()
+	if buf.Len() > 0 {
+		t.Errorf("no goroutines leaked, got log %q", buf.String())
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	for range 4 * goroutineLeakThreshold {
+		go func() { <-release }()
+	}
+
+	app.goroutineLeakCheck()
+	if !strings.Contains(buf.String(), "Goroutines left over at shutdown") {
+		t.Errorf("log = %q, want a leak warning", buf.String())
+	}
+}
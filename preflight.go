@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+)
+
+// preflightCheck resolves the SSH server and opens a TCP connection to it before ssh
+// is started, so a server that is down costs one log line per restart instead of
+// ssh's key exchange errors. target is the config ssh will be started with. A failure
+// counts as a failed check.
+func (app *Application) preflightCheck(logger *slog.Logger, target *config) error {
+	endpoint, err := target.dialServer()
+	if err != nil {
+		logger.Warn("SSH server unreachable, skipping restart", "remote", endpoint, "error", err)
+		app.recordCheckStats(false)
+		return fmt.Errorf("SSH server unreachable: %w", err)
+	}
+	logger.Debug("SSH server is reachable", "remote", endpoint)
+	return nil
+}
+
+// dialServer connects to the address ssh will use and returns it: the HTTP proxy if the
+// server is reached through one, otherwise the server, resolved unless RESOLVE_ON_RESTART
+// already picked an address.
+func (c *config) dialServer() (string, error) {
+	endpoint := c.httpProxyAddr()
+	if endpoint == "" {
+		host := c.resolvedHost
+		if host == "" {
+			ctx, cancel := context.WithTimeout(context.Background(), c.PreflightCheckTimeout)
+			defer cancel()
+			addrs, err := lookupHost(ctx, c.remoteHost())
+			if err == nil && len(addrs) == 0 {
+				err = errors.New("no addresses")
+			}
+			if err != nil {
+				return c.remoteEndpoint(), fmt.Errorf("failed to resolve %s: %w", c.remoteHost(), err)
+			}
+			if host, err = c.pickServerAddress(addrs); err != nil {
+				return c.remoteEndpoint(), err
+			}
+		}
+		endpoint = net.JoinHostPort(host, strconv.Itoa(c.remotePort()))
+	}
+
+	// The HTTP proxy is reached however ssh's nc reaches it
+	network := c.serverNetwork()
+	if c.httpProxyAddr() != "" {
+		network = "tcp"
+	}
+	conn, err := c.dialer(&net.Dialer{Timeout: c.PreflightCheckTimeout}).DialContext(context.Background(), network, endpoint)
+	if err != nil {
+		return endpoint, err
+	}
+	return endpoint, conn.Close()
+}
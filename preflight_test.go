@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// preflightApp returns an app whose SSH server is ssh.example.com, resolved to 127.0.0.1,
+// at the port of a listener that is closed unless open is true.
+func preflightApp(t *testing.T, open bool) *Application {
+	t.Helper()
+	useLookupHost(t, func(context.Context, string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if open {
+		t.Cleanup(func() { _ = listener.Close() })
+	} else {
+		_ = listener.Close()
+	}
+
+	app := newTestApp(t)
+	app.config.SSHRemoteAddress = "user@ssh.example.com"
+	app.config.SSHRemotePort = listener.Addr().(*net.TCPAddr).Port
+	app.config.PreflightCheck = true
+	app.config.PreflightCheckTimeout = time.Second
+	return app
+}
+
+func TestPreflightCheck(t *testing.T) {
+	app := preflightApp(t, true)
+
+	if err := app.preflightCheck(app.logger, app.config); err != nil {
+		t.Errorf("preflightCheck() = %v with the server up, want nil", err)
+	}
+	if got := app.consecutiveFailures.Load(); got != 0 {
+		t.Errorf("consecutiveFailures = %d, want 0", got)
+	}
+}
+
+func TestPreflightCheck_Unreachable(t *testing.T) {
+	app := preflightApp(t, false)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	if err := app.preflightCheck(logger, app.config); err == nil {
+		t.Fatal("preflightCheck() should fail with the server down")
+	}
+	if got := app.consecutiveFailures.Load(); got != 1 {
+		t.Errorf("consecutiveFailures = %d, want the failure counted", got)
+	}
+	want := "127.0.0.1:" + strconv.Itoa(app.config.SSHRemotePort)
+	if !strings.Contains(buf.String(), "SSH server unreachable, skipping restart") || !strings.Contains(buf.String(), want) {
+		t.Errorf("log = %q, want the unreachable message with %s", buf.String(), want)
+	}
+}
+
+func TestPreflightCheck_ResolveFailure(t *testing.T) {
+	app := preflightApp(t, true)
+	useLookupHost(t, func(context.Context, string) ([]string, error) {
+		return nil, errors.New("no such host")
+	})
+
+	err := app.preflightCheck(app.logger, app.config)
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve ssh.example.com") {
+		t.Errorf("preflightCheck() = %v, want a resolution error", err)
+	}
+}
+
+func TestStartSSH_PreflightFailureSkipsStart(t *testing.T) {
+	app := preflightApp(t, false)
+	originalSSHBinary := sshBinary
+	sshBinary = "ssh-tunnel-test-missing-ssh"
+	t.Cleanup(func() { sshBinary = originalSSHBinary })
+
+	err := app.startSSH(app.logger)
+	if err == nil || !strings.Contains(err.Error(), "SSH server unreachable") {
+		t.Fatalf("startSSH() = %v, want the preflight error", err)
+	}
+	if app.sshProcess != nil {
+		t.Error("ssh should not be started when the server is unreachable")
+	}
+}
+
+func TestStartSSH_PreflightWithoutLock(t *testing.T) {
+	app := preflightApp(t, false)
+	var locked bool
+	useLookupHost(t, func(context.Context, string) ([]string, error) {
+		// Status requests and stopSSH must not wait for the dial
+		if app.sshMutex.TryLock() {
+			app.sshMutex.Unlock()
+		} else {
+			locked = true
+		}
+		return []string{"127.0.0.1"}, nil
+	})
+
+	if err := app.startSSH(app.logger); err == nil {
+		t.Fatal("startSSH should fail with the server down")
+	}
+	if locked {
+		t.Error("sshMutex was held during the preflight check")
+	}
+	if app.sshProcess != nil {
+		t.Error("no SSH process should be started")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// probeResponse is the body of the Kubernetes-style probe endpoints.
+type probeResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// markLoopActive records that the main loop is alive for /livez. busy is set while
+// it handles an event, which may restart the tunnel.
+func (app *Application) markLoopActive(busy bool) {
+	app.lastLoopTick.Store(time.Now().UnixNano())
+	app.loopBusy.Store(busy)
+}
+
+// handleLivez reports whether the main loop is still ticking. A loop that hasn't
+// ticked within two sleep intervals is assumed to be deadlocked; while it handles
+// an event it also gets the time a tunnel start or tick may take.
+func (app *Application) handleLivez(w http.ResponseWriter, r *http.Request) {
+	app.configMutex.RLock()
+	maxAge := 2 * app.loopSleep()
+	if app.loopBusy.Load() {
+		maxAge += max(app.config.TunnelStartTimeout, app.config.TickTimeout)
+	}
+	app.configMutex.RUnlock()
+
+	lastTick := unixNanoTime(app.lastLoopTick.Load())
+	if lastTick.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, probeResponse{Status: "fail", Reason: "main loop has not started"})
+		return
+	}
+	if age := time.Since(lastTick); age > maxAge {
+		writeJSON(w, http.StatusServiceUnavailable, probeResponse{
+			Status: "fail",
+			Reason: fmt.Sprintf("main loop last ticked %s ago", age.Round(time.Second)),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, probeResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether the tunnel is carrying traffic, tolerating up to
+// TrafficCheckFailureThreshold consecutive failed checks, or with the health-score
+// restart policy a health score down to HealthRestartThreshold.
+func (app *Application) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	app.configMutex.RLock()
+	threshold := app.config.TrafficCheckFailureThreshold
+	healthScorePolicy := app.config.RestartPolicy == restartPolicyHealthScore
+	healthThreshold := app.config.HealthRestartThreshold
+	app.configMutex.RUnlock()
+
+	if !app.tunnelStarted.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, probeResponse{Status: "fail", Reason: "tunnel has not started"})
+		return
+	}
+	if healthScorePolicy {
+		if score := loadFloat(&app.healthScore); score < healthThreshold {
+			writeJSON(w, http.StatusServiceUnavailable, probeResponse{
+				Status: "fail",
+				Reason: fmt.Sprintf("health score %.2f is below %.2f", score, healthThreshold),
+			})
+			return
+		}
+	} else if failures := app.consecutiveFailures.Load(); failures > int64(threshold) {
+		writeJSON(w, http.StatusServiceUnavailable, probeResponse{
+			Status: "fail",
+			Reason: fmt.Sprintf("%d consecutive traffic check failures", failures),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, probeResponse{Status: "ok"})
+}
+
+// handleStartupz reports whether the tunnel has become ready at least once.
+func (app *Application) handleStartupz(w http.ResponseWriter, r *http.Request) {
+	if app.tunnelStarted.Load() {
+		writeJSON(w, http.StatusOK, probeResponse{Status: "ok"})
+		return
+	}
+
+	app.configMutex.RLock()
+	threshold := app.config.StartupProbeFailureThreshold
+	app.configMutex.RUnlock()
+
+	reason := "tunnel is starting"
+	if failures := app.consecutiveFailures.Load(); failures >= int64(threshold) {
+		reason = fmt.Sprintf("tunnel failed to start after %d checks", failures)
+	}
+	writeJSON(w, http.StatusServiceUnavailable, probeResponse{Status: "fail", Reason: reason})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestProbe_Livez(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/livez", "", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before the loop starts: status = %d, want 503", resp.StatusCode)
+	}
+
+	app.lastLoopTick.Store(time.Now().UnixNano())
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/livez", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("after a recent tick: status = %d, want 200", resp.StatusCode)
+	}
+
+	app.lastLoopTick.Store(time.Now().Add(-3 * app.config.MainLoopSleep).UnixNano())
+	resp := doRequest(t, http.MethodGet, srv.URL+"/livez", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("after a stalled loop: status = %d, want 503", resp.StatusCode)
+	}
+	var body probeResponse
+	decodeBody(t, resp, &body)
+	if body.Status != "fail" || body.Reason == "" {
+		t.Errorf("unexpected body: %+v", body)
+	}
+}
+
+func TestProbe_LivezDuringLongRestart(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	app.config.MainLoopSleep = 100 * time.Millisecond
+	restarting := make(chan struct{})
+	app.restartStrategy = func(context.Context, *Application, *slog.Logger) error {
+		select {
+		case <-restarting:
+		default:
+			close(restarting)
+		}
+		time.Sleep(5 * app.config.MainLoopSleep)
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.run()
+	}()
+	defer func() {
+		close(app.shutdownChan)
+		<-done
+	}()
+
+	app.restartChan <- struct{}{}
+	<-restarting
+	time.Sleep(3 * app.config.MainLoopSleep)
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/livez", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("during a restart: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestProbe_Readyz(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	app.config.TrafficCheckFailureThreshold = 2
+
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/readyz", "", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before startup: status = %d, want 503", resp.StatusCode)
+	}
+
+	app.tunnelStarted.Store(true)
+	app.consecutiveFailures.Store(2)
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/readyz", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("at the threshold: status = %d, want 200", resp.StatusCode)
+	}
+
+	app.consecutiveFailures.Store(3)
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/readyz", "", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("over the threshold: status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestProbe_ReadyzHealthScore(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	app.config.RestartPolicy = restartPolicyHealthScore
+	app.config.HealthRestartThreshold = 0.5
+	app.tunnelStarted.Store(true)
+
+	// Consecutive failures don't count with the health-score policy
+	app.consecutiveFailures.Store(5)
+	storeFloat(&app.healthScore, 0.5)
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/readyz", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("at the threshold: status = %d, want 200", resp.StatusCode)
+	}
+
+	storeFloat(&app.healthScore, 0.4)
+	resp := doRequest(t, http.MethodGet, srv.URL+"/readyz", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("below the threshold: status = %d, want 503", resp.StatusCode)
+	}
+	var body probeResponse
+	decodeBody(t, resp, &body)
+	if body.Reason != "health score 0.40 is below 0.50" {
+		t.Errorf("reason = %q, want the health score", body.Reason)
+	}
+}
+
+func TestProbe_Startupz(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+	app.config.StartupProbeFailureThreshold = 2
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/startupz", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before startup: status = %d, want 503", resp.StatusCode)
+	}
+	var body probeResponse
+	decodeBody(t, resp, &body)
+	if body.Reason != "tunnel is starting" {
+		t.Errorf("reason = %q, want starting", body.Reason)
+	}
+
+	app.consecutiveFailures.Store(2)
+	resp = doRequest(t, http.MethodGet, srv.URL+"/startupz", "", "")
+	decodeBody(t, resp, &body)
+	if body.Reason != "tunnel failed to start after 2 checks" {
+		t.Errorf("reason = %q, want startup failure", body.Reason)
+	}
+
+	app.tunnelStarted.Store(true)
+	if resp := doRequest(t, http.MethodGet, srv.URL+"/startupz", "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("after startup: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestWaitForTunnelReady_MarksStarted(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	useProxyListener(t, app)
+
+	if err := app.waitForTunnelReady(t.Context(), app.logger); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !app.tunnelStarted.Load() {
+		t.Error("tunnelStarted should be set once the tunnel is ready")
+	}
+}
@@ -8,6 +8,16 @@ import (
 	"syscall"
 )
 
+// isAddrInUse reports whether err is a failed bind to an address that is already in use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
+
+// isConnRefused reports whether err is a dial to an address nothing listens on.
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
 // terminateProcess sends SIGTERM to the process, allowing it to shut down gracefully.
 func terminateProcess(proc *os.Process) error {
 	return proc.Signal(syscall.SIGTERM)
@@ -32,3 +42,13 @@ func isProcessAlive(pid int) (bool, error) {
 	}
 	return false, err
 }
+
+// suspendProcess stops the process with SIGSTOP; its connections stay open.
+func suspendProcess(proc *os.Process) error {
+	return proc.Signal(syscall.SIGSTOP)
+}
+
+// resumeProcess continues a stopped process with SIGCONT.
+func resumeProcess(proc *os.Process) error {
+	return proc.Signal(syscall.SIGCONT)
+}
@@ -22,8 +22,20 @@ const (
 	waitFailed         = 0xFFFFFFFF
 	errAccessDenied    = syscall.Errno(5)
 	errInvalidArgument = syscall.Errno(87)
+	errAddrInUse       = syscall.Errno(10048) // WSAEADDRINUSE
+	errConnRefused     = syscall.Errno(10061) // WSAECONNREFUSED
 )
 
+// isAddrInUse reports whether err is a failed bind to an address that is already in use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, errAddrInUse)
+}
+
+// isConnRefused reports whether err is a dial to an address nothing listens on.
+func isConnRefused(err error) bool {
+	return errors.Is(err, errConnRefused)
+}
+
 // terminateProcess kills the process on Windows.
 // Windows has no equivalent of SIGTERM for external processes,
 // so Process.Kill (TerminateProcess) is the only reliable option.
@@ -62,3 +74,16 @@ func isProcessAlive(pid int) (bool, error) {
 		return false, fmt.Errorf("WaitForSingleObject returned unexpected status: %#x", result)
 	}
 }
+
+// errSuspendUnsupported is returned by suspendProcess and resumeProcess on Windows.
+var errSuspendUnsupported = errors.New("suspending processes is not supported on Windows")
+
+// suspendProcess is not supported on Windows.
+func suspendProcess(*os.Process) error {
+	return errSuspendUnsupported
+}
+
+// resumeProcess is not supported on Windows.
+func resumeProcess(*os.Process) error {
+	return errSuspendUnsupported
+}
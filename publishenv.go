@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	publishEnvFormatShell   = "shell"
+	publishEnvFormatSystemd = "systemd"
+)
+
+// publishEnvContent renders the published variables, as "export" lines that a
+// shell can source or as plain KEY=value lines for systemd's EnvironmentFile.
+func (c *config) publishEnvContent(pid int, startedAt time.Time) []byte {
+	host, port, _ := net.SplitHostPort(c.proxyHost)
+	vars := []struct{ name, value string }{
+		{"SSH_TUNNEL_ACTIVE_HOST", host},
+		{"SSH_TUNNEL_ACTIVE_PORT", port},
+		{"SSH_TUNNEL_ACTIVE_PID", strconv.Itoa(pid)},
+		{"SSH_TUNNEL_STARTED_AT", startedAt.UTC().Format(time.RFC3339)},
+	}
+
+	var b strings.Builder
+	for _, v := range vars {
+		if c.PublishEnvFormat == publishEnvFormatSystemd {
+			fmt.Fprintf(&b, "%s=%s\n", v.name, v.value)
+			continue
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", v.name, shellQuote(v.value))
+	}
+	return []byte(b.String())
+}
+
+// shellQuote wraps s in single quotes so the shell takes it literally.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// publishEnv writes the env file for the ssh process with the given PID, if one is
+// configured. Failures are logged only: the tunnel itself is up.
+func (app *Application) publishEnv(logger *slog.Logger, pid int) {
+	if app.config.PublishEnvFile == "" {
+		return
+	}
+	if err := writeFileAtomic(app.config.PublishEnvFile, app.config.publishEnvContent(pid, time.Now())); err != nil {
+		logger.Error("Failed to publish env file", "path", app.config.PublishEnvFile, "error", err)
+		return
+	}
+	logger.Debug("Published env file", "path", app.config.PublishEnvFile, "pid", pid)
+}
+
+// removePublishedEnv deletes the env file, if one is configured and exists.
+func (c *config) removePublishedEnv() error {
+	if c.PublishEnvFile == "" {
+		return nil
+	}
+	if err := os.Remove(c.PublishEnvFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
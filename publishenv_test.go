@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishEnvContent(t *testing.T) {
+	cfg := validConfig()
+	cfg.proxyHost = "127.0.0.1:8080"
+	startedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", "export SSH_TUNNEL_ACTIVE_HOST='127.0.0.1'\nexport SSH_TUNNEL_ACTIVE_PORT='8080'\n" +
+			"export SSH_TUNNEL_ACTIVE_PID='42'\nexport SSH_TUNNEL_STARTED_AT='2024-05-01T12:00:00Z'\n"},
+		{"systemd", "SSH_TUNNEL_ACTIVE_HOST=127.0.0.1\nSSH_TUNNEL_ACTIVE_PORT=8080\n" +
+			"SSH_TUNNEL_ACTIVE_PID=42\nSSH_TUNNEL_STARTED_AT=2024-05-01T12:00:00Z\n"},
+	}
+	for _, tt := range tests {
+		cfg.PublishEnvFormat = tt.format
+		if got := string(cfg.publishEnvContent(42, startedAt)); got != tt.want {
+			t.Errorf("format %q:\ngot  %q\nwant %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestPublishEnv_SourceableAndRemoved(t *testing.T) {
+	requireShell(t)
+	app := newTestApp(t)
+	path := filepath.Join(t.TempDir(), "tunnel.env")
+	app.config.PublishEnvFile = path
+
+	app.publishEnv(slog.New(slog.DiscardHandler), 4242)
+
+	out, err := exec.Command("sh", "-c", `. "$0" && echo "$SSH_TUNNEL_ACTIVE_HOST:$SSH_TUNNEL_ACTIVE_PORT $SSH_TUNNEL_ACTIVE_PID"`, path).Output()
+	if err != nil {
+		t.Fatalf("sourcing env file: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "127.0.0.1:8080 4242"; got != want {
+		t.Errorf("sourced env = %q, want %q", got, want)
+	}
+
+	if err := app.config.removePublishedEnv(); err != nil {
+		t.Fatalf("removePublishedEnv: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("env file still exists after removal: %v", err)
+	}
+	if err := app.config.removePublishedEnv(); err != nil {
+		t.Errorf("removing a missing env file: %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got, want := shellQuote("it's"), `'it'\''s'`; got != want {
+		t.Errorf("shellQuote = %q, want %q", got, want)
+	}
+}
+
+func TestValidate_PublishEnvFormat(t *testing.T) {
+	for _, value := range []string{"", "shell", "systemd"} {
+		cfg := validConfig()
+		cfg.PublishEnvFormat = value
+		if err := cfg.validate(); err != nil {
+			t.Errorf("format %q: unexpected error: %v", value, err)
+		}
+	}
+
+	cfg := validConfig()
+	cfg.PublishEnvFormat = "json"
+	if err := cfg.validate(); err == nil {
+		t.Error("expected error for unknown publish env format")
+	}
+}
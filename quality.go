@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// qualitySamples is how many recent port checks the connection quality covers.
+const qualitySamples = 20
+
+// qualityMinSamples is how many port checks are needed before a low quality
+// score restarts the tunnel; a couple of samples say little about jitter.
+const qualityMinSamples = 5
+
+// ConnectionQuality summarizes the recent port check round trips of the tunnel.
+type ConnectionQuality struct {
+	LastRTT      float64 `json:"last_rtt_seconds"`
+	AvgRTT       float64 `json:"avg_rtt_seconds"`
+	Jitter       float64 `json:"jitter_seconds"` // standard deviation of the round trips
+	PacketLoss   float64 `json:"packet_loss"`    // share of failed port checks
+	QualityScore float64 `json:"quality_score"`  // 0 to 100
+	Samples      int     `json:"samples"`
+}
+
+// qualityTracker keeps the outcomes of the last qualitySamples port checks. The
+// zero value is ready to use and it is safe for concurrent use.
+type qualityTracker struct {
+	mu      sync.Mutex
+	samples []qualitySample // oldest first
+}
+
+// qualitySample is one port check: its round trip, or a failure.
+type qualitySample struct {
+	rtt time.Duration
+	ok  bool
+}
+
+// observe records a port check; rtt is ignored for failed checks.
+func (q *qualityTracker) observe(rtt time.Duration, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.samples = append(q.samples, qualitySample{rtt: rtt, ok: ok})
+	if extra := len(q.samples) - qualitySamples; extra > 0 {
+		q.samples = q.samples[extra:]
+	}
+}
+
+// reset drops all samples, e.g. for a freshly started tunnel.
+func (q *qualityTracker) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.samples = q.samples[:0]
+}
+
+// snapshot computes the connection quality of the recorded samples. The score is
+// 100 - (jitter/avgRTT)*50 - packetLoss*50, kept within 0 and 100.
+func (q *qualityTracker) snapshot() ConnectionQuality {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quality := ConnectionQuality{Samples: len(q.samples), QualityScore: 100}
+	if len(q.samples) == 0 {
+		return quality
+	}
+
+	var rtts []float64
+	for _, sample := range q.samples {
+		if sample.ok {
+			rtts = append(rtts, sample.rtt.Seconds())
+			quality.LastRTT = sample.rtt.Seconds()
+		}
+	}
+	quality.PacketLoss = 1 - float64(len(rtts))/float64(len(q.samples))
+
+	if len(rtts) > 0 {
+		var sum float64
+		for _, rtt := range rtts {
+			sum += rtt
+		}
+		quality.AvgRTT = sum / float64(len(rtts))
+
+		var variance float64
+		for _, rtt := range rtts {
+			variance += (rtt - quality.AvgRTT) * (rtt - quality.AvgRTT)
+		}
+		quality.Jitter = math.Sqrt(variance / float64(len(rtts)))
+	}
+
+	score := 100 - quality.PacketLoss*50
+	if quality.AvgRTT > 0 {
+		score -= quality.Jitter / quality.AvgRTT * 50
+	}
+	quality.QualityScore = min(max(score, 0), 100)
+	return quality
+}
+
+// qualityDegraded reports whether the connection quality has dropped below
+// QualityRestartThreshold, so a working but unstable tunnel is restarted. It
+// waits for qualityMinSamples port checks since the last restart.
+func (app *Application) qualityDegraded() bool {
+	if app.config.QualityRestartThreshold <= 0 {
+		return false
+	}
+
+	quality := app.quality.snapshot()
+	if quality.Samples < qualityMinSamples || quality.QualityScore >= app.config.QualityRestartThreshold {
+		return false
+	}
+	app.componentLogger(componentTunnel).Warn("Connection quality below the restart threshold, restarting tunnel",
+		"quality_score", quality.QualityScore, "quality_restart_threshold", app.config.QualityRestartThreshold,
+		"avg_rtt", quality.AvgRTT, "jitter", quality.Jitter, "packet_loss", quality.PacketLoss)
+	return true
+}
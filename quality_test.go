@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestQualityTracker_Snapshot(t *testing.T) {
+	var q qualityTracker
+	if got := q.snapshot(); got.QualityScore != 100 || got.Samples != 0 {
+		t.Errorf("empty snapshot = %+v, want score 100 without samples", got)
+	}
+
+	q.observe(10*time.Millisecond, true)
+	q.observe(0, false)
+	q.observe(30*time.Millisecond, true)
+
+	got := q.snapshot()
+	// 100 - (10ms/20ms)*50 - (1/3)*50
+	want := ConnectionQuality{
+		LastRTT:      0.030,
+		AvgRTT:       0.020,
+		Jitter:       0.010,
+		PacketLoss:   1.0 / 3,
+		QualityScore: 100 - 25 - 50.0/3,
+		Samples:      3,
+	}
+	for name, pair := range map[string][2]float64{
+		"LastRTT":      {got.LastRTT, want.LastRTT},
+		"AvgRTT":       {got.AvgRTT, want.AvgRTT},
+		"Jitter":       {got.Jitter, want.Jitter},
+		"PacketLoss":   {got.PacketLoss, want.PacketLoss},
+		"QualityScore": {got.QualityScore, want.QualityScore},
+	} {
+		if math.Abs(pair[0]-pair[1]) > 1e-9 {
+			t.Errorf("%s = %g, want %g", name, pair[0], pair[1])
+		}
+	}
+	if got.Samples != want.Samples {
+		t.Errorf("Samples = %d, want %d", got.Samples, want.Samples)
+	}
+}
+
+func TestQualityTracker_Window(t *testing.T) {
+	var q qualityTracker
+	for range qualitySamples {
+		q.observe(0, false)
+	}
+	if got := q.snapshot(); got.QualityScore != 50 || got.PacketLoss != 1 {
+		t.Errorf("all failed: %+v, want score 50 and packet loss 1", got)
+	}
+
+	// Old failures leave the window
+	for range qualitySamples {
+		q.observe(10*time.Millisecond, true)
+	}
+	if got := q.snapshot(); math.Abs(got.QualityScore-100) > 1e-9 || got.Samples != qualitySamples {
+		t.Errorf("steady round trips: %+v, want score 100 over %d samples", got, qualitySamples)
+	}
+
+	q.reset()
+	if got := q.snapshot(); got.Samples != 0 {
+		t.Errorf("after reset: %d samples, want 0", got.Samples)
+	}
+}
+
+func TestQualityDegraded(t *testing.T) {
+	app := newTestApp(t)
+	app.config.QualityRestartThreshold = 60
+
+	// Every other check fails, for a score of 75
+	app.quality.observe(0, false)
+	app.quality.observe(10*time.Millisecond, true)
+	app.quality.observe(0, false)
+	app.quality.observe(10*time.Millisecond, true)
+	if app.qualityDegraded() {
+		t.Error("too few samples to restart")
+	}
+
+	app.quality.observe(0, false)
+	app.quality.observe(10*time.Millisecond, true)
+	if app.qualityDegraded() {
+		t.Errorf("score %g is above the threshold", app.quality.snapshot().QualityScore)
+	}
+
+	// An outlier round trip adds jitter
+	app.quality.observe(0, false)
+	app.quality.observe(70*time.Millisecond, true)
+	if !app.qualityDegraded() {
+		t.Errorf("score %g should be below the threshold", app.quality.snapshot().QualityScore)
+	}
+
+	app.config.QualityRestartThreshold = 0
+	if app.qualityDegraded() {
+		t.Error("threshold 0 disables quality restarts")
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tunnel modes, also passed to hooks as TUNNEL_MODE.
+const (
+	tunnelModeDynamic = "dynamic" // SOCKS proxy (-D)
+	tunnelModeRemote  = "remote"  // port on the SSH server forwarded to a local service (-R)
+	tunnelModeLocal   = "local"   // local ports forwarded to services behind the SSH server (-L)
+)
+
+// validateTunnelMode normalizes TunnelMode and checks the settings of remote forwarding.
+// It runs before deriveProxyHost, which depends on the mode and parses local forwards.
+func (c *config) validateTunnelMode() error {
+	switch strings.ToLower(c.TunnelMode) {
+	case "", tunnelModeDynamic:
+		c.TunnelMode = tunnelModeDynamic
+		return nil
+	case tunnelModeRemote:
+		c.TunnelMode = tunnelModeRemote
+		if len(c.SSHRemoteForwards) > 0 {
+			if c.SSHRemoteForwardRemoteAddr != "" || c.SSHRemoteForwardLocalAddr != "" {
+				return fmt.Errorf("remote forwards cannot be combined with the remote forward remote and local addresses")
+			}
+			break
+		}
+		if _, err := splitForwardAddr(c.SSHRemoteForwardRemoteAddr, true); err != nil {
+			return fmt.Errorf("invalid remote forward remote address: %w", err)
+		}
+		if _, err := splitForwardAddr(c.SSHRemoteForwardLocalAddr, false); err != nil {
+			return fmt.Errorf("invalid remote forward local address: %w", err)
+		}
+	case tunnelModeLocal:
+		c.TunnelMode = tunnelModeLocal
+	default:
+		return fmt.Errorf("invalid tunnel mode: %s", c.TunnelMode)
+	}
+
+	// Both move the local proxy port, which only the dynamic mode has
+	if c.SSHAutoSelectPort || c.OverlapRestart {
+		return fmt.Errorf("auto-selected ports and overlap restarts require the dynamic tunnel mode")
+	}
+	return nil
+}
+
+// splitForwardAddr checks a host:port forwarding address and returns it normalized.
+// An empty host is only allowed for the remote side, where ssh then binds per the
+// server's GatewayPorts setting.
+func splitForwardAddr(addr string, emptyHost bool) (string, error) {
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr // a bare port
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if portNum, err := strconv.Atoi(port); err != nil || portNum <= 0 || portNum > 65535 {
+		return "", fmt.Errorf("invalid port: %q", port)
+	}
+	if host == "" && !emptyHost {
+		return "", fmt.Errorf("missing host in %q", addr)
+	}
+	if host == "" {
+		return port, nil
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// remoteForwardSpec returns the -R argument, "[bind_address:]port:host:hostport".
+func (c *config) remoteForwardSpec() string {
+	remote, _ := splitForwardAddr(c.SSHRemoteForwardRemoteAddr, true)
+	local, _ := splitForwardAddr(c.SSHRemoteForwardLocalAddr, false)
+	return remote + ":" + local
+}
+
+// parseRemoteForward checks an OpenSSH -R rule, "[bind_address:]port:host:hostport"
+// or "[bind_address:]port:local_socket", where the remote port may also be a socket
+// path on the server. It returns the rule and the port or socket the server listens on.
+func parseRemoteForward(rule string) (forwardRule, string, error) {
+	fields := splitForwardFields(rule)
+	forward := forwardRule{spec: rule}
+	if last := fields[len(fields)-1]; strings.HasPrefix(last, "/") {
+		// A local socket is only reachable by path; its forward isn't health checked
+		fields = fields[:len(fields)-1]
+	} else {
+		if len(fields) < 3 {
+			return forwardRule{}, "", fmt.Errorf("invalid remote forward %q: want [bind_address:]port:host:hostport", rule)
+		}
+		host, hostPort := strings.Trim(fields[len(fields)-2], "[]"), last
+		if host == "" {
+			return forwardRule{}, "", fmt.Errorf("invalid remote forward %q: missing host", rule)
+		}
+		if !validForwardPort(hostPort) {
+			return forwardRule{}, "", fmt.Errorf("invalid remote forward %q: invalid port %q", rule, hostPort)
+		}
+		forward.check = net.JoinHostPort(host, hostPort)
+		fields = fields[:len(fields)-2]
+	}
+
+	switch {
+	case len(fields) == 1 && strings.HasPrefix(fields[0], "/"):
+	case len(fields) == 1 || len(fields) == 2:
+		if !validForwardPort(fields[len(fields)-1]) {
+			return forwardRule{}, "", fmt.Errorf("invalid remote forward %q: invalid remote port %q", rule, fields[len(fields)-1])
+		}
+	default:
+		return forwardRule{}, "", fmt.Errorf("invalid remote forward %q: want [bind_address:]port:host:hostport", rule)
+	}
+	return forward, fields[len(fields)-1], nil
+}
+
+// parseRemoteForwards returns the -R rules: SSHRemoteForwards, or the single rule
+// of the remote forward addresses. Every remote port may appear once, and at least
+// one rule must lead to a local port, which identifies the instance.
+func (c *config) parseRemoteForwards() ([]forwardRule, error) {
+	if len(c.SSHRemoteForwards) == 0 {
+		local, err := splitForwardAddr(c.SSHRemoteForwardLocalAddr, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote forward local address: %w", err)
+		}
+		return []forwardRule{{spec: c.remoteForwardSpec(), check: local}}, nil
+	}
+
+	forwards := make([]forwardRule, 0, len(c.SSHRemoteForwards))
+	listeners := make(map[string]bool, len(c.SSHRemoteForwards))
+	checked := false
+	for _, rule := range c.SSHRemoteForwards {
+		forward, listener, err := parseRemoteForward(strings.TrimSpace(rule))
+		if err != nil {
+			return nil, err
+		}
+		if listeners[listener] {
+			return nil, fmt.Errorf("duplicate remote forward port: %s", listener)
+		}
+		listeners[listener] = true
+		checked = checked || forward.check != ""
+		forwards = append(forwards, forward)
+	}
+	if !checked {
+		return nil, fmt.Errorf("remote forwards need at least one rule with a local host and port")
+	}
+	return forwards, nil
+}
+
+// deriveForwardTarget makes the local services of remote forwarding the addresses
+// health checks connect to. The first one's port identifies the instance.
+func (c *config) deriveForwardTarget() error {
+	forwards, err := c.parseRemoteForwards()
+	if err != nil {
+		return err
+	}
+
+	c.proxyHosts = nil
+	for _, forward := range forwards {
+		if forward.check != "" {
+			c.proxyHosts = append(c.proxyHosts, forward.check)
+		}
+	}
+	c.proxyHost = c.proxyHosts[0]
+	_, c.proxyPort, _ = net.SplitHostPort(c.proxyHost)
+	c.forwards = forwards
+	c.Tunnels = c.flatTunnels()
+	return nil
+}
+
+// remoteForwardCheckTimeout bounds a request to SSHRemoteForwardCheckURL.
+const remoteForwardCheckTimeout = 10 * time.Second
+
+// checkRemoteForwardURL requests SSHRemoteForwardCheckURL directly, without a proxy.
+// The URL points to the server side of a remote forward, so a response shows the
+// whole path back through the tunnel to the local service works.
+func (app *Application) checkRemoteForwardURL(ctx context.Context, logger *slog.Logger) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, app.config.SSHRemoteForwardCheckURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Close = true
+	req.Header.Set("User-Agent", "ssh-tunnel/"+buildInfo.Version)
+
+	client := &http.Client{
+		Timeout:   remoteForwardCheckTimeout,
+		Transport: &http.Transport{TLSClientConfig: app.config.tlsVersionConfig()},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	app.metrics.since(metricTrafficCheck, start)
+	if err != nil {
+		return fmt.Errorf("remote forward check failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error("Failed to close response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code from remote forward check: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// remoteForwardConfig returns a valid config in remote forwarding mode.
+func remoteForwardConfig() config {
+	cfg := validConfig()
+	cfg.TunnelMode = "Remote"
+	cfg.SSHRemoteForwardRemoteAddr = "0.0.0.0:9000"
+	cfg.SSHRemoteForwardLocalAddr = "127.0.0.1:3000"
+	return cfg
+}
+
+func TestValidate_TunnelMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		ok     bool
+	}{
+		{"remote", func(*config) {}, true},
+		{"remote port only", func(c *config) { c.SSHRemoteForwardRemoteAddr = "9000" }, true},
+		{"IPv6 local service", func(c *config) { c.SSHRemoteForwardLocalAddr = "[::1]:3000" }, true},
+		{"dynamic", func(c *config) { c.TunnelMode = "dynamic" }, true},
+		{"unknown mode", func(c *config) { c.TunnelMode = "tun" }, false},
+		{"missing remote address", func(c *config) { c.SSHRemoteForwardRemoteAddr = "" }, false},
+		{"invalid remote port", func(c *config) { c.SSHRemoteForwardRemoteAddr = "0.0.0.0:99999" }, false},
+		{"local port only", func(c *config) { c.SSHRemoteForwardLocalAddr = "3000" }, false},
+		{"missing local address", func(c *config) { c.SSHRemoteForwardLocalAddr = "" }, false},
+		{"with auto-selected port", func(c *config) { c.SSHAutoSelectPort = true }, false},
+		{"with overlap restart", func(c *config) { c.OverlapRestart = true }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := remoteForwardConfig()
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidate_TunnelModeDefault(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if cfg.TunnelMode != tunnelModeDynamic {
+		t.Errorf("TunnelMode = %q, want %q", cfg.TunnelMode, tunnelModeDynamic)
+	}
+}
+
+func TestRemoteForward_ProxyHost(t *testing.T) {
+	cfg := remoteForwardConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if cfg.TunnelMode != tunnelModeRemote {
+		t.Errorf("TunnelMode = %q, want it normalized to %q", cfg.TunnelMode, tunnelModeRemote)
+	}
+	if cfg.proxyHost != "127.0.0.1:3000" || !slices.Equal(cfg.proxyHosts, []string{"127.0.0.1:3000"}) {
+		t.Errorf("proxy hosts = %q %v, want the local service", cfg.proxyHost, cfg.proxyHosts)
+	}
+	if got := cfg.getPortSpecificPIDFile(); got != "ssh-tunnel-3000.pid" {
+		t.Errorf("PID file = %q, want it suffixed with the local service port", got)
+	}
+}
+
+func TestSerializeSSHOptions_RemoteForward(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		local   string
+		gateway bool
+		want    string
+	}{
+		{"bind address", "0.0.0.0:9000", "127.0.0.1:3000", false, "-R 0.0.0.0:9000:127.0.0.1:3000"},
+		{"port only", "9000", "localhost:3000", false, "-R 9000:localhost:3000"},
+		{"IPv6", "[::]:9000", "[::1]:3000", true, "-R [::]:9000:[::1]:3000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := remoteForwardConfig()
+			cfg.SSHRemoteForwardRemoteAddr = tt.remote
+			cfg.SSHRemoteForwardLocalAddr = tt.local
+			cfg.SSHGatewayPorts = tt.gateway
+			if err := cfg.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			joined := strings.Join(cfg.serializeSSHOptions(), " ")
+			if !strings.Contains(joined, tt.want) {
+				t.Errorf("missing %q: %s", tt.want, joined)
+			}
+			if !strings.Contains(joined, "-o ExitOnForwardFailure=yes") {
+				t.Errorf("missing ExitOnForwardFailure=yes: %s", joined)
+			}
+			if strings.Contains(joined, "-D ") {
+				t.Errorf("unexpected dynamic forwarding: %s", joined)
+			}
+			if strings.Contains(joined, "GatewayPorts=yes") != tt.gateway {
+				t.Errorf("GatewayPorts=yes present = %v, want %v: %s", !tt.gateway, tt.gateway, joined)
+			}
+		})
+	}
+}
+
+func TestCheckTraffic_RemoteForward(t *testing.T) {
+	app := newTestApp(t)
+	app.config.TunnelMode = tunnelModeRemote
+	// No HTTP transport: remote mode must not send traffic through a proxy
+	listener := useProxyListener(t, app)
+
+	if err := app.checkTraffic(); err != nil {
+		t.Errorf("checkTraffic() = %v with the local service up, want nil", err)
+	}
+
+	_ = listener.Close()
+	if err := app.checkTraffic(); err == nil {
+		t.Error("checkTraffic() should fail with the local service down")
+	}
+}
+
+func TestParseRemoteForward(t *testing.T) {
+	tests := []struct {
+		rule         string
+		wantCheck    string
+		wantListener string
+		ok           bool
+	}{
+		{"9000:localhost:3000", "localhost:3000", "9000", true},
+		{"0.0.0.0:9000:127.0.0.1:3000", "127.0.0.1:3000", "9000", true},
+		{"[::]:9000:[::1]:3000", "[::1]:3000", "9000", true},
+		{"/run/app.sock:127.0.0.1:3000", "127.0.0.1:3000", "/run/app.sock", true},
+		{"9000:/run/local.sock", "", "9000", true},
+		{"9000", "", "", false},
+		{"9000:localhost", "", "", false},
+		{"9000::3000", "", "", false},
+		{"99999:localhost:3000", "", "", false},
+		{"a:b:9000:localhost:3000", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			forward, listener, err := parseRemoteForward(tt.rule)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if forward.check != tt.wantCheck || listener != tt.wantListener {
+				t.Errorf("check, listener = %q, %q, want %q, %q", forward.check, listener, tt.wantCheck, tt.wantListener)
+			}
+		})
+	}
+}
+
+// remoteForwardsConfig returns a valid config with several remote forwarding rules.
+func remoteForwardsConfig() config {
+	cfg := validConfig()
+	cfg.TunnelMode = tunnelModeRemote
+	cfg.SSHRemoteForwards = []string{"9000:127.0.0.1:3000", "0.0.0.0:9001:127.0.0.1:3001", "9002:/run/app.sock"}
+	return cfg
+}
+
+func TestValidate_RemoteForwards(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		ok     bool
+	}{
+		{"valid", func(*config) {}, true},
+		{"with addresses", func(c *config) { c.SSHRemoteForwardLocalAddr = "127.0.0.1:3000" }, false},
+		{"duplicate remote port", func(c *config) { c.SSHRemoteForwards = []string{"9000:a:1", "127.0.0.1:9000:b:2"} }, false},
+		{"sockets only", func(c *config) { c.SSHRemoteForwards = []string{"9000:/run/app.sock"} }, false},
+		{"invalid rule", func(c *config) { c.SSHRemoteForwards = []string{"9000"} }, false},
+		{"check URL", func(c *config) { c.SSHRemoteForwardCheckURL = "http://127.0.0.1:9000/health" }, true},
+		{"invalid check URL", func(c *config) { c.SSHRemoteForwardCheckURL = "ftp://host/health" }, false},
+		{"check URL in dynamic mode", func(c *config) {
+			c.TunnelMode = tunnelModeDynamic
+			c.SSHRemoteForwardCheckURL = "http://127.0.0.1:9000/health"
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := remoteForwardsConfig()
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSerializeSSHOptions_RemoteForwards(t *testing.T) {
+	cfg := remoteForwardsConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if want := []string{"127.0.0.1:3000", "127.0.0.1:3001"}; !slices.Equal(cfg.proxyHosts, want) {
+		t.Errorf("proxyHosts = %v, want %v", cfg.proxyHosts, want)
+	}
+	joined := strings.Join(cfg.serializeSSHOptions(), " ")
+	for _, want := range []string{"-R 9000:127.0.0.1:3000", "-R 0.0.0.0:9001:127.0.0.1:3001", "-R 9002:/run/app.sock", "-o ExitOnForwardFailure=yes"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q: %s", want, joined)
+		}
+	}
+
+	cfg.SSHExitOnForwardFailure = false
+	if joined := strings.Join(cfg.serializeSSHOptions(), " "); strings.Contains(joined, "ExitOnForwardFailure") {
+		t.Errorf("unexpected ExitOnForwardFailure: %s", joined)
+	}
+}
+
+func TestCheckTraffic_RemoteForwardCheckURL(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	app := newTestApp(t)
+	app.config.TunnelMode = tunnelModeRemote
+	app.config.SSHRemoteForwardCheckURL = server.URL + "/health"
+	useProxyListener(t, app)
+
+	if err := app.checkTraffic(); err != nil {
+		t.Errorf("checkTraffic() = %v with the URL answering, want nil", err)
+	}
+
+	status = http.StatusBadGateway
+	if err := app.checkTraffic(); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("checkTraffic() = %v, want the status code error", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// knownSSHPorts are ports SSH servers commonly listen on.
+var knownSSHPorts = []int{22, 2200, 2222}
+
+// unusualRemotePort reports whether port is a privileged port other than a known SSH port,
+// which more likely belongs to another service than to an SSH server.
+func unusualRemotePort(port int) bool {
+	return port < 1024 && !slices.Contains(knownSSHPorts, port)
+}
+
+// validateRemotePortPolicy checks SSHAllowedRemotePorts; with SSHRemotePortStrict they
+// must hold the port of every server ssh may connect to.
+func (c *config) validateRemotePortPolicy() error {
+	for _, port := range c.SSHAllowedRemotePorts {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid allowed remote port: %d", port)
+		}
+	}
+	if !c.SSHRemotePortStrict {
+		return nil
+	}
+
+	if len(c.SSHAllowedRemotePorts) == 0 {
+		return fmt.Errorf("REMOTE_PORT_STRICT requires ALLOWED_REMOTE_PORTS")
+	}
+	if !slices.Contains(c.SSHAllowedRemotePorts, c.SSHRemotePort) {
+		return fmt.Errorf("remote port %d is not in the allowed remote ports %v", c.SSHRemotePort, c.SSHAllowedRemotePorts)
+	}
+	if c.FallbackRemoteAddress != "" && !slices.Contains(c.SSHAllowedRemotePorts, c.fallbackRemotePort()) {
+		return fmt.Errorf("fallback remote port %d is not in the allowed remote ports %v", c.fallbackRemotePort(), c.SSHAllowedRemotePorts)
+	}
+	return nil
+}
+
+// warnUnusualRemotePorts logs the configured server ports that look like a mistake.
+func (app *Application) warnUnusualRemotePorts(logger *slog.Logger) {
+	if port := app.config.SSHRemotePort; unusualRemotePort(port) {
+		logger.Warn("Remote port is a privileged port not usually used by SSH, check REMOTE_PORT",
+			"remote_port", port, "usual_ports", knownSSHPorts)
+	}
+	// An unset fallback port is the remote port, which has been checked already
+	if port := app.config.FallbackRemotePort; app.config.FallbackRemoteAddress != "" && port != 0 && unusualRemotePort(port) {
+		logger.Warn("Fallback remote port is a privileged port not usually used by SSH, check FALLBACK_REMOTE_PORT",
+			"fallback_remote_port", port, "usual_ports", knownSSHPorts)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarnUnusualRemotePorts(t *testing.T) {
+	tests := []struct {
+		name         string
+		port         int
+		fallbackPort int
+		wantWarnings int
+	}{
+		{"default port", 2212, 0, 0},
+		{"standard SSH port", 22, 0, 0},
+		{"alternative SSH port", 2222, 0, 0},
+		{"privileged port", 443, 0, 1},
+		{"privileged fallback port", 22, 80, 1},
+		{"privileged port, fallback on the same port", 443, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.config.SSHRemotePort = tt.port
+			app.config.FallbackRemoteAddress = "user@backup.example.com"
+			app.config.FallbackRemotePort = tt.fallbackPort
+
+			var buf bytes.Buffer
+			app.warnUnusualRemotePorts(slog.New(slog.NewTextHandler(&buf, nil)))
+			if got := strings.Count(buf.String(), "level=WARN"); got != tt.wantWarnings {
+				t.Errorf("logged %d warnings, want %d:\n%s", got, tt.wantWarnings, buf.String())
+			}
+		})
+	}
+}
+
+func TestValidate_RemotePortStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		strict   bool
+		allowed  []int
+		fallback int
+		ok       bool
+	}{
+		{"not strict, port not listed", false, []int{22}, 0, true},
+		{"strict, port listed", true, []int{22, 2212}, 0, true},
+		{"strict, port not listed", true, []int{22}, 0, false},
+		{"strict, empty allowlist", true, nil, 0, false},
+		{"strict, fallback port not listed", true, []int{2212}, 2222, false},
+		{"strict, fallback port listed", true, []int{2212, 2222}, 2222, true},
+		{"invalid allowed port", false, []int{70000}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.SSHRemotePortStrict = tt.strict
+			cfg.SSHAllowedRemotePorts = tt.allowed
+			if tt.fallback != 0 {
+				cfg.FallbackRemoteAddress = "user@backup.example.com"
+				cfg.FallbackRemotePort = tt.fallback
+				cfg.PreflightCheckTimeout = time.Second
+			}
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
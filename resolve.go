@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+)
+
+// lookupHost resolves the SSH server name and is replaced in tests.
+var lookupHost = net.DefaultResolver.LookupHost
+
+// resolveRemote looks up the SSH server name again so changed DNS records take effect
+// on the next start, and returns the chosen address for ssh's HostName.
+func (c *config) resolveRemote(logger *slog.Logger) (string, error) {
+	host := c.remoteHost()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.TunnelStartTimeout)
+	defer cancel()
+
+	addrs, err := lookupHost(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("failed to resolve %s: no addresses", host)
+	}
+	addr, err := c.pickServerAddress(addrs)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	logger.Info("Resolved SSH server", "host", host, "addresses", addrs, "selected", addr)
+	return addr, nil
+}
+
+// pickAddress returns the first address of the preferred family, or the first address
+// if there is none.
+func pickAddress(addrs []string, preferIPv4 bool) string {
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip != nil && (ip.To4() != nil) == preferIPv4 {
+			return addr
+		}
+	}
+	return addrs[0]
+}
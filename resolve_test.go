@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// useLookupHost replaces DNS resolution of the SSH server for the duration of the test.
+func useLookupHost(t *testing.T, lookup func(ctx context.Context, host string) ([]string, error)) {
+	t.Helper()
+	original := lookupHost
+	lookupHost = lookup
+	t.Cleanup(func() { lookupHost = original })
+}
+
+func TestPickAddress(t *testing.T) {
+	addrs := []string{"2001:db8::1", "192.0.2.1", "192.0.2.2"}
+
+	tests := []struct {
+		name       string
+		addrs      []string
+		preferIPv4 bool
+		want       string
+	}{
+		{"prefer IPv4", addrs, true, "192.0.2.1"},
+		{"prefer IPv6", addrs, false, "2001:db8::1"},
+		{"no IPv4", []string{"2001:db8::1"}, true, "2001:db8::1"},
+		{"no IPv6", []string{"192.0.2.1"}, false, "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickAddress(tt.addrs, tt.preferIPv4); got != tt.want {
+				t.Errorf("pickAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRemote(t *testing.T) {
+	var looked string
+	useLookupHost(t, func(_ context.Context, host string) ([]string, error) {
+		looked = host
+		return []string{"2001:db8::1", "192.0.2.1"}, nil
+	})
+
+	app := newTestApp(t)
+	app.config.SSHRemoteAddress = "user@ssh.example.com"
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	addr, err := app.config.resolveRemote(slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("resolveRemote: %v", err)
+	}
+	app.config.resolvedHost = addr
+
+	if looked != "ssh.example.com" {
+		t.Errorf("looked up %q, want ssh.example.com", looked)
+	}
+	joined := strings.Join(app.config.serializeSSHOptions(), " ")
+	if !strings.Contains(joined, "-o HostName=192.0.2.1 -o HostKeyAlias=ssh.example.com") {
+		t.Errorf("missing HostName and HostKeyAlias: %s", joined)
+	}
+	if !strings.HasSuffix(joined, "user@ssh.example.com") {
+		t.Errorf("destination should keep the configured name: %s", joined)
+	}
+}
+
+func TestStartSSH_ResolveFailureSkipsStart(t *testing.T) {
+	useFakeSSH(t)
+	useLookupHost(t, func(context.Context, string) ([]string, error) {
+		return nil, errors.New("no such host")
+	})
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.SSHResolveOnRestart = true
+
+	if err := app.startSSH(app.logger); err == nil {
+		t.Fatal("expected error when the server name does not resolve")
+	}
+	if app.sshProcess != nil {
+		t.Error("no SSH process should be started")
+	}
+	if got := app.Stats().ConsecutiveFailures; got != 1 {
+		t.Errorf("ConsecutiveFailures = %d, want 1", got)
+	}
+}
+
+func TestApplyConfig_KeepsResolvedHost(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.SSHResolveOnRestart = true
+	app.config.resolvedHost = "192.0.2.1"
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		t.Fatalf("createHTTPTransport: %v", err)
+	}
+	app.httpTransport = transport
+	// Keep applyConfig from restarting the tunnel
+	app.paused.Store(true)
+
+	next := *app.config
+	next.resolvedHost = ""
+	next.MainLoopSleep *= 2
+	app.applyConfig(&next)
+	if app.config.resolvedHost != "192.0.2.1" {
+		t.Errorf("resolvedHost = %q, want it kept for the same server", app.config.resolvedHost)
+	}
+
+	next = *app.config
+	next.SSHRemoteAddress = "user@other.example.com"
+	app.applyConfig(&next)
+	if app.config.resolvedHost != "" {
+		t.Errorf("resolvedHost = %q, want it cleared for a new server", app.config.resolvedHost)
+	}
+}
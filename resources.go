@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// errResourcesUnsupported is returned on platforms without a way to read process usage.
+var errResourcesUnsupported = errors.New("resource monitoring is not supported on this platform")
+
+// resourcePollInterval is how often SSH process CPU and memory usage are sampled.
+const resourcePollInterval = 30 * time.Second
+
+// resourceSample is the CPU time of a process at a point in time.
+// The CPU percentage is derived from the difference between two samples.
+type resourceSample struct {
+	pid     int
+	cpuTime time.Duration
+	at      time.Time
+}
+
+// runResourceMonitor samples the SSH process CPU and memory usage until shutdown.
+func (app *Application) runResourceMonitor() {
+	ticker := time.NewTicker(resourcePollInterval)
+	defer ticker.Stop()
+
+	var last resourceSample
+	for {
+		select {
+		case <-app.shutdownChan:
+			return
+		case <-ticker.C:
+			if err := app.sampleResources(&last); err != nil {
+				app.componentLogger(componentSSH).Warn("Resource monitoring stopped", "error", err)
+				return
+			}
+		}
+	}
+}
+
+// sampleResources updates the SSH process CPU and memory usage and replaces last with the new sample.
+// The CPU percentage stays 0 until the same process has been sampled twice.
+// Only unsupported platforms return an error; a missing process just resets the values.
+func (app *Application) sampleResources(last *resourceSample) error {
+	app.sshMutex.RLock()
+	pid := 0
+	if app.isProcessRunning(app.sshProcess) {
+		pid = app.sshProcess.Process.Pid
+	}
+	app.sshMutex.RUnlock()
+
+	if pid == 0 {
+		*last = resourceSample{}
+		storeFloat(&app.sshCPUPercent, 0)
+		storeFloat(&app.sshMemoryMB, 0)
+		return nil
+	}
+
+	cpuTime, rss, err := processUsage(pid)
+	if errors.Is(err, errResourcesUnsupported) {
+		return err
+	}
+	if err != nil {
+		app.componentLogger(componentSSH).Debug("Failed to read SSH process usage", "pid", pid, "error", err)
+		return nil
+	}
+
+	now := time.Now()
+	cpuPercent := 0.0
+	if last.pid == pid && now.After(last.at) {
+		cpuPercent = float64(cpuTime-last.cpuTime) / float64(now.Sub(last.at)) * 100
+	}
+	*last = resourceSample{pid: pid, cpuTime: cpuTime, at: now}
+
+	storeFloat(&app.sshCPUPercent, cpuPercent)
+	storeFloat(&app.sshMemoryMB, float64(rss)/(1<<20))
+	return nil
+}
+
+// storeFloat stores v in an atomic.Uint64 holding a float64.
+func storeFloat(bits *atomic.Uint64, v float64) {
+	bits.Store(math.Float64bits(v))
+}
+
+// loadFloat loads a float64 stored with storeFloat.
+func loadFloat(bits *atomic.Uint64) float64 {
+	return math.Float64frombits(bits.Load())
+}
@@ -0,0 +1,49 @@
+//go:build darwin
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// processUsage returns the CPU time and resident memory in bytes of a process.
+// macOS has no /proc, so ps reports them.
+func processUsage(pid int) (time.Duration, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "ps", "-o", "time=,rss=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	return parsePSUsage(string(out))
+}
+
+// parsePSUsage parses "ps -o time=,rss=" output such as "  1:02.35   5824",
+// where time is [hh:]mm:ss.ss of CPU time and rss is in KiB.
+func parsePSUsage(out string) (time.Duration, int64, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected ps output %q", out)
+	}
+
+	var seconds float64
+	for _, part := range strings.Split(fields[0], ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid CPU time %q", fields[0])
+		}
+		seconds = seconds*60 + n
+	}
+
+	rss, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid RSS %q", fields[1])
+	}
+	return time.Duration(seconds * float64(time.Second)), rss * 1024, nil
+}
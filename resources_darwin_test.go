@@ -0,0 +1,34 @@
+//go:build darwin
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePSUsage(t *testing.T) {
+	tests := []struct {
+		out     string
+		cpuTime time.Duration
+		rss     int64
+		ok      bool
+	}{
+		{"  0:01.50   5824\n", 1500 * time.Millisecond, 5824 * 1024, true},
+		{"1:02:03.00 100\n", time.Hour + 2*time.Minute + 3*time.Second, 100 * 1024, true},
+		{"", 0, 0, false},
+		{"0:01.50 x\n", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.out, func(t *testing.T) {
+			cpuTime, rss, err := parsePSUsage(tt.out)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err=%v, want ok=%v", err, tt.ok)
+			}
+			if tt.ok && (cpuTime != tt.cpuTime || rss != tt.rss) {
+				t.Errorf("got %s, %d; want %s, %d", cpuTime, rss, tt.cpuTime, tt.rss)
+			}
+		})
+	}
+}
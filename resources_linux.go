@@ -0,0 +1,59 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of the CPU times in /proc/<pid>/stat.
+// It is 100 on every Linux architecture; reading it via sysconf would need cgo.
+const clockTicksPerSecond = 100
+
+// processUsage returns the CPU time and resident memory in bytes of a process.
+func processUsage(pid int) (time.Duration, int64, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	ticks, pages, err := parseProcStat(content)
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(ticks) * time.Second / clockTicksPerSecond, pages * int64(os.Getpagesize()), nil
+}
+
+// parseProcStat extracts utime+stime (clock ticks) and rss (pages) from /proc/<pid>/stat content.
+func parseProcStat(content []byte) (int64, int64, error) {
+	// The command name in field 2 may contain spaces and parentheses, so fields
+	// are counted from the last ')'; state (field 3) is the first one after it.
+	end := bytes.LastIndexByte(content, ')')
+	if end < 0 {
+		return 0, 0, errors.New("command name not found")
+	}
+	fields := bytes.Fields(content[end+1:])
+
+	const (
+		utimeField = 14 - 3
+		stimeField = 15 - 3
+		rssField   = 24 - 3
+	)
+	if len(fields) <= rssField {
+		return 0, 0, fmt.Errorf("got %d fields, want at least %d", len(fields)+2, rssField+3)
+	}
+
+	var values [3]int64
+	for i, field := range []int{utimeField, stimeField, rssField} {
+		n, err := strconv.ParseInt(string(fields[field]), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid field %d: %w", field+3, err)
+		}
+		values[i] = n
+	}
+	return values[0] + values[1], values[2], nil
+}
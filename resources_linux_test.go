@@ -0,0 +1,112 @@
+//go:build linux
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	// The command name contains a space and a parenthesis
+	content := []byte("4242 (ssh (x) -D) S 1 4242 4242 0 -1 4194560 300 0 0 0 " +
+		"120 30 0 0 20 0 1 0 12345 10485760 1536 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n")
+
+	ticks, pages, err := parseProcStat(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ticks != 150 || pages != 1536 {
+		t.Errorf("got ticks=%d pages=%d, want ticks=150 pages=1536", ticks, pages)
+	}
+}
+
+func TestParseProcStat_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no command name", "4242 ssh S 1"},
+		{"too few fields", "4242 (ssh) S 1 4242"},
+		{"non-numeric", "4242 (ssh) S 1 4242 4242 0 -1 0 0 0 0 0 x 30 0 0 20 0 1 0 1 1 1536\n"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseProcStat([]byte(tt.content)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestProcessUsage_Self(t *testing.T) {
+	_, rss, err := processUsage(os.Getpid())
+	if err != nil {
+		t.Fatalf("processUsage: %v", err)
+	}
+	if rss < 1<<20 {
+		t.Errorf("rss = %d, want at least 1 MiB for the test binary", rss)
+	}
+}
+
+func TestSampleResources_RunningProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start process: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	// A freshly started process has no resident pages yet
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, rss, err := processUsage(cmd.Process.Pid); err == nil && rss > 0 {
+			break
+		}
+	}
+
+	app := &Application{logger: slog.New(slog.DiscardHandler), sshProcess: cmd}
+	var last resourceSample
+	if err := app.sampleResources(&last); err != nil {
+		t.Fatalf("sampleResources: %v", err)
+	}
+	if last.pid != cmd.Process.Pid {
+		t.Errorf("sample pid = %d, want %d", last.pid, cmd.Process.Pid)
+	}
+	stats := app.Stats()
+	if stats.MemoryMB <= 0 || stats.CPUPercent != 0 {
+		t.Errorf("first sample: memory_mb=%v cpu_percent=%v, want memory > 0 and no CPU yet", stats.MemoryMB, stats.CPUPercent)
+	}
+
+	// Pretend the previous sample was taken a second ago with a second of CPU time less
+	last.cpuTime -= time.Second
+	last.at = last.at.Add(-time.Second)
+	if err := app.sampleResources(&last); err != nil {
+		t.Fatalf("sampleResources: %v", err)
+	}
+	if cpu := app.Stats().CPUPercent; cpu < 50 || cpu > 100 {
+		t.Errorf("cpu_percent = %v, want about 100", cpu)
+	}
+}
+
+func TestSampleResources_NoProcessResets(t *testing.T) {
+	app := &Application{logger: slog.New(slog.DiscardHandler)}
+	storeFloat(&app.sshCPUPercent, 12.5)
+	storeFloat(&app.sshMemoryMB, 8)
+	last := resourceSample{pid: 4242}
+
+	if err := app.sampleResources(&last); err != nil {
+		t.Fatalf("sampleResources: %v", err)
+	}
+	if stats := app.Stats(); stats.CPUPercent != 0 || stats.MemoryMB != 0 {
+		t.Errorf("usage not reset: %+v", stats)
+	}
+	if last.pid != 0 {
+		t.Errorf("last sample not reset: %+v", last)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "time"
+
+// processUsage is unavailable outside Linux and macOS.
+func processUsage(pid int) (time.Duration, int64, error) {
+	return 0, 0, errResourcesUnsupported
+}
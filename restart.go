@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+)
+
+// restartStrategy replaces the running SSH process with a fresh one.
+// Only called from the main loop.
+// Once ctx is done it stops waiting for the new process and returns an error.
+type restartStrategy func(ctx context.Context, app *Application, logger *slog.Logger) error
+
+// newRestartStrategy returns the restart strategy selected by the config.
+func newRestartStrategy(cfg *config) restartStrategy {
+	if cfg.OverlapRestart {
+		return overlapRestart
+	}
+	return stopStartRestart
+}
+
+// stopStartRestart stops the current process, waits a random part of ReconnectJitter
+// and starts a new one. The proxy is unavailable in between.
+func stopStartRestart(ctx context.Context, app *Application, logger *slog.Logger) error {
+	app.stopSSH(logger)
+
+	// Spread reconnects of many clients dropped by the same server restart
+	delay := randomDuration(app.config.ReconnectJitter)
+	logger.Debug("Waiting before reconnect", "delay", delay)
+	if !app.sleepOrShutdown(delay) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return app.startSSHContext(ctx, logger)
+}
+
+// overlapRestart starts a new process on a free port next to the running one,
+// switches the proxy over once it is ready, and only then stops the old process.
+// The proxy address moves to the new port; /api/v1/status reports the current one.
+//
+// sshProcess and the proxy address are swapped together after the new process is ready,
+// so readers never see the new process with the old address or vice versa. Until then the
+// new process is private to this function and is never treated as the current one.
+func overlapRestart(ctx context.Context, app *Application, logger *slog.Logger) error {
+	app.sshMutex.RLock()
+	old := app.sshProcess
+	running := app.isProcessRunning(old)
+	app.sshMutex.RUnlock()
+
+	// Nothing to overlap with
+	if !running {
+		return stopStartRestart(ctx, app, logger)
+	}
+
+	// The chosen server becomes current with the new process
+	target, fingerprint, err := app.prepareSSHStart(logger)
+	if err != nil {
+		return err
+	}
+	if app.config.HostKeyScan {
+		app.sshMutex.Lock()
+		err := app.checkHostKey(logger, target, fingerprint)
+		app.sshMutex.Unlock()
+		if err != nil {
+			app.recordCheckStats(false)
+			return err
+		}
+	}
+	next := *target
+	bindHost, err := freeBindHost(next.SSHBindHost)
+	if err != nil {
+		return err
+	}
+	next.activeBindHost = bindHost
+	if err := next.deriveProxyHost(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, app.config.TunnelStartTimeout)
+	defer cancel()
+
+	logger.Info("Starting overlapping SSH process", "bind", bindHost)
+	cmd := next.sshCommand(next.serializeSSHOptions())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = app.sshStderr(logger)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start SSH: %w", err)
+	}
+	app.watchSSHProcess(cmd, logger)
+
+	if err := app.waitForProxies(ctx, logger, next.proxyHosts); err != nil {
+		logger.Error("Overlapping SSH process did not become ready, keeping the current one", "pid", cmd.Process.Pid, "error", err)
+		app.terminateSSH(cmd, logger)
+		return err
+	}
+
+	// Switch over: the new process becomes current together with its address
+	app.sshMutex.Lock()
+	app.configMutex.Lock()
+	*app.config = next
+	app.sshProcess = cmd
+	app.configMutex.Unlock()
+	app.sshMutex.Unlock()
+	app.audit(auditTunnelStart, "ssh_pid", cmd.Process.Pid, "remote", next.remoteAddress(), "bind", bindHost)
+
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		logger.Error("Failed to recreate HTTP transport", "error", err)
+	} else {
+		app.httpTransport.CloseIdleConnections()
+		app.httpTransport = transport
+	}
+	app.setState(StateRunning)
+	logger.Info("Switched proxy to new SSH process", "pid", cmd.Process.Pid, "proxy_host", next.proxyHost)
+
+	logger.Info("Stopping previous SSH process", "pid", old.Process.Pid)
+	app.audit(auditTunnelStop, "ssh_pid", old.Process.Pid)
+	app.terminateSSH(old, logger)
+
+	app.publishEnv(logger, cmd.Process.Pid)
+	app.runHook(logger, "on_start", app.config.OnStartCommand)
+	return nil
+}
+
+// freeBindHost returns bindHost with its port replaced by a currently unused one.
+func freeBindHost(bindHost string) (string, error) {
+	host, _, err := net.SplitHostPort(bindHost)
+	if err != nil {
+		return "", fmt.Errorf("invalid bind host: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free port: %w", err)
+	}
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if closeErr := listener.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free port: %w", err)
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeSSHEnv makes TestFakeSSHProcess act as an ssh client that only opens its -D listeners.
+const fakeSSHEnv = "SSH_TUNNEL_TEST_FAKE_SSH"
+
+// fakeSSHMasterEnv makes the fake ssh answer control commands as if a master were running.
+const fakeSSHMasterEnv = "SSH_TUNNEL_TEST_FAKE_SSH_MASTER"
+
+// useFakeSSH points sshBinary at a wrapper running this test binary as a fake ssh.
+func useFakeSSH(t *testing.T) {
+	t.Helper()
+	requireShell(t)
+
+	script := filepath.Join(t.TempDir(), "ssh")
+	content := "#!/bin/sh\nexec " + strconv.Quote(os.Args[0]) + " -test.run=TestFakeSSHProcess -- \"$@\"\n"
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil {
+		t.Fatalf("failed to write fake ssh: %v", err)
+	}
+	t.Setenv(fakeSSHEnv, "1")
+
+	originalSSHBinary := sshBinary
+	sshBinary = script
+	t.Cleanup(func() { sshBinary = originalSSHBinary })
+}
+
+// TestFakeSSHProcess runs as a child process standing in for ssh.
+func TestFakeSSHProcess(t *testing.T) {
+	if os.Getenv(fakeSSHEnv) == "" {
+		return
+	}
+
+	args := os.Args
+	for i, arg := range args {
+		// Control commands such as "-O check" go to a master; exit like ssh does without one
+		if arg == "-O" {
+			if os.Getenv(fakeSSHMasterEnv) != "" {
+				os.Exit(0)
+			}
+			os.Exit(255)
+		}
+		if arg == "-D" && i+1 < len(args) {
+			if _, err := net.Listen("tcp", args[i+1]); err != nil {
+				os.Exit(2)
+			}
+		}
+	}
+	time.Sleep(30 * time.Second)
+	os.Exit(0)
+}
+
+func TestNewRestartStrategy(t *testing.T) {
+	cfg := validConfig()
+	if got := reflect.ValueOf(newRestartStrategy(&cfg)).Pointer(); got != reflect.ValueOf(stopStartRestart).Pointer() {
+		t.Error("default strategy should be stop-then-start")
+	}
+
+	cfg.OverlapRestart = true
+	if got := reflect.ValueOf(newRestartStrategy(&cfg)).Pointer(); got != reflect.ValueOf(overlapRestart).Pointer() {
+		t.Error("OverlapRestart should select the overlap strategy")
+	}
+}
+
+func TestFreeBindHost(t *testing.T) {
+	got, err := freeBindHost("127.0.0.1:8080")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	host, port, err := net.SplitHostPort(got)
+	if err != nil {
+		t.Fatalf("invalid address %q: %v", got, err)
+	}
+	if host != "127.0.0.1" || port == "0" || port == "8080" {
+		t.Errorf("freeBindHost() = %q, want 127.0.0.1 with a free port", got)
+	}
+}
+
+func TestOverlapRestart(t *testing.T) {
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	app.config.OverlapRestart = true
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		t.Fatalf("createHTTPTransport: %v", err)
+	}
+	app.httpTransport = transport
+	instancePort := app.config.proxyPort
+
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	old := app.sshProcess
+	t.Cleanup(func() { app.stopSSH(app.logger) })
+
+	if err := overlapRestart(context.Background(), app, app.logger); err != nil {
+		t.Fatalf("overlapRestart: %v", err)
+	}
+
+	if app.sshProcess == old || !app.isProcessRunning(app.sshProcess) {
+		t.Error("new SSH process should be current")
+	}
+	if old.ProcessState == nil {
+		t.Error("previous SSH process should have been stopped")
+	}
+	if app.config.proxyHost == bindHost {
+		t.Errorf("proxyHost = %q, want the new process's port", app.config.proxyHost)
+	}
+	if app.config.proxyPort != instancePort {
+		t.Errorf("proxyPort = %q, want instance port %q unchanged", app.config.proxyPort, instancePort)
+	}
+	if err := app.checkPortContext(t.Context(), app.logger); err != nil {
+		t.Errorf("new proxy address should accept connections: %v", err)
+	}
+}
+
+func TestOverlapRestart_NotRunningFallsBack(t *testing.T) {
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	t.Cleanup(func() { app.stopSSH(app.logger) })
+
+	if err := overlapRestart(context.Background(), app, app.logger); err != nil {
+		t.Fatalf("overlapRestart: %v", err)
+	}
+	if app.config.proxyHost != bindHost {
+		t.Errorf("proxyHost = %q, want configured %q when there was nothing to overlap", app.config.proxyHost, bindHost)
+	}
+}
+
+func TestRestartTunnel_ClosesIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	app := newTestApp(t)
+	app.config.MaxRestartsPerHour = 0
+	countRestarts(app)
+
+	// Leave a kept-alive connection in the pool, as a check through the old process would
+	resp, err := (&http.Client{Transport: app.httpTransport}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	app.restartTunnel()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle connection was not closed after the restart")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudgetStats is the state of the retry budget in /api/v1/status.
+type RetryBudgetStats struct {
+	Tokens   float64 `json:"tokens"`
+	Capacity int     `json:"capacity"`
+}
+
+// retryBudget is a token bucket shared by the retries of all components, so that a
+// cascading failure can't multiply retry attempts. Every retry takes a token; the
+// bucket refills at refillRate tokens per interval, up to capacity. A zero capacity
+// turns the budget off. The zero value is off and it is safe for concurrent use.
+type retryBudget struct {
+	mu         sync.Mutex
+	capacity   int
+	refillRate float64       // tokens per interval
+	interval   time.Duration // the main loop sleep
+	tokens     float64
+	updated    time.Time // when tokens was last refilled
+}
+
+// configure applies new limits, keeping the current tokens within the new capacity.
+// A budget turned on starts out full.
+func (b *retryBudget) configure(capacity int, refillRate float64, interval time.Duration, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.capacity == 0 {
+		b.tokens = float64(capacity)
+	} else {
+		b.refill(now)
+		b.tokens = min(b.tokens, float64(capacity))
+	}
+	b.capacity, b.refillRate, b.interval, b.updated = capacity, refillRate, interval, now
+}
+
+// take consumes a token for a retry at now and reports whether the retry may go ahead.
+// A budget that is off always allows it.
+func (b *retryBudget) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.capacity == 0 {
+		return true
+	}
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// snapshot returns the tokens available at now.
+func (b *retryBudget) snapshot(now time.Time) RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.capacity == 0 {
+		return RetryBudgetStats{}
+	}
+	b.refill(now)
+	return RetryBudgetStats{Tokens: b.tokens, Capacity: b.capacity}
+}
+
+// refill adds the tokens earned since the last refill. Callers hold mu.
+func (b *retryBudget) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(b.tokens+b.refillRate*elapsed.Seconds()/b.interval.Seconds(), float64(b.capacity))
+		b.updated = now
+	}
+}
+
+// configureRetryBudget applies the retry budget settings of the config.
+func (app *Application) configureRetryBudget() {
+	app.retryBudget.configure(app.config.RetryBudgetCapacity, app.config.RetryBudgetRefillRate,
+		app.config.MainLoopSleep, time.Now())
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	var b retryBudget
+	start := time.Now()
+	if !b.take(start) {
+		t.Fatal("a budget that is off should allow every retry")
+	}
+
+	b.configure(2, 1, 10*time.Second, start)
+	if !b.take(start) || !b.take(start) {
+		t.Fatal("a new budget should start full")
+	}
+	if b.take(start) {
+		t.Fatal("an empty budget should refuse retries")
+	}
+
+	// One token per interval, never above capacity
+	if b.take(start.Add(5 * time.Second)) {
+		t.Error("half an interval should not refill a whole token")
+	}
+	if !b.take(start.Add(10 * time.Second)) {
+		t.Error("a full interval should refill a token")
+	}
+	if got := b.snapshot(start.Add(time.Hour)); got != (RetryBudgetStats{Tokens: 2, Capacity: 2}) {
+		t.Errorf("snapshot() = %+v, want a full budget", got)
+	}
+
+	// Lowering the capacity drops extra tokens
+	b.configure(1, 1, 10*time.Second, start.Add(time.Hour))
+	if got := b.snapshot(start.Add(time.Hour)).Tokens; got != 1 {
+		t.Errorf("tokens = %v after lowering the capacity, want 1", got)
+	}
+
+	b.configure(0, 1, 10*time.Second, start.Add(time.Hour))
+	if got := b.snapshot(start.Add(time.Hour)); got != (RetryBudgetStats{}) {
+		t.Errorf("snapshot() = %+v for a budget that is off, want zero", got)
+	}
+}
+
+func TestCheckTrafficWithRetry_BudgetExhausted(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.TrafficCheckRetries = 3
+	app.config.TrafficCheckRetryDelay = time.Millisecond
+	app.config.RetryBudgetCapacity = 1
+	app.config.RetryBudgetRefillRate = 1
+	app.configureRetryBudget()
+	requests := useFlakyTrafficServer(t, app, 10)
+
+	if err := app.checkTrafficWithRetry(context.Background()); err == nil {
+		t.Fatal("check should fail")
+	}
+	// The single token pays for one retry
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+	if got := app.Stats().RetryBudget.Tokens; got >= 1 {
+		t.Errorf("retry budget = %v tokens, want it used up", got)
+	}
+}
+
+func TestValidate_RetryBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		rate     float64
+		ok       bool
+	}{
+		{"default", 20, 1, true},
+		{"off", 0, 0, true},
+		{"negative capacity", -1, 1, false},
+		{"no refill", 20, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.RetryBudgetCapacity = tt.capacity
+			cfg.RetryBudgetRefillRate = tt.rate
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("err=%v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// selfTestReport is the structured summary printed by --self-test.
+type selfTestReport struct {
+	Config         *config  `json:"config"`
+	RemoteEndpoint string   `json:"remote_endpoint"`
+	ProxyHost      string   `json:"proxy_host"`
+	SSHPID         int      `json:"ssh_pid,omitempty"`
+	TunnelReady    bool     `json:"tunnel_ready"`
+	TrafficCheck   string   `json:"traffic_check"`
+	TrafficOK      bool     `json:"traffic_ok"`
+	Duration       string   `json:"duration"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// selfTestResult is what the self-test steps report back before the time limit.
+type selfTestResult struct {
+	tunnelReady bool
+	trafficOK   bool
+	err         error
+}
+
+// selfTest starts the tunnel, waits for it to become ready, runs one traffic check and
+// stops the tunnel again, all within SelfTestTimeout. It writes a JSON report to w and
+// returns an error if any step failed. No PID file is written and the management API is not started.
+func (app *Application) selfTest(w io.Writer) error {
+	start := time.Now()
+	logger := app.componentLogger(componentTunnel)
+
+	// The tunnel must give up on its own before the overall limit
+	app.config.TunnelStartTimeout = min(app.config.TunnelStartTimeout, app.config.SelfTestTimeout)
+
+	report := selfTestReport{
+		Config:         app.config,
+		RemoteEndpoint: app.config.remoteEndpoint(),
+		TrafficCheck:   app.config.TrafficCheckMode,
+	}
+
+	if err := app.runSelfTest(&report); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	app.sshMutex.RLock()
+	if app.sshProcess != nil && app.sshProcess.Process != nil {
+		report.SSHPID = app.sshProcess.Process.Pid
+	}
+	app.sshMutex.RUnlock()
+	app.stopSSH(logger)
+	if err := app.config.removeIdentityKey(); err != nil {
+		logger.Error("Failed to remove identity file", "error", err)
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write self-test report: %w", err)
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d self-test check(s) failed", len(report.Errors))
+	}
+	return nil
+}
+
+// runSelfTest prepares the app and runs the tunnel steps under the time limit.
+func (app *Application) runSelfTest(report *selfTestReport) error {
+	if _, err := app.config.autoSelectPort(); err != nil {
+		return fmt.Errorf("port selection failed: %w", err)
+	}
+	report.ProxyHost = app.config.proxyHost
+
+	if err := app.config.writeIdentityKey(nil); err != nil {
+		return err
+	}
+
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		return fmt.Errorf("http transport initialization failed: %w", err)
+	}
+	app.httpTransport = transport
+
+	results := make(chan selfTestResult, 1)
+	go func() {
+		results <- app.selfTestSteps()
+	}()
+
+	timer := time.NewTimer(app.config.SelfTestTimeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-results:
+		report.TunnelReady = result.tunnelReady
+		report.TrafficOK = result.trafficOK
+		return result.err
+	case <-timer.C:
+		return fmt.Errorf("self-test did not finish within %s", app.config.SelfTestTimeout)
+	}
+}
+
+// selfTestSteps starts SSH and checks traffic through it.
+func (app *Application) selfTestSteps() selfTestResult {
+	if err := app.startSSH(app.componentLogger(componentSSH)); err != nil {
+		return selfTestResult{err: fmt.Errorf("tunnel failed to start: %w", err)}
+	}
+
+	if err := app.checkTraffic(); err != nil {
+		return selfTestResult{tunnelReady: true, err: fmt.Errorf("traffic check failed: %w", err)}
+	}
+	return selfTestResult{tunnelReady: true, trafficOK: true}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useSleepingSSH replaces ssh with a process that stays up without opening any port,
+// so the test controls the proxy listener itself.
+func useSleepingSSH(t *testing.T) {
+	t.Helper()
+	requireShell(t)
+
+	script := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 30\n"), 0o700); err != nil {
+		t.Fatalf("failed to write fake ssh: %v", err)
+	}
+
+	originalSSHBinary := sshBinary
+	sshBinary = script
+	t.Cleanup(func() { sshBinary = originalSSHBinary })
+}
+
+// serveSocks5Connects accepts connections on listener and answers every SOCKS5 CONNECT
+// with success. Readiness probes that close without a greeting are ignored.
+func serveSocks5Connects(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			greeting := make([]byte, 3)
+			if _, err := io.ReadFull(conn, greeting); err != nil {
+				return
+			}
+			if _, err := conn.Write([]byte{socks5Version, socks5NoAuth}); err != nil {
+				return
+			}
+			// Request for an IPv4 target: header (4) + address (4) + port (2)
+			request := make([]byte, 10)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte{socks5Version, socks5ReplySuccess, 0x00, socks5AtypIPv4, 127, 0, 0, 1, 0x04, 0x38})
+		}()
+	}
+}
+
+// selfTestResultReport holds the report fields the tests assert on.
+type selfTestResultReport struct {
+	ProxyHost   string   `json:"proxy_host"`
+	SSHPID      int      `json:"ssh_pid"`
+	TunnelReady bool     `json:"tunnel_ready"`
+	TrafficOK   bool     `json:"traffic_ok"`
+	Errors      []string `json:"errors"`
+}
+
+// newSelfTestApp returns an app checking traffic with a SOCKS5 CONNECT to an IPv4 target.
+func newSelfTestApp(t *testing.T) *Application {
+	t.Helper()
+	useSleepingSSH(t)
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.TrafficCheckMode = trafficCheckSocks5Connect
+	app.config.TrafficCheckSocks5Target = "10.0.0.1:80"
+	app.config.PortCheckTimeout = time.Second
+	return app
+}
+
+// runSelfTest runs the self-test and decodes its report.
+func runSelfTest(t *testing.T, app *Application) (selfTestResultReport, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	runErr := app.selfTest(&out)
+
+	var report selfTestResultReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report %q: %v", out.String(), err)
+	}
+
+	app.sshMutex.RLock()
+	defer app.sshMutex.RUnlock()
+	if app.sshProcess != nil {
+		t.Error("self-test must stop the SSH process")
+	}
+	return report, runErr
+}
+
+func TestSelfTest_Success(t *testing.T) {
+	app := newSelfTestApp(t)
+	listener := useProxyListener(t, app)
+	go serveSocks5Connects(listener)
+
+	report, err := runSelfTest(t, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v (report errors: %v)", err, report.Errors)
+	}
+	if !report.TunnelReady || !report.TrafficOK {
+		t.Errorf("tunnel_ready=%v traffic_ok=%v, want both true", report.TunnelReady, report.TrafficOK)
+	}
+	if report.SSHPID == 0 {
+		t.Error("report should include the SSH PID")
+	}
+	if report.ProxyHost != listener.Addr().String() {
+		t.Errorf("proxy_host = %q, want %q", report.ProxyHost, listener.Addr().String())
+	}
+}
+
+func TestSelfTest_TrafficCheckFails(t *testing.T) {
+	app := newSelfTestApp(t)
+	listener := useProxyListener(t, app)
+	go func() {
+		// Accept connections but never speak SOCKS5
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	report, err := runSelfTest(t, app)
+	if err == nil {
+		t.Fatal("expected error for failed traffic check")
+	}
+	if !report.TunnelReady || report.TrafficOK {
+		t.Errorf("tunnel_ready=%v traffic_ok=%v, want true and false", report.TunnelReady, report.TrafficOK)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("Errors = %v, want exactly the traffic check failure", report.Errors)
+	}
+}
+
+func TestSelfTest_Timeout(t *testing.T) {
+	app := newSelfTestApp(t)
+	app.config.SelfTestTimeout = 300 * time.Millisecond
+
+	// Nothing ever listens on the proxy port
+	listener := useProxyListener(t, app)
+	_ = listener.Close()
+
+	start := time.Now()
+	report, err := runSelfTest(t, app)
+	if err == nil {
+		t.Fatal("expected error when the tunnel never becomes ready")
+	}
+	if report.TunnelReady {
+		t.Error("tunnel should not be reported ready")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("self-test took %s, want it bounded by the timeout", elapsed)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxSessions is how many SSH sessions the session log keeps.
+const maxSessions = 50
+
+// SessionLog records one SSH process started by the tunnel.
+type SessionLog struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end,omitzero"` // zero while the process runs
+	PID           int       `json:"pid"`
+	ExitCode      int       `json:"exit_code"` // -1 if killed by a signal; valid once End is set
+	BindHost      string    `json:"bind_host"`
+	RemoteAddress string    `json:"remote_address"`
+}
+
+// sessionsResponse is the body of GET /api/v1/sessions.
+type sessionsResponse struct {
+	Sessions []SessionLog `json:"sessions"`
+}
+
+// loadSessionLog reads the sessions saved by a previous run from SessionLogFile.
+// A missing file is not an error.
+func (app *Application) loadSessionLog() error {
+	if app.config.SessionLogFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(app.config.SessionLogFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session log: %w", err)
+	}
+
+	var sessions []SessionLog
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return fmt.Errorf("failed to parse session log %s: %w", app.config.SessionLogFile, err)
+	}
+	if extra := len(sessions) - maxSessions; extra > 0 {
+		sessions = sessions[extra:]
+	}
+
+	app.sessionMutex.Lock()
+	app.sessionLog = sessions
+	app.sessionMutex.Unlock()
+	return nil
+}
+
+// recordSessionStart adds a session for the freshly started cmd.
+func (app *Application) recordSessionStart(cmd *exec.Cmd) {
+	app.sessionMutex.Lock()
+	defer app.sessionMutex.Unlock()
+
+	app.sessionLog = append(app.sessionLog, SessionLog{
+		Start:         time.Now().UTC(),
+		PID:           cmd.Process.Pid,
+		BindHost:      strings.Join(app.config.bindHosts(), ","),
+		RemoteAddress: app.config.remoteAddress(),
+	})
+	if extra := len(app.sessionLog) - maxSessions; extra > 0 {
+		app.sessionLog = app.sessionLog[extra:]
+	}
+	app.saveSessionLog()
+}
+
+// recordSessionEnd closes the session of cmd once it has been reaped.
+func (app *Application) recordSessionEnd(cmd *exec.Cmd) {
+	app.sessionMutex.Lock()
+	defer app.sessionMutex.Unlock()
+
+	// Newest first: a session left open by a crashed run may carry a reused PID
+	for i := len(app.sessionLog) - 1; i >= 0; i-- {
+		session := &app.sessionLog[i]
+		if session.PID != cmd.Process.Pid || !session.End.IsZero() {
+			continue
+		}
+		session.End = time.Now().UTC()
+		session.ExitCode = cmd.ProcessState.ExitCode()
+		app.saveSessionLog()
+		return
+	}
+}
+
+// sessions returns a copy of the session log, oldest first.
+func (app *Application) sessions() []SessionLog {
+	app.sessionMutex.Lock()
+	defer app.sessionMutex.Unlock()
+	return slices.Clone(app.sessionLog)
+}
+
+// saveSessionLog writes the session log to SessionLogFile. Callers hold sessionMutex.
+func (app *Application) saveSessionLog() {
+	app.configMutex.RLock()
+	path := app.config.SessionLogFile
+	app.configMutex.RUnlock()
+	if path == "" {
+		return
+	}
+
+	data, err := json.Marshal(app.sessionLog)
+	if err == nil {
+		err = writeFileAtomic(filepath.Clean(path), data)
+	}
+	if err != nil {
+		app.logger.Error("Failed to write session log", "path", path, "error", err)
+	}
+}
+
+// handleSessions lists the recent SSH sessions, oldest first.
+func (app *Application) handleSessions(w http.ResponseWriter, r *http.Request) {
+	sessions := app.sessions()
+	if sessions == nil {
+		sessions = []SessionLog{}
+	}
+	writeJSON(w, http.StatusOK, sessionsResponse{Sessions: sessions})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessionLog_StartStop(t *testing.T) {
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	app.config.SessionLogFile = filepath.Join(t.TempDir(), "sessions.json")
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	pid := app.sshProcess.Process.Pid
+	if sessions := app.sessions(); len(sessions) != 1 || sessions[0].PID != pid || !sessions[0].End.IsZero() {
+		t.Fatalf("sessions after start = %+v, want one open session of PID %d", sessions, pid)
+	}
+
+	app.stopSSH(app.logger)
+	sessions := app.sessions()
+	if len(sessions) != 1 || sessions[0].End.IsZero() {
+		t.Fatalf("sessions after stop = %+v, want the session closed", sessions)
+	}
+	if sessions[0].BindHost != bindHost || sessions[0].RemoteAddress != app.config.SSHRemoteAddress {
+		t.Errorf("session = %+v, want bind host %s and remote %s", sessions[0], bindHost, app.config.SSHRemoteAddress)
+	}
+
+	// A new run picks up the saved sessions
+	restarted := newTestApp(t)
+	restarted.config.SessionLogFile = app.config.SessionLogFile
+	if err := restarted.loadSessionLog(); err != nil {
+		t.Fatalf("loadSessionLog: %v", err)
+	}
+	if loaded := restarted.sessions(); len(loaded) != 1 || loaded[0].PID != pid || !loaded[0].End.Equal(sessions[0].End) {
+		t.Errorf("loaded sessions = %+v, want %+v", loaded, sessions)
+	}
+}
+
+func TestLoadSessionLog(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(t)
+
+	app.config.SessionLogFile = filepath.Join(dir, "missing.json")
+	if err := app.loadSessionLog(); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+
+	app.config.SessionLogFile = filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(app.config.SessionLogFile, []byte("{"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := app.loadSessionLog(); err == nil {
+		t.Error("invalid file should fail")
+	}
+
+	sessions := make([]SessionLog, maxSessions+10)
+	for i := range sessions {
+		sessions[i] = SessionLog{PID: i + 1, Start: time.Now().UTC()}
+	}
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	app.config.SessionLogFile = filepath.Join(dir, "sessions.json")
+	if err := os.WriteFile(app.config.SessionLogFile, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := app.loadSessionLog(); err != nil {
+		t.Fatalf("loadSessionLog: %v", err)
+	}
+	if loaded := app.sessions(); len(loaded) != maxSessions || loaded[0].PID != 11 {
+		t.Errorf("loaded %d sessions starting at PID %d, want the last %d", len(loaded), loaded[0].PID, maxSessions)
+	}
+}
+
+func TestMgmtAPI_Sessions(t *testing.T) {
+	app, srv := newTestMgmtServer(t)
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/api/v1/sessions", "", "")
+	var body sessionsResponse
+	decodeBody(t, resp, &body)
+	if resp.StatusCode != http.StatusOK || body.Sessions == nil || len(body.Sessions) != 0 {
+		t.Errorf("status %d, sessions %v, want 200 and an empty list", resp.StatusCode, body.Sessions)
+	}
+
+	app.sessionLog = []SessionLog{{PID: 42, ExitCode: 255, RemoteAddress: "user@host"}}
+	resp = doRequest(t, http.MethodGet, srv.URL+"/api/v1/sessions", "", "")
+	decodeBody(t, resp, &body)
+	if len(body.Sessions) != 1 || body.Sessions[0].PID != 42 || body.Sessions[0].ExitCode != 255 {
+		t.Errorf("sessions = %+v, want the recorded session", body.Sessions)
+	}
+}
@@ -11,3 +11,14 @@ import (
 func shutdownSignals() []os.Signal {
 	return []os.Signal{syscall.SIGINT, syscall.SIGTERM}
 }
+
+// pauseSignals returns the signals handled when SIGUSR1_ACTION=pause:
+// SIGUSR1 toggles the pause, SIGUSR2 always resumes.
+func pauseSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}
+}
+
+// isResumeSignal reports whether sig always resumes a paused tunnel.
+func isResumeSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR2
+}
@@ -13,3 +13,13 @@ import (
 func shutdownSignals() []os.Signal {
 	return []os.Signal{os.Interrupt, syscall.SIGTERM}
 }
+
+// pauseSignals returns nil: Windows has no SIGUSR1/SIGUSR2, so pausing is unsupported.
+func pauseSignals() []os.Signal {
+	return nil
+}
+
+// isResumeSignal always reports false on Windows.
+func isResumeSignal(os.Signal) bool {
+	return false
+}
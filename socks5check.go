@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Traffic check modes.
+const (
+	trafficCheckHTTP          = "http"
+	trafficCheckSocks5Connect = "socks5-connect"
+)
+
+// socks5CheckTimeout bounds a whole SOCKS5 CONNECT check, like the HTTP client timeout.
+const socks5CheckTimeout = 10 * time.Second
+
+// SOCKS5 protocol constants (RFC 1928).
+const (
+	socks5Version      = 0x05
+	socks5NoAuth       = 0x00
+	socks5CmdConnect   = 0x01
+	socks5AtypIPv4     = 0x01
+	socks5AtypDomain   = 0x03
+	socks5AtypIPv6     = 0x04
+	socks5ReplySuccess = 0x00
+)
+
+// checkViaSocks5Connect performs a raw SOCKS5 CONNECT through the proxy to TrafficCheckSocks5Target.
+// It validates the SOCKS5 path without the HTTP stack.
+func (app *Application) checkViaSocks5Connect(ctx context.Context) bool {
+	logger := app.checkLogger()
+	target := app.config.TrafficCheckSocks5Target
+
+	dialer := app.config.dialer(&net.Dialer{Timeout: app.config.PortCheckTimeout})
+	conn, err := dialer.DialContext(ctx, "tcp", app.config.proxyHost)
+	if err != nil {
+		logger.Error("SOCKS5 check failed to reach proxy", "host", app.config.proxyHost, "error", err)
+		return false
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			logger.Error("Failed to close proxy connection", "error", err)
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			logger.Error("Failed to set SOCKS5 deadline", "error", err)
+			return false
+		}
+	}
+
+	if err := socks5Connect(conn, target); err != nil {
+		logger.Error("SOCKS5 CONNECT check failed", "target", target, "error", err)
+		return false
+	}
+	return true
+}
+
+// socks5Connect runs the no-auth greeting and a CONNECT request for target over conn.
+func socks5Connect(conn io.ReadWriter, target string) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid target port: %s", portStr)
+	}
+
+	// Greeting: one method, no authentication
+	if _, err := conn.Write([]byte{socks5Version, 1, socks5NoAuth}); err != nil {
+		return fmt.Errorf("failed to send greeting: %w", err)
+	}
+	var method [2]byte
+	if _, err := io.ReadFull(conn, method[:]); err != nil {
+		return fmt.Errorf("failed to read greeting reply: %w", err)
+	}
+	if method[0] != socks5Version || method[1] != socks5NoAuth {
+		return fmt.Errorf("proxy rejected no-auth method: %#x", method[1])
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, socks5AtypIPv4), ip4...)
+		} else {
+			req = append(append(req, socks5AtypIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("target host name too long")
+		}
+		req = append(append(req, socks5AtypDomain, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+
+	// Reply: VER REP RSV ATYP BND.ADDR BND.PORT
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return fmt.Errorf("failed to read CONNECT reply: %w", err)
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version in reply: %#x", header[0])
+	}
+	if header[1] != socks5ReplySuccess {
+		return fmt.Errorf("CONNECT failed with reply code %#x", header[1])
+	}
+
+	var addrLen int
+	switch header[3] {
+	case socks5AtypIPv4:
+		addrLen = net.IPv4len
+	case socks5AtypIPv6:
+		addrLen = net.IPv6len
+	case socks5AtypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return fmt.Errorf("failed to read bound address: %w", err)
+		}
+		addrLen = int(n[0])
+	default:
+		return fmt.Errorf("unexpected address type in reply: %#x", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return fmt.Errorf("failed to read bound address: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveSocks5 answers one SOCKS5 greeting and CONNECT on conn with the given reply code
+// and sends the requested destination to got.
+func serveSocks5(t *testing.T, conn net.Conn, reply byte, got chan<- []byte) {
+	t.Helper()
+	defer func() { _ = conn.Close() }()
+
+	greeting := make([]byte, 3)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		t.Errorf("failed to read greeting: %v", err)
+		return
+	}
+	if _, err := conn.Write([]byte{socks5Version, socks5NoAuth}); err != nil {
+		t.Errorf("failed to write greeting reply: %v", err)
+		return
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Errorf("failed to read request: %v", err)
+		return
+	}
+	var addrLen int
+	switch header[3] {
+	case socks5AtypIPv4:
+		addrLen = net.IPv4len
+	case socks5AtypIPv6:
+		addrLen = net.IPv6len
+	case socks5AtypDomain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			t.Errorf("failed to read domain length: %v", err)
+			return
+		}
+		addrLen = int(n[0])
+	}
+	dest := make([]byte, addrLen+2)
+	if _, err := io.ReadFull(conn, dest); err != nil {
+		t.Errorf("failed to read destination: %v", err)
+		return
+	}
+	got <- append(header[3:4], dest...)
+
+	// The client may stop reading after a failure code, so the write error is ignored
+	_, _ = conn.Write([]byte{socks5Version, reply, 0x00, socks5AtypIPv4, 127, 0, 0, 1, 0x04, 0x38})
+}
+
+func TestSocks5Connect(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		reply    byte
+		wantDest []byte
+		wantErr  bool
+	}{
+		{"ipv4 target", "8.8.8.8:443", socks5ReplySuccess, []byte{socks5AtypIPv4, 8, 8, 8, 8, 0x01, 0xbb}, false},
+		{"domain target", "db.internal:5432", socks5ReplySuccess, append(append([]byte{socks5AtypDomain}, "db.internal"...), 0x15, 0x38), false},
+		{"connection refused", "8.8.8.8:443", 0x05, []byte{socks5AtypIPv4, 8, 8, 8, 8, 0x01, 0xbb}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer func() { _ = client.Close() }()
+			got := make(chan []byte, 1)
+			go serveSocks5(t, server, tt.reply, got)
+
+			err := socks5Connect(client, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("socks5Connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if dest := <-got; string(dest) != string(tt.wantDest) {
+				t.Errorf("destination = %v, want %v", dest, tt.wantDest)
+			}
+		})
+	}
+}
+
+func TestSocks5Connect_AuthRejected(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go func() {
+		defer func() { _ = server.Close() }()
+		_, _ = io.ReadFull(server, make([]byte, 3))
+		_, _ = server.Write([]byte{socks5Version, 0xff})
+	}()
+
+	if err := socks5Connect(client, "8.8.8.8:443"); err == nil {
+		t.Error("expected error when the proxy rejects no-auth")
+	}
+}
+
+func TestCheckViaSocks5Connect(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.TrafficCheckSocks5Target = "10.0.0.1:80"
+	listener := useProxyListener(t, app)
+
+	got := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		serveSocks5(t, conn, socks5ReplySuccess, got)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !app.checkViaSocks5Connect(ctx) {
+		t.Error("checkViaSocks5Connect should succeed")
+	}
+	<-got
+}
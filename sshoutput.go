@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// sshOutputFilter decides which ssh stderr lines are dropped or promoted to errors.
+type sshOutputFilter struct {
+	drop    []*regexp.Regexp
+	promote *regexp.Regexp
+}
+
+// newSSHOutputFilter compiles the SSH_OUTPUT_FILTER and SSH_OUTPUT_PROMOTE_PATTERN patterns.
+// It returns nil when neither is configured.
+func newSSHOutputFilter(drop []string, promote string) (*sshOutputFilter, error) {
+	if len(drop) == 0 && promote == "" {
+		return nil, nil
+	}
+
+	filter := &sshOutputFilter{}
+	for _, pattern := range drop {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH output filter %q: %w", pattern, err)
+		}
+		filter.drop = append(filter.drop, re)
+	}
+	if promote != "" {
+		re, err := regexp.Compile(promote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH output promote pattern %q: %w", promote, err)
+		}
+		filter.promote = re
+	}
+	return filter, nil
+}
+
+// promoted reports whether line must be logged as an error.
+func (f *sshOutputFilter) promoted(line string) bool {
+	return f != nil && f.promote != nil && f.promote.MatchString(line)
+}
+
+// dropped reports whether line is suppressed.
+func (f *sshOutputFilter) dropped(line string) bool {
+	if f == nil {
+		return false
+	}
+	for _, re := range f.drop {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// sshStderr returns the writer for ssh's stderr. Lines are passed on to our stderr
+// unless filtered, promoted lines are also logged as errors, and host key messages are logged.
+func (app *Application) sshStderr(logger *slog.Logger) io.Writer {
+	return &sshOutput{
+		out:      os.Stderr,
+		logger:   logger,
+		filter:   app.sshOutputFilter,
+		hostKeys: &hostKeyWatcher{logger: logger, host: app.config.knownHostsName()},
+	}
+}
+
+// sshOutput splits ssh's stderr into lines and handles each one.
+type sshOutput struct {
+	out      io.Writer
+	logger   *slog.Logger
+	filter   *sshOutputFilter
+	hostKeys *hostKeyWatcher
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+// Write implements io.Writer. Incomplete lines are kept until the rest arrives.
+func (o *sshOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.handleLine(strings.TrimRight(string(o.partial[:i]), "\r"))
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// handleLine logs and forwards a single line of ssh output.
+func (o *sshOutput) handleLine(line string) {
+	o.hostKeys.handleLine(line)
+
+	switch {
+	case o.filter.promoted(line):
+		o.logger.Error("SSH reported an error", "line", line)
+	case o.filter.dropped(line):
+		return
+	}
+	// Failing to echo ssh's output must not stop ssh
+	_, _ = io.WriteString(o.out, line+"\n")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewSSHOutputFilter(t *testing.T) {
+	filter, err := newSSHOutputFilter(nil, "")
+	if err != nil || filter != nil {
+		t.Errorf("newSSHOutputFilter() = %v, %v; want nil, nil without patterns", filter, err)
+	}
+
+	if _, err := newSSHOutputFilter([]string{"^debug1:", "("}, ""); err == nil {
+		t.Error("expected error for invalid filter pattern")
+	}
+	if _, err := newSSHOutputFilter(nil, "[a-"); err == nil {
+		t.Error("expected error for invalid promote pattern")
+	}
+}
+
+func TestSSHOutput_Filter(t *testing.T) {
+	filter, err := newSSHOutputFilter([]string{"^debug1:", "^channel \\d+: open failed"}, "Permission denied|debug1: fatal")
+	if err != nil {
+		t.Fatalf("newSSHOutputFilter: %v", err)
+	}
+
+	var out, logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	w := &sshOutput{out: &out, logger: logger, filter: filter, hostKeys: &hostKeyWatcher{logger: logger}}
+
+	input := "debug1: Reading configuration data\n" +
+		"channel 3: open failed: connect failed: Connection refused\n" +
+		"Connection to host closed by remote host.\n" +
+		"debug1: fatal thing\n" +
+		"user@host: Permission denied (publickey).\n"
+	if _, err := w.Write([]byte(input)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	want := "Connection to host closed by remote host.\n" +
+		"debug1: fatal thing\n" +
+		"user@host: Permission denied (publickey).\n"
+	if out.String() != want {
+		t.Errorf("forwarded output = %q, want %q", out.String(), want)
+	}
+	if n := strings.Count(logs.String(), "level=ERROR"); n != 2 {
+		t.Errorf("got %d error records, want 2 promoted lines:\n%s", n, logs.String())
+	}
+}
+
+func TestSSHOutput_NoFilter(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.DiscardHandler)
+	w := &sshOutput{out: &out, logger: logger, hostKeys: &hostKeyWatcher{logger: logger}}
+
+	if _, err := w.Write([]byte("debug1: one\r\ndebug1: two\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if out.String() != "debug1: one\ndebug1: two\n" {
+		t.Errorf("forwarded output = %q", out.String())
+	}
+}
+
+func TestInitialize_InvalidSSHOutputFilter(t *testing.T) {
+	app := newTestApp(t)
+	app.config.SSHOutputFilter = []string{"("}
+
+	if err := app.initialize(); err == nil {
+		app.cleanup()
+		t.Fatal("expected initialize to fail for an invalid pattern")
+	}
+}
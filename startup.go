@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// waitForStartup sleeps for StartupDelay, then polls the SSH server until it accepts
+// connections or StartupProbeMaxDuration elapses, so a server that is still booting
+// doesn't flood the log with failed health checks.
+// Returns false if shutdown was requested while waiting.
+func (app *Application) waitForStartup() bool {
+	logger := app.componentLogger(componentTunnel)
+
+	if delay := app.config.StartupDelay; delay > 0 {
+		logger.Info("Delaying startup", "delay", delay)
+		if !app.sleepOrShutdown(delay) {
+			return false
+		}
+	}
+
+	if app.config.StartupProbeMaxDuration <= 0 {
+		return true
+	}
+
+	// The SSH server may only be reachable through the HTTP proxy
+	endpoint, network := app.config.remoteEndpoint(), app.config.serverNetwork()
+	if proxyAddr := app.config.httpProxyAddr(); proxyAddr != "" {
+		endpoint, network = proxyAddr, "tcp"
+	}
+	dialer := app.config.dialer(&net.Dialer{Timeout: app.config.PortCheckTimeout})
+	deadline := time.Now().Add(app.config.StartupProbeMaxDuration)
+	for {
+		conn, err := dialer.DialContext(context.Background(), network, endpoint)
+		if err == nil {
+			if err := conn.Close(); err != nil {
+				logger.Error("Failed to close remote connection", "error", err)
+			}
+			logger.Info("SSH server is reachable", "remote", endpoint)
+			return true
+		}
+		logger.Debug("SSH server not reachable yet", "remote", endpoint, "error", err)
+
+		if time.Now().After(deadline) {
+			logger.Warn("SSH server still unreachable, starting main loop anyway",
+				"remote", endpoint, "waited", app.config.StartupProbeMaxDuration)
+			return true
+		}
+		if !app.sleepOrShutdown(app.config.StartupProbeInterval) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// newStartupTestApp returns an app whose SSH server is 127.0.0.1:port.
+func newStartupTestApp(t *testing.T, port int) *Application {
+	t.Helper()
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.SSHRemoteAddress = "user@127.0.0.1"
+	app.config.SSHRemotePort = port
+	app.config.PortCheckTimeout = 100 * time.Millisecond
+	app.config.StartupProbeInterval = 10 * time.Millisecond
+	app.config.StartupProbeMaxDuration = 5 * time.Second
+	return app
+}
+
+func TestWaitForStartup_ServerReachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	app := newStartupTestApp(t, listenerPort(t, listener))
+	if !app.waitForStartup() {
+		t.Error("waitForStartup should succeed once the server is reachable")
+	}
+}
+
+func TestWaitForStartup_GivesUpAfterMaxDuration(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listenerPort(t, listener)
+	_ = listener.Close()
+
+	app := newStartupTestApp(t, port)
+	app.config.StartupProbeMaxDuration = 50 * time.Millisecond
+
+	start := time.Now()
+	if !app.waitForStartup() {
+		t.Error("waitForStartup should continue to the main loop after the probe window")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned after %v, before the probe window elapsed", elapsed)
+	}
+}
+
+func TestWaitForStartup_ShutdownDuringDelay(t *testing.T) {
+	app := newStartupTestApp(t, 2212)
+	app.config.StartupDelay = time.Hour
+	close(app.shutdownChan)
+
+	done := make(chan bool, 1)
+	go func() { done <- app.waitForStartup() }()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("waitForStartup should report shutdown")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForStartup did not return on shutdown")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+// tunnelState is the lifecycle state of the SSH tunnel.
+type tunnelState int32
+
+// Tunnel states. The numeric values are stable and may be exported as metrics.
+const (
+	StateUnknown tunnelState = iota
+	StateStarting
+	StateRunning
+	StateDegraded
+	StateStopping
+	StateStopped
+)
+
+// String returns the lowercase state name.
+func (s tunnelState) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateDegraded:
+		return "degraded"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// state returns the current tunnel state.
+func (app *Application) state() tunnelState {
+	return tunnelState(app.currentState.Load())
+}
+
+// setState moves the tunnel to a new state and logs the transition.
+func (app *Application) setState(newState tunnelState) {
+	oldState := tunnelState(app.currentState.Swap(int32(newState)))
+	if oldState == newState {
+		return
+	}
+	app.componentLogger(componentTunnel).Info("Tunnel state changed", "old_state", oldState.String(), "new_state", newState.String())
+}
+
+// markDegraded moves a running tunnel to degraded. Other states are left alone
+// so failed checks during start or shutdown don't mask the real state.
+func (app *Application) markDegraded() {
+	if app.currentState.CompareAndSwap(int32(StateRunning), int32(StateDegraded)) {
+		app.componentLogger(componentTunnel).Info("Tunnel state changed", "old_state", StateRunning.String(), "new_state", StateDegraded.String())
+	}
+}
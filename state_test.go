@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTunnelState_String(t *testing.T) {
+	tests := []struct {
+		state tunnelState
+		want  string
+	}{
+		{StateUnknown, "unknown"},
+		{StateStarting, "starting"},
+		{StateRunning, "running"},
+		{StateDegraded, "degraded"},
+		{StateStopping, "stopping"},
+		{StateStopped, "stopped"},
+		{tunnelState(99), "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("tunnelState(%d).String() = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestSetState_LogsTransition(t *testing.T) {
+	var buf bytes.Buffer
+	app := &Application{logger: slog.New(slog.NewTextHandler(&buf, nil))}
+
+	app.setState(StateStarting)
+	if app.state() != StateStarting {
+		t.Fatalf("state = %v, want %v", app.state(), StateStarting)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "old_state=unknown") || !strings.Contains(out, "new_state=starting") {
+		t.Errorf("transition not logged: %s", out)
+	}
+
+	// Same-state updates are not logged
+	buf.Reset()
+	app.setState(StateStarting)
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log for unchanged state: %s", buf.String())
+	}
+}
+
+func TestMarkDegraded(t *testing.T) {
+	app := &Application{logger: slog.New(slog.DiscardHandler)}
+
+	app.setState(StateStarting)
+	app.markDegraded()
+	if app.state() != StateStarting {
+		t.Errorf("state = %v, want %v; only running tunnels degrade", app.state(), StateStarting)
+	}
+
+	app.setState(StateRunning)
+	app.markDegraded()
+	if app.state() != StateDegraded {
+		t.Errorf("state = %v, want %v", app.state(), StateDegraded)
+	}
+}
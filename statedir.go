@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// stateDirName is the directory of ssh-tunnel below the platform's state directory.
+const stateDirName = "ssh-tunnel"
+
+// stateDirFor returns the directory of the default PID and log files on goos,
+// following the XDG Base Directory Specification where XDG_STATE_HOME is set.
+func stateDirFor(goos string, getenv func(string) string) (string, error) {
+	// The specification says to ignore relative paths
+	if dir := getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, stateDirName), nil
+	}
+
+	if goos == "windows" {
+		appData := getenv("APPDATA")
+		if appData == "" {
+			return "", errors.New("%APPDATA% is not set")
+		}
+		return filepath.Join(appData, stateDirName), nil
+	}
+
+	home := getenv("HOME")
+	if home == "" {
+		return "", errors.New("$HOME is not set")
+	}
+	if goos == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", stateDirName), nil
+	}
+	return filepath.Join(home, ".local", "state", stateDirName), nil
+}
+
+// usesStateDir reports whether the PID or log file is left at its default name,
+// which is then placed in the state directory.
+func (c *config) usesStateDir() bool {
+	return c.PIDFile == defaultPIDFile || c.LogFile == defaultLogFile
+}
+
+// createStateDir creates the state directory, if one is used.
+func (c *config) createStateDir() error {
+	if c.stateDir == "" {
+		return nil
+	}
+	return os.MkdirAll(c.stateDir, 0o700)
+}
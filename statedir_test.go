@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateDirFor(t *testing.T) {
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		want string
+	}{
+		{"XDG", "linux", map[string]string{"XDG_STATE_HOME": "/var/state", "HOME": "/home/u"}, filepath.Join("/var/state", "ssh-tunnel")},
+		{"XDG on macOS", "darwin", map[string]string{"XDG_STATE_HOME": "/var/state", "HOME": "/Users/u"}, filepath.Join("/var/state", "ssh-tunnel")},
+		{"relative XDG ignored", "linux", map[string]string{"XDG_STATE_HOME": "state", "HOME": "/home/u"}, filepath.Join("/home/u", ".local", "state", "ssh-tunnel")},
+		{"Linux", "linux", map[string]string{"HOME": "/home/u"}, filepath.Join("/home/u", ".local", "state", "ssh-tunnel")},
+		{"macOS", "darwin", map[string]string{"HOME": "/Users/u"}, filepath.Join("/Users/u", "Library", "Application Support", "ssh-tunnel")},
+		{"Windows", "windows", map[string]string{"APPDATA": "/appdata"}, filepath.Join("/appdata", "ssh-tunnel")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stateDirFor(tt.goos, func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatalf("stateDirFor: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("stateDirFor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateDirFor_Unset(t *testing.T) {
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		if _, err := stateDirFor(goos, func(string) string { return "" }); err == nil {
+			t.Errorf("%s: expected error without a home directory", goos)
+		}
+	}
+}
+
+func TestStateDir_DefaultFilesOnly(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state", "ssh-tunnel")
+	cfg := validConfig()
+	cfg.proxyPort = "8080"
+	cfg.stateDir = dir
+
+	if got, want := cfg.getPortSpecificPIDFile(), filepath.Join(dir, "ssh-tunnel-8080.pid"); got != want {
+		t.Errorf("PID file = %q, want %q", got, want)
+	}
+	if got, want := cfg.getPortSpecificLogFile(), filepath.Join(dir, "ssh-tunnel-8080.log"); got != want {
+		t.Errorf("log file = %q, want %q", got, want)
+	}
+
+	// Explicit names take precedence over the state directory
+	cfg.PIDFile = "/run/tunnel.pid"
+	cfg.LogFile = "/var/log/tunnel.log"
+	if cfg.usesStateDir() {
+		t.Error("usesStateDir should be false with explicit file names")
+	}
+	if got := cfg.getPortSpecificPIDFile(); got != "/run/tunnel-8080.pid" {
+		t.Errorf("PID file = %q, want %q", got, "/run/tunnel-8080.pid")
+	}
+	if got := cfg.getPortSpecificLogFile(); got != "/var/log/tunnel-8080.log" {
+		t.Errorf("log file = %q, want %q", got, "/var/log/tunnel-8080.log")
+	}
+
+	if err := cfg.createStateDir(); err != nil {
+		t.Fatalf("createStateDir: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("state directory not created: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import "time"
+
+// TunnelStats is a point-in-time snapshot of tunnel health counters.
+type TunnelStats struct {
+	TotalRestarts       int64     `json:"total_restarts"`
+	ConsecutiveFailures int64     `json:"consecutive_failures"`
+	LastRestartTime     time.Time `json:"last_restart_time,omitzero"`
+	TunnelUpSince       time.Time `json:"tunnel_up_since,omitzero"`
+	UptimeSeconds       float64   `json:"uptime_seconds"`
+	BytesIn             int64     `json:"bytes_in"`
+	BytesOut            int64     `json:"bytes_out"`
+	CPUPercent          float64   `json:"cpu_percent"`
+	MemoryMB            float64   `json:"memory_mb"`
+	HealthScore         float64   `json:"health_score"`
+
+	OverlapChecksSkipped int64 `json:"overlap_checks_skipped"`
+	TickTimeouts         int64 `json:"tick_timeouts"`
+
+	// Round trips of the last port checks
+	Quality ConnectionQuality `json:"quality"`
+
+	// Retries left to all components, omitted when the budget is off
+	RetryBudget RetryBudgetStats `json:"retry_budget,omitzero"`
+
+	// Recent durations of health checks and SSH starts and stops, keyed by operation
+	Durations map[string]DurationPercentiles `json:"durations,omitempty"`
+}
+
+// Stats returns the current tunnel counters. It is the single source of truth
+// for anything reporting tunnel health.
+func (app *Application) Stats() TunnelStats {
+	stats := TunnelStats{
+		TotalRestarts:       app.totalRestarts.Load(),
+		ConsecutiveFailures: app.consecutiveFailures.Load(),
+		LastRestartTime:     unixNanoTime(app.lastRestartTime.Load()),
+		TunnelUpSince:       unixNanoTime(app.tunnelUpSince.Load()),
+		BytesIn:             app.bytesIn.Load(),
+		BytesOut:            app.bytesOut.Load(),
+		CPUPercent:          loadFloat(&app.sshCPUPercent),
+		MemoryMB:            loadFloat(&app.sshMemoryMB),
+		HealthScore:         loadFloat(&app.healthScore),
+
+		OverlapChecksSkipped: app.overlapChecksSkipped.Load(),
+		TickTimeouts:         app.tickTimeouts.Load(),
+
+		Quality: app.quality.snapshot(),
+
+		RetryBudget: app.retryBudget.snapshot(time.Now()),
+
+		Durations: app.metrics.percentiles(),
+	}
+	if !stats.TunnelUpSince.IsZero() {
+		stats.UptimeSeconds = time.Since(stats.TunnelUpSince).Seconds()
+	}
+	return stats
+}
+
+// recordRestart updates counters for a tunnel restart.
+func (app *Application) recordRestart() {
+	app.totalRestarts.Add(1)
+	app.lastRestartTime.Store(time.Now().UnixNano())
+	app.tunnelUpSince.Store(0)
+}
+
+// recordCheckStats updates counters for a traffic check outcome.
+func (app *Application) recordCheckStats(healthy bool) {
+	if !healthy {
+		app.consecutiveFailures.Add(1)
+		app.tunnelUpSince.Store(0)
+		return
+	}
+	app.consecutiveFailures.Store(0)
+	app.lastCheckSuccess.Store(time.Now().UnixNano())
+	app.tunnelUpSince.CompareAndSwap(0, time.Now().UnixNano())
+}
+
+// unixNanoTime converts a stored Unix nanosecond timestamp, treating 0 as unset.
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestStats_Initial(t *testing.T) {
+	app := &Application{}
+
+	stats := app.Stats()
+	if stats.TotalRestarts != 0 || stats.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected initial counters: %+v", stats)
+	}
+	if !stats.LastRestartTime.IsZero() || !stats.TunnelUpSince.IsZero() {
+		t.Errorf("unexpected initial timestamps: %+v", stats)
+	}
+}
+
+func TestStats_CheckTransitions(t *testing.T) {
+	app := &Application{}
+
+	app.recordCheckStats(false)
+	app.recordCheckStats(false)
+	if got := app.Stats().ConsecutiveFailures; got != 2 {
+		t.Errorf("ConsecutiveFailures = %d, want 2", got)
+	}
+
+	app.recordCheckStats(true)
+	stats := app.Stats()
+	if stats.ConsecutiveFailures != 0 {
+		t.Errorf("ConsecutiveFailures = %d, want 0 after success", stats.ConsecutiveFailures)
+	}
+	if stats.TunnelUpSince.IsZero() {
+		t.Fatal("TunnelUpSince should be set after success")
+	}
+
+	// Further successes keep the original up-since time
+	upSince := stats.TunnelUpSince
+	app.recordCheckStats(true)
+	if got := app.Stats().TunnelUpSince; !got.Equal(upSince) {
+		t.Errorf("TunnelUpSince changed from %v to %v", upSince, got)
+	}
+
+	app.recordRestart()
+	stats = app.Stats()
+	if stats.TotalRestarts != 1 || stats.LastRestartTime.IsZero() {
+		t.Errorf("unexpected restart stats: %+v", stats)
+	}
+	if !stats.TunnelUpSince.IsZero() {
+		t.Error("TunnelUpSince should reset on restart")
+	}
+}
+
+func TestStats_ConcurrentUpdates(t *testing.T) {
+	app := &Application{}
+
+	const workers = 8
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				app.recordRestart()
+				_ = app.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := app.Stats().TotalRestarts; got != workers*iterations {
+		t.Errorf("TotalRestarts = %d, want %d", got, workers*iterations)
+	}
+}
+
+func TestStats_JSON(t *testing.T) {
+	app := &Application{}
+	app.recordRestart()
+
+	data, err := json.Marshal(app.Stats())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var decoded TunnelStats
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.TotalRestarts != 1 {
+		t.Errorf("TotalRestarts = %d, want 1", decoded.TotalRestarts)
+	}
+}
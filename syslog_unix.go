@@ -0,0 +1,82 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// syslogTag identifies ssh-tunnel records in the system log.
+const syslogTag = "ssh-tunnel"
+
+var syslogFacilities = map[string]syslog.Priority{
+	"LOG_KERN":     syslog.LOG_KERN,
+	"LOG_USER":     syslog.LOG_USER,
+	"LOG_DAEMON":   syslog.LOG_DAEMON,
+	"LOG_AUTH":     syslog.LOG_AUTH,
+	"LOG_SYSLOG":   syslog.LOG_SYSLOG,
+	"LOG_AUTHPRIV": syslog.LOG_AUTHPRIV,
+	"LOG_LOCAL0":   syslog.LOG_LOCAL0,
+	"LOG_LOCAL1":   syslog.LOG_LOCAL1,
+	"LOG_LOCAL2":   syslog.LOG_LOCAL2,
+	"LOG_LOCAL3":   syslog.LOG_LOCAL3,
+	"LOG_LOCAL4":   syslog.LOG_LOCAL4,
+	"LOG_LOCAL5":   syslog.LOG_LOCAL5,
+	"LOG_LOCAL6":   syslog.LOG_LOCAL6,
+	"LOG_LOCAL7":   syslog.LOG_LOCAL7,
+}
+
+var syslogSeverities = map[string]syslog.Priority{
+	"LOG_EMERG":   syslog.LOG_EMERG,
+	"LOG_ALERT":   syslog.LOG_ALERT,
+	"LOG_CRIT":    syslog.LOG_CRIT,
+	"LOG_ERR":     syslog.LOG_ERR,
+	"LOG_WARNING": syslog.LOG_WARNING,
+	"LOG_NOTICE":  syslog.LOG_NOTICE,
+	"LOG_INFO":    syslog.LOG_INFO,
+	"LOG_DEBUG":   syslog.LOG_DEBUG,
+}
+
+// openSyslog connects to the local syslog daemon and is replaced in tests.
+var openSyslog = func(priority string) (io.WriteCloser, error) {
+	p, err := parseSyslogPriority(priority)
+	if err != nil {
+		return nil, err
+	}
+	return syslog.New(p, syslogTag)
+}
+
+// validateSyslogPriority checks a "FACILITY|SEVERITY" priority string.
+func validateSyslogPriority(priority string) error {
+	_, err := parseSyslogPriority(priority)
+	return err
+}
+
+// parseSyslogPriority parses e.g. "LOG_DAEMON|LOG_INFO".
+// Missing parts default to LOG_DAEMON and LOG_INFO.
+func parseSyslogPriority(priority string) (syslog.Priority, error) {
+	facility, severity := syslog.LOG_DAEMON, syslog.LOG_INFO
+	var seenFacility, seenSeverity bool
+
+	for part := range strings.SplitSeq(priority, "|") {
+		name := strings.ToUpper(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+
+		if f, ok := syslogFacilities[name]; ok && !seenFacility {
+			facility, seenFacility = f, true
+			continue
+		}
+		if s, ok := syslogSeverities[name]; ok && !seenSeverity {
+			severity, seenSeverity = s, true
+			continue
+		}
+		return 0, fmt.Errorf("invalid syslog priority %q: unexpected %q", priority, part)
+	}
+
+	return facility | severity, nil
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package main
+
+import (
+	"log/syslog"
+	"testing"
+)
+
+func TestParseSyslogPriority(t *testing.T) {
+	tests := []struct {
+		priority string
+		ok       bool
+		want     syslog.Priority
+	}{
+		{"LOG_DAEMON|LOG_INFO", true, syslog.LOG_DAEMON | syslog.LOG_INFO},
+		{"LOG_LOCAL3|LOG_ERR", true, syslog.LOG_LOCAL3 | syslog.LOG_ERR},
+		{"log_user | log_debug", true, syslog.LOG_USER | syslog.LOG_DEBUG},
+		{"LOG_WARNING", true, syslog.LOG_DAEMON | syslog.LOG_WARNING},
+		{"", true, syslog.LOG_DAEMON | syslog.LOG_INFO},
+		{"LOG_BOGUS", false, 0},
+		{"LOG_INFO|LOG_ERR", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			got, err := parseSyslogPriority(tt.priority)
+			if (err == nil) != tt.ok {
+				t.Fatalf("priority=%q: err=%v, want ok=%v", tt.priority, err, tt.ok)
+			}
+			if tt.ok && got != tt.want {
+				t.Errorf("priority=%q: got %v, want %v", tt.priority, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// errSyslogUnsupported is returned because Windows has no syslog daemon.
+var errSyslogUnsupported = errors.New("syslog output is not supported on Windows")
+
+// openSyslog is unavailable on Windows and is replaced in tests.
+var openSyslog = func(priority string) (io.WriteCloser, error) {
+	return nil, errSyslogUnsupported
+}
+
+// validateSyslogPriority always fails on Windows.
+func validateSyslogPriority(priority string) error {
+	return errSyslogUnsupported
+}
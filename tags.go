@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// maxTunnelTagKeys is the number of tag keys above which a warning is logged.
+// Every key multiplies the series of whatever indexes the logs and events.
+const maxTunnelTagKeys = 10
+
+// tagKeyPattern accepts tag keys usable as log attribute and metric label names.
+var tagKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateTunnelTags checks the keys of TunnelTags.
+func (c *config) validateTunnelTags() error {
+	for key := range c.TunnelTags {
+		if !tagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid TUNNEL_TAGS key %q: must be letters, digits and underscores, not starting with a digit", key)
+		}
+	}
+	return nil
+}
+
+// tagAttrs returns TunnelTags as a "tags" log attribute group, sorted by key,
+// or nothing without tags.
+func (c *config) tagAttrs() []any {
+	if len(c.TunnelTags) == 0 {
+		return nil
+	}
+	attrs := make([]any, 0, len(c.TunnelTags))
+	for _, key := range slices.Sorted(maps.Keys(c.TunnelTags)) {
+		attrs = append(attrs, slog.String(key, c.TunnelTags[key]))
+	}
+	return []any{slog.Group("tags", attrs...)}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewConfig_TunnelTags(t *testing.T) {
+	t.Setenv("SSH_TUNNEL_REMOTE_ADDRESS", "user@example.com")
+	t.Setenv("SSH_TUNNEL_TUNNEL_TAGS", "env=prod,region=us-east-1,service=db-proxy")
+
+	cfg, err := newConfig(defaultEnvPrefix)
+	if err != nil {
+		t.Fatalf("newConfig: %v", err)
+	}
+	want := map[string]string{"env": "prod", "region": "us-east-1", "service": "db-proxy"}
+	if !maps.Equal(cfg.TunnelTags, want) {
+		t.Errorf("TunnelTags = %v, want %v", cfg.TunnelTags, want)
+	}
+}
+
+func TestValidateTunnelTags(t *testing.T) {
+	for _, key := range []string{"", "1st", "service-name", "a.b"} {
+		cfg := validConfig()
+		cfg.TunnelTags = map[string]string{key: "x"}
+		if err := cfg.validate(); err == nil {
+			t.Errorf("tag key %q should be rejected", key)
+		}
+	}
+
+	cfg := validConfig()
+	cfg.TunnelTags = map[string]string{"env": "prod", "_team": ""}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestComponentLogger_Tags(t *testing.T) {
+	cfg := validConfig()
+	cfg.LogLevel = "info"
+	cfg.TunnelTags = map[string]string{"region": "us-east-1", "env": "prod"}
+
+	var buf bytes.Buffer
+	logger, components, err := cfg.newLeveledLoggers(slog.NewTextHandler(&buf, nil))
+	if err != nil {
+		t.Fatalf("newLeveledLoggers: %v", err)
+	}
+	app := &Application{logger: logger, componentLoggers: components}
+
+	app.componentLogger(componentTunnel).Info("tunnel info")
+	app.logger.Info("global info")
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.Contains(line, "tags.env=prod tags.region=us-east-1") {
+			t.Errorf("tags missing or unsorted: %s", line)
+		}
+	}
+}
+
+func TestTunnelTags_StatusAndWebhook(t *testing.T) {
+	tags := map[string]string{"env": "prod"}
+
+	app, srv := newTestMgmtServer(t)
+	app.config.TunnelTags = tags
+	var body statusResponse
+	decodeBody(t, doRequest(t, http.MethodGet, srv.URL+"/api/v1/status", "", ""), &body)
+	if !maps.Equal(body.Tunnels[0].Tags, tags) {
+		t.Errorf("status tags = %v, want %v", body.Tunnels[0].Tags, tags)
+	}
+
+	app, received, _ := newWebhookTestApp(t, 0)
+	app.config.TunnelTags = tags
+	app.notifyWebhook(webhookTunnelDown, "test")
+	if got := waitWebhook(t, received).event.Tags; !maps.Equal(got, tags) {
+		t.Errorf("webhook tags = %v, want %v", got, tags)
+	}
+}
+
+func TestApplyConfig_KeepsTunnelTags(t *testing.T) {
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.TunnelTags = map[string]string{"env": "prod"}
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		t.Fatalf("createHTTPTransport: %v", err)
+	}
+	app.httpTransport = transport
+	// Keep applyConfig from restarting the tunnel
+	app.paused.Store(true)
+
+	cfg := *app.config
+	cfg.TunnelTags = map[string]string{"env": "staging"}
+	app.applyConfig(&cfg)
+
+	if got := app.config.TunnelTags["env"]; got != "prod" {
+		t.Errorf("env tag = %q after reload, want the startup value", got)
+	}
+}
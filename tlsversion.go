@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions maps the TLSMinVersion and TLSMaxVersion values to protocol versions.
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// validateTLSVersions checks the TLS version bounds of traffic checks.
+// An empty value leaves that bound to Go's default.
+func (c *config) validateTLSVersions() error {
+	for _, v := range []string{c.TLSMinVersion, c.TLSMaxVersion} {
+		if _, ok := tlsVersions[v]; v != "" && !ok {
+			return fmt.Errorf("invalid TLS version %q: must be TLS10, TLS11, TLS12 or TLS13", v)
+		}
+	}
+	if c.TLSMinVersion != "" && c.TLSMaxVersion != "" && tlsVersions[c.TLSMinVersion] > tlsVersions[c.TLSMaxVersion] {
+		return fmt.Errorf("TLS min version %s is above max version %s", c.TLSMinVersion, c.TLSMaxVersion)
+	}
+	return nil
+}
+
+// tlsVersionConfig returns a TLS config that only sets the configured version bounds.
+func (c *config) tlsVersionConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tlsVersions[c.TLSMinVersion],
+		MaxVersion: tlsVersions[c.TLSMaxVersion],
+	}
+}
+
+// trafficCheckTLSConfig returns the TLS config for HTTP traffic checks: the version
+// bounds plus the certificate pin, if one is set. The usual chain verification still
+// runs; the pin is checked on top of it.
+func (c *config) trafficCheckTLSConfig() *tls.Config {
+	tlsConfig := c.tlsVersionConfig()
+	if len(c.pinnedCerts) > 0 {
+		tlsConfig.VerifyPeerCertificate = verifyPinnedCert(c.pinnedCerts)
+	}
+	return tlsConfig
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidate_TLSVersions(t *testing.T) {
+	tests := []struct {
+		min, max string
+		ok       bool
+	}{
+		{"", "", true},
+		{"TLS12", "", true},
+		{"TLS10", "TLS13", true},
+		{"TLS13", "TLS13", true},
+		{"", "TLS11", true},
+		{"TLS13", "TLS12", false},
+		{"SSL30", "", false},
+		{"TLS12", "tls13", false},
+	}
+
+	for _, tt := range tests {
+		cfg := validConfig()
+		cfg.TLSMinVersion = tt.min
+		cfg.TLSMaxVersion = tt.max
+		if err := cfg.validate(); (err == nil) != tt.ok {
+			t.Errorf("min %q max %q: error = %v, want ok=%v", tt.min, tt.max, err, tt.ok)
+		}
+	}
+}
+
+func TestCreateHTTPTransport_TLSVersions(t *testing.T) {
+	app := newTestApp(t)
+	app.config.TLSMinVersion = "TLS12"
+	app.config.TLSMaxVersion = "TLS13"
+
+	transport, err := app.createHTTPTransport()
+	if err != nil {
+		t.Fatalf("createHTTPTransport: %v", err)
+	}
+	if got := transport.TLSClientConfig; got.MinVersion != tls.VersionTLS12 || got.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("TLS versions = %x-%x, want %x-%x", got.MinVersion, got.MaxVersion, tls.VersionTLS12, tls.VersionTLS13)
+	}
+}
+
+func TestTLSVersionConfig_RejectsOlderServer(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	cfg := validConfig()
+	cfg.TLSMinVersion = "TLS13"
+	tlsConfig := cfg.tlsVersionConfig()
+	tlsConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(server.URL)
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected handshake failure against a TLS 1.2 server with TLS13 minimum")
+	}
+}
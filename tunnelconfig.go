@@ -0,0 +1,74 @@
+package main
+
+import "strconv"
+
+// TunnelConfig is one forwarding rule of the SSH process: the server it goes
+// through, what it forwards and where health checks reach it.
+type TunnelConfig struct {
+	BindHost      string `json:"bind_host"`      // local SOCKS listener (-D) in dynamic mode
+	RemoteAddress string `json:"remote_address"` // SSH server, user@host
+	RemotePort    int    `json:"remote_port"`    // SSH server port
+	TunnelMode    string `json:"tunnel_mode"`    // tunnelModeDynamic, tunnelModeRemote or tunnelModeLocal
+	LocalForward  string `json:"local_forward"`  // -L spec, "[bind_address:]port:host:hostport"
+	RemoteForward string `json:"remote_forward"` // -R spec, "[bind_address:]port:host:hostport"
+	ProxyHost     string `json:"proxy_host"`     // address health checks connect to
+}
+
+// flatTunnels builds Tunnels from the single-tunnel settings: one rule per
+// bind host in dynamic mode and one rule per -R or -L rule in remote and local
+// mode. It runs after proxyHosts are derived, which line up
+// with the bind hosts.
+func (c *config) flatTunnels() []TunnelConfig {
+	base := TunnelConfig{
+		RemoteAddress: c.remoteAddress(),
+		RemotePort:    c.remotePort(),
+		TunnelMode:    c.TunnelMode,
+	}
+
+	if c.TunnelMode == tunnelModeRemote || c.TunnelMode == tunnelModeLocal {
+		tunnels := make([]TunnelConfig, 0, len(c.forwards))
+		for _, forward := range c.forwards {
+			tunnel := base
+			if c.TunnelMode == tunnelModeRemote {
+				tunnel.RemoteForward = forward.spec
+			} else {
+				tunnel.LocalForward = forward.spec
+			}
+			tunnel.ProxyHost = forward.check
+			tunnels = append(tunnels, tunnel)
+		}
+		return tunnels
+	}
+
+	bindHosts := c.bindHosts()
+	tunnels := make([]TunnelConfig, 0, len(bindHosts))
+	for i, bindHost := range bindHosts {
+		tunnel := base
+		tunnel.BindHost = bindHost
+		if i < len(c.proxyHosts) {
+			tunnel.ProxyHost = c.proxyHosts[i]
+		}
+		tunnels = append(tunnels, tunnel)
+	}
+	return tunnels
+}
+
+// forwardOptions returns the ssh arguments of the rule's forwards.
+func (t TunnelConfig) forwardOptions() []string {
+	var opts []string
+	if t.TunnelMode != tunnelModeRemote && t.BindHost != "" {
+		opts = append(opts, "-D", t.BindHost)
+	}
+	if t.LocalForward != "" {
+		opts = append(opts, "-L", t.LocalForward)
+	}
+	if t.RemoteForward != "" {
+		opts = append(opts, "-R", t.RemoteForward)
+	}
+	return opts
+}
+
+// destinationOptions returns the trailing ssh arguments naming the server.
+func (t TunnelConfig) destinationOptions() []string {
+	return []string{"-p", strconv.Itoa(t.RemotePort), t.RemoteAddress}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidate_FlatTunnels(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	want := []TunnelConfig{{
+		BindHost:      cfg.SSHBindHost,
+		RemoteAddress: cfg.SSHRemoteAddress,
+		RemotePort:    cfg.SSHRemotePort,
+		TunnelMode:    tunnelModeDynamic,
+		ProxyHost:     cfg.proxyHost,
+	}}
+	if !reflect.DeepEqual(cfg.Tunnels, want) {
+		t.Errorf("Tunnels = %+v, want %+v", cfg.Tunnels, want)
+	}
+}
+
+func TestValidate_FlatTunnelsPerBindHost(t *testing.T) {
+	cfg := validConfig()
+	cfg.SSHBindHosts = []string{"0.0.0.0:1080", "[::1]:1081"}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if len(cfg.Tunnels) != 2 {
+		t.Fatalf("got %d tunnels, want one per bind host", len(cfg.Tunnels))
+	}
+	for i, tunnel := range cfg.Tunnels {
+		if tunnel.BindHost != cfg.SSHBindHosts[i] || tunnel.ProxyHost != cfg.proxyHosts[i] {
+			t.Errorf("tunnel %d = %+v, want bind host %q and proxy host %q", i, tunnel, cfg.SSHBindHosts[i], cfg.proxyHosts[i])
+		}
+	}
+}
+
+func TestValidate_FlatTunnelsRemote(t *testing.T) {
+	cfg := remoteForwardConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if len(cfg.Tunnels) != 1 {
+		t.Fatalf("got %d tunnels, want 1", len(cfg.Tunnels))
+	}
+	tunnel := cfg.Tunnels[0]
+	if tunnel.BindHost != "" || tunnel.RemoteForward != cfg.remoteForwardSpec() || tunnel.ProxyHost != cfg.proxyHost {
+		t.Errorf("tunnel = %+v, want the remote forward without a bind host", tunnel)
+	}
+}
+
+func TestDeriveProxyHost_UpdatesTunnels(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	cfg.activeBindHost = "127.0.0.1:9090"
+	if err := cfg.deriveProxyHost(); err != nil {
+		t.Fatalf("deriveProxyHost: %v", err)
+	}
+
+	opts := cfg.serializeSSHOptions()
+	if !slices.Contains(opts, "127.0.0.1:9090") || slices.Contains(opts, cfg.SSHBindHost) {
+		t.Errorf("SSH options should forward the active bind host only: %v", opts)
+	}
+}
+
+func TestTunnelConfigForwardOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		tunnel TunnelConfig
+		want   string
+	}{
+		{"dynamic", TunnelConfig{TunnelMode: tunnelModeDynamic, BindHost: "127.0.0.1:1080"}, "-D 127.0.0.1:1080"},
+		{"dynamic with local forward", TunnelConfig{TunnelMode: tunnelModeDynamic, BindHost: "127.0.0.1:1080", LocalForward: "5432:db:5432"}, "-D 127.0.0.1:1080 -L 5432:db:5432"},
+		{"remote", TunnelConfig{TunnelMode: tunnelModeRemote, BindHost: "127.0.0.1:1080", RemoteForward: "9000:localhost:3000"}, "-R 9000:localhost:3000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.tunnel.forwardOptions(), " "); got != tt.want {
+				t.Errorf("forwardOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log/slog"
+	"os/exec"
+)
+
+// processExit records how a watched SSH process ended.
+type processExit struct {
+	done chan struct{} // closed once the process has exited and been reaped
+	err  error         // result of Wait, valid after done is closed
+}
+
+// watchSSHProcess reaps cmd in the background and signals processDied if the
+// current tunnel process exits on its own, so the main loop restarts it right
+// away instead of at the next traffic check. Once a process is watched, only
+// the watcher calls Wait; everyone else goes through waitSSH. The process is
+// also tracked in the session log from start to exit.
+//
+// The goroutine ends with the process. Exits during start or stop are left to
+// startSSH and stopSSH, which hold sshMutex while they wait for the process.
+func (app *Application) watchSSHProcess(cmd *exec.Cmd, logger *slog.Logger) {
+	exit := &processExit{done: make(chan struct{})}
+	app.processExits.Store(cmd, exit)
+	app.recordSessionStart(cmd)
+
+	go func() {
+		exit.err = cmd.Wait()
+		app.recordSessionEnd(cmd)
+		close(exit.done)
+
+		app.sshMutex.RLock()
+		current := app.sshProcess == cmd
+		app.sshMutex.RUnlock()
+		app.processExits.Delete(cmd)
+
+		if !current || app.paused.Load() {
+			return
+		}
+		if state := app.state(); state != StateRunning && state != StateDegraded {
+			return
+		}
+
+		logger.Warn("SSH process exited unexpectedly", "pid", cmd.Process.Pid, "error", exit.err)
+		select {
+		case app.processDied <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+// waitSSH waits for cmd to exit and returns the result of Wait.
+func (app *Application) waitSSH(cmd *exec.Cmd) error {
+	if value, ok := app.processExits.Load(cmd); ok {
+		exit := value.(*processExit)
+		<-exit.done
+		return exit.err
+	}
+	if cmd.ProcessState != nil {
+		// Already reaped by its watcher
+		return nil
+	}
+	return cmd.Wait()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// startWatchedSSH starts the fake ssh through startSSH and stops it at cleanup.
+func startWatchedSSH(t *testing.T) *Application {
+	t.Helper()
+	useFakeSSH(t)
+
+	app := newTestApp(t)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if err := app.startSSH(app.logger); err != nil {
+		t.Fatalf("startSSH: %v", err)
+	}
+	t.Cleanup(func() { app.stopSSH(app.logger) })
+	return app
+}
+
+func TestWatchSSHProcess_RestartsOnExit(t *testing.T) {
+	app := startWatchedSSH(t)
+	app.config.MainLoopSleep = time.Minute
+	old := app.sshProcess
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.run()
+	}()
+	defer func() {
+		close(app.shutdownChan)
+		<-done
+	}()
+
+	if err := old.Process.Kill(); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		app.sshMutex.RLock()
+		current := app.sshProcess
+		running := app.isProcessRunning(current)
+		app.sshMutex.RUnlock()
+		if current != old && running && app.state() == StateRunning {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("SSH process was not restarted after it was killed")
+}
+
+func TestWatchSSHProcess_IgnoresStop(t *testing.T) {
+	app := startWatchedSSH(t)
+	cmd := app.sshProcess
+
+	app.stopSSH(app.logger)
+
+	if app.isProcessRunning(cmd) {
+		t.Error("stopped SSH process should not be running")
+	}
+	select {
+	case <-app.processDied:
+		t.Error("stopping the tunnel should not signal processDied")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook event types.
+const (
+	webhookTunnelDown      = "tunnel_down"
+	webhookTunnelRecovered = "tunnel_recovered"
+)
+
+// webhookSignatureHeader carries the hex HMAC-SHA256 of the request body.
+const webhookSignatureHeader = "X-Signature-SHA256"
+
+// webhookMaxAttempts is the number of delivery attempts per event.
+const webhookMaxAttempts = 3
+
+// webhookBackoff is the delay before the first retry; it doubles on each attempt.
+// Replaced in tests.
+var webhookBackoff = 1 * time.Second
+
+// webhookEvent is the JSON body posted to the webhook URL.
+type webhookEvent struct {
+	Event     string    `json:"event"`
+	TunnelID  string    `json:"tunnel_id"`
+	ProxyHost string    `json:"proxy_host"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason,omitempty"`
+	RestartID string    `json:"restart_id,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// notifyWebhook dispatches an event to the configured webhook without blocking the caller.
+func (app *Application) notifyWebhook(event, reason string) {
+	if app.config.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(webhookEvent{
+		Event:     event,
+		TunnelID:  app.tunnelID(),
+		ProxyHost: app.config.proxyHost,
+		Timestamp: time.Now().UTC(),
+		Reason:    reason,
+		RestartID: app.restartID,
+		Tags:      app.config.TunnelTags,
+	})
+	if err != nil {
+		app.componentLogger(componentTunnel).Error("Failed to encode webhook event", "error", err)
+		return
+	}
+
+	// Capture settings now so a concurrent reload can't change them mid-delivery
+	go app.deliverWebhook(app.config.WebhookURL, app.config.WebhookSecret, event, body)
+}
+
+// deliverWebhook posts the body with retry and exponential backoff.
+func (app *Application) deliverWebhook(url, secret, event string, body []byte) {
+	logger := app.componentLogger(componentTunnel)
+	client := &http.Client{Timeout: 10 * time.Second}
+	backoff := webhookBackoff
+
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		err := postWebhook(client, url, secret, body)
+		if err == nil {
+			logger.Info("Webhook delivered", "event", event, "attempt", attempt)
+			return
+		}
+		logger.Warn("Webhook delivery failed", "event", event, "attempt", attempt, "error", err)
+
+		if attempt == webhookMaxAttempts {
+			break
+		}
+		if !app.retryBudget.take(time.Now()) {
+			logger.Error("Retry budget exhausted, webhook delivery abandoned", "event", event, "attempts", attempt)
+			return
+		}
+
+		select {
+		case <-app.shutdownChan:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	logger.Error("Webhook delivery abandoned", "event", event, "attempts", webhookMaxAttempts)
+}
+
+// postWebhook sends a single signed webhook request.
+func postWebhook(client *http.Client, url, secret string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhook(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhook returns the hex-encoded HMAC-SHA256 of body keyed by secret.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// receivedWebhook is a webhook request captured by the test server.
+type receivedWebhook struct {
+	event     webhookEvent
+	signature string
+	body      []byte
+}
+
+// newWebhookTestApp returns an app posting webhooks to a test server.
+// The first failCount requests are answered with 500.
+func newWebhookTestApp(t *testing.T, failCount int32) (*Application, <-chan receivedWebhook, *atomic.Int32) {
+	t.Helper()
+
+	originalBackoff := webhookBackoff
+	webhookBackoff = time.Millisecond
+	t.Cleanup(func() { webhookBackoff = originalBackoff })
+
+	received := make(chan receivedWebhook, 10)
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= failCount {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read webhook body: %v", err)
+			return
+		}
+		var event webhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- receivedWebhook{event: event, signature: r.Header.Get(webhookSignatureHeader), body: body}
+	}))
+	t.Cleanup(srv.Close)
+
+	app := newTestApp(t)
+	app.logger = slog.New(slog.DiscardHandler)
+	app.config.WebhookURL = srv.URL
+	app.config.WebhookSecret = "hook-secret"
+	return app, received, &attempts
+}
+
+// waitWebhook waits for the next webhook delivery.
+func waitWebhook(t *testing.T, received <-chan receivedWebhook) receivedWebhook {
+	t.Helper()
+	select {
+	case hook := <-received:
+		return hook
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+		return receivedWebhook{}
+	}
+}
+
+func TestRecordCheckResult_DownAndRecovery(t *testing.T) {
+	app, received, _ := newWebhookTestApp(t, 0)
+
+	app.recordCheckResult(errors.New("proxy port unavailable"))
+	down := waitWebhook(t, received)
+	if down.event.Event != webhookTunnelDown {
+		t.Errorf("event = %q, want %q", down.event.Event, webhookTunnelDown)
+	}
+	if down.event.Reason != "proxy port unavailable" {
+		t.Errorf("reason = %q, want failure reason", down.event.Reason)
+	}
+	if down.event.TunnelID != "8080" || down.event.ProxyHost != "127.0.0.1:8080" {
+		t.Errorf("unexpected tunnel fields: %+v", down.event)
+	}
+	if down.signature != signWebhook("hook-secret", down.body) {
+		t.Error("signature does not match body")
+	}
+
+	// Repeated failures must not re-notify
+	app.recordCheckResult(errors.New("still down"))
+
+	app.recordCheckResult(nil)
+	recovered := waitWebhook(t, received)
+	if recovered.event.Event != webhookTunnelRecovered {
+		t.Errorf("event = %q, want %q", recovered.event.Event, webhookTunnelRecovered)
+	}
+}
+
+func TestNotifyWebhook_RestartID(t *testing.T) {
+	app, received, _ := newWebhookTestApp(t, 0)
+	app.restartID = "3f2b8c1e-4d5a-4e6f-8a9b-0c1d2e3f4a5b"
+
+	app.recordCheckResult(errors.New("proxy port unavailable"))
+	hook := waitWebhook(t, received)
+	if hook.event.RestartID != app.restartID {
+		t.Errorf("restart_id = %q, want %q", hook.event.RestartID, app.restartID)
+	}
+}
+
+func TestRecordCheckResult_HealthyNoNotification(t *testing.T) {
+	app, received, _ := newWebhookTestApp(t, 0)
+
+	app.recordCheckResult(nil)
+
+	select {
+	case hook := <-received:
+		t.Errorf("unexpected webhook: %+v", hook.event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeliverWebhook_Retries(t *testing.T) {
+	app, received, attempts := newWebhookTestApp(t, 2)
+
+	app.notifyWebhook(webhookTunnelDown, "boom")
+	waitWebhook(t, received)
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestDeliverWebhook_GivesUp(t *testing.T) {
+	app, _, attempts := newWebhookTestApp(t, 100)
+
+	// Run synchronously so the attempt count is final
+	app.deliverWebhook(app.config.WebhookURL, "", webhookTunnelDown, []byte("{}"))
+
+	if got := attempts.Load(); got != webhookMaxAttempts {
+		t.Errorf("attempts = %d, want %d", got, webhookMaxAttempts)
+	}
+}
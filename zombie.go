@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// errZombieDetectionUnsupported is returned on platforms without a way to list processes.
+var errZombieDetectionUnsupported = errors.New("zombie SSH detection is not supported on this platform")
+
+// zombieExitTimeout bounds the wait for the ports of killed processes to be released.
+const zombieExitTimeout = 2 * time.Second
+
+// processInfo is a process found by listProcesses.
+type processInfo struct {
+	pid  int
+	args []string // command line, args[0] being the program
+}
+
+// detectZombieSSH kills ssh processes left over from an earlier run of this tunnel,
+// e.g. when stopSSH lost track of one, which would otherwise keep the proxy port and
+// make the new ssh fail with "address already in use". A process counts as ours if it
+// is an ssh with a -D forward to one of the configured bind hosts.
+func (app *Application) detectZombieSSH(logger *slog.Logger) {
+	processes, err := listProcesses()
+	if err != nil {
+		logger.Warn("Failed to look for leftover SSH processes", "error", err)
+		return
+	}
+
+	// It runs without sshMutex, so a concurrent start may have an ssh up by now
+	ownPID := 0
+	app.sshMutex.RLock()
+	if app.sshProcess != nil && app.sshProcess.Process != nil {
+		ownPID = app.sshProcess.Process.Pid
+	}
+	app.sshMutex.RUnlock()
+
+	bindHosts := app.config.bindHosts()
+	killed := 0
+	for _, proc := range processes {
+		if proc.pid == os.Getpid() || proc.pid == ownPID || !isSSHForwarding(proc.args, bindHosts) {
+			continue
+		}
+
+		process, err := os.FindProcess(proc.pid)
+		if err == nil {
+			err = process.Kill()
+		}
+		if err != nil && !errors.Is(err, os.ErrProcessDone) {
+			logger.Error("Failed to kill leftover SSH process", "pid", proc.pid, "error", err)
+			continue
+		}
+		logger.Warn("Killed leftover SSH process", "pid", proc.pid, "args", strings.Join(proc.args, " "))
+		killed++
+	}
+
+	// The kernel closes the sockets of a killed process shortly after the signal
+	deadline := time.Now().Add(zombieExitTimeout)
+	for killed > 0 && app.config.checkBindHostsFree() != nil && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// isSSHForwarding reports whether args run ssh with a -D forward to one of bindHosts,
+// given either as "-D host:port" or "-Dhost:port".
+func isSSHForwarding(args []string, bindHosts []string) bool {
+	if len(args) == 0 || filepath.Base(args[0]) != "ssh" {
+		return false
+	}
+	for i, arg := range args[1:] {
+		forward, ok := strings.CutPrefix(arg, "-D")
+		if !ok {
+			continue
+		}
+		if forward == "" && i+2 < len(args) {
+			forward = args[i+2]
+		}
+		if slices.Contains(bindHosts, forward) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+//go:build darwin
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listProcesses returns all processes as reported by ps; macOS has no /proc.
+func listProcesses() ([]processInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "ps", "-axo", "pid=,args=").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePSProcesses(string(out)), nil
+}
+
+// parsePSProcesses parses "ps -o pid=,args=" lines such as "  4242 ssh -N -D 127.0.0.1:8080 host".
+// ps joins arguments with spaces, so arguments containing spaces are split.
+func parsePSProcesses(out string) []processInfo {
+	var processes []processInfo
+	for line := range strings.Lines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		processes = append(processes, processInfo{pid: pid, args: fields[1:]})
+	}
+	return processes
+}
@@ -0,0 +1,20 @@
+//go:build darwin
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePSProcesses(t *testing.T) {
+	out := "    1 /sbin/launchd\n 4242 ssh -N -D 127.0.0.1:8080 user@host\n\nbogus line\n"
+
+	got := parsePSProcesses(out)
+	if len(got) != 2 {
+		t.Fatalf("got %d processes, want 2: %+v", len(got), got)
+	}
+	if got[1].pid != 4242 || !slices.Equal(got[1].args, []string{"ssh", "-N", "-D", "127.0.0.1:8080", "user@host"}) {
+		t.Errorf("process = %+v, want pid 4242 with its ssh arguments", got[1])
+	}
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// listProcesses returns the processes with a readable command line from /proc.
+// Processes that exit while being read are skipped.
+func listProcesses() ([]processInfo, error) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := make([]processInfo, 0, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")) //nolint:gosec // fixed /proc path
+		if err != nil || len(cmdline) == 0 {
+			// Gone, or a kernel thread
+			continue
+		}
+		processes = append(processes, processInfo{pid: pid, args: parseCmdline(cmdline)})
+	}
+	return processes, nil
+}
+
+// parseCmdline splits /proc/<pid>/cmdline content at its NUL separators.
+func parseCmdline(cmdline []byte) []string {
+	fields := bytes.Split(bytes.TrimSuffix(cmdline, []byte{0}), []byte{0})
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		args[i] = string(field)
+	}
+	return args
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package main
+
+import (
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCmdline(t *testing.T) {
+	got := parseCmdline([]byte("ssh\x00-D\x00127.0.0.1:8080\x00user@host\x00"))
+	want := []string{"ssh", "-D", "127.0.0.1:8080", "user@host"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseCmdline() = %q, want %q", got, want)
+	}
+}
+
+// startFakeSSHCommand starts a shell whose command line looks like ssh forwarding bindHost.
+func startFakeSSHCommand(t *testing.T, bindHost string) *exec.Cmd {
+	t.Helper()
+	requireShell(t)
+
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	cmd := &exec.Cmd{Path: shell, Args: []string{"ssh", "-c", "while :; do sleep 1; done", "ssh", "-N", "-D", bindHost, "user@host"}}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start fake ssh: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	return cmd
+}
+
+func TestDetectZombieSSH(t *testing.T) {
+	app := newTestApp(t)
+	bindHost, err := freeBindHost("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("freeBindHost: %v", err)
+	}
+	app.config.SSHBindHost = bindHost
+	if err := app.config.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	zombie := startFakeSSHCommand(t, bindHost)
+	other := startFakeSSHCommand(t, "127.0.0.1:1")
+	exited := make(chan struct{})
+	go func() {
+		_ = zombie.Wait()
+		close(exited)
+	}()
+
+	app.detectZombieSSH(app.logger)
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ssh forwarding the bind host was not killed")
+	}
+	if alive, _ := isProcessAlive(other.Process.Pid); !alive {
+		t.Error("ssh forwarding another address should be left alone")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// listProcesses is unavailable outside Linux and macOS.
+func listProcesses() ([]processInfo, error) {
+	return nil, errZombieDetectionUnsupported
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestIsSSHForwarding(t *testing.T) {
+	bindHosts := []string{"127.0.0.1:8080", "127.0.0.1:8081"}
+
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"separate argument", []string{"ssh", "-N", "-D", "127.0.0.1:8080", "user@host"}, true},
+		{"joined argument", []string{"/usr/bin/ssh", "-N", "-D127.0.0.1:8081", "user@host"}, true},
+		{"other bind host", []string{"ssh", "-N", "-D", "127.0.0.1:9090", "user@host"}, false},
+		{"no forward", []string{"ssh", "user@host"}, false},
+		{"trailing -D", []string{"ssh", "-D"}, false},
+		{"not ssh", []string{"autossh", "-D", "127.0.0.1:8080", "user@host"}, false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSSHForwarding(tt.args, bindHosts); got != tt.want {
+				t.Errorf("isSSHForwarding(%q) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}